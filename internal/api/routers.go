@@ -35,6 +35,19 @@ func Routers(router *gin.Engine, app *service.App) {
 		auth.POST("/create/robot", app.CreateRobot)
 		auth.GET("/list/robot", app.RobotList)
 		auth.PUT("/update/robot", app.UpdateRobot)
+		auth.GET("/export/robot", app.ExportRobot)
+		auth.POST("/import/robot", app.ImportRobot)
+		auth.POST("/clone/robot", app.CloneRobot)
+		auth.GET("/list/provider", app.ProviderList)
+		auth.GET("/list/call", app.CallList)
+		auth.POST("/call/transfer", app.TransferCall)
+		auth.POST("/call/outbound", app.OutboundCall)
+		auth.GET("/call/status", app.CallStatus)
+		auth.GET("/call/events", app.CallEvents)
+		auth.POST("/create/phoneNumber", app.CreatePhoneNumber)
+		auth.GET("/list/phoneNumber", app.PhoneNumberList)
+		auth.PUT("/update/phoneNumber", app.UpdatePhoneNumber)
+		auth.POST("/preview/robot/prompt", app.PreviewRobotPrompt)
 		auth.GET("/webrtc/init", func(c *gin.Context) {
 			app.FrontendForWeb.FrontendInit(c)
 		})
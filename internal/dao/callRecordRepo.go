@@ -0,0 +1,70 @@
+package dao
+
+import (
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"miniRustpbxgo/internal/model"
+	"time"
+)
+
+type CallRecordRepo struct {
+	db *gorm.DB
+}
+
+func NewCallRecordRepo(db *gorm.DB) *CallRecordRepo {
+	return &CallRecordRepo{db: db}
+}
+
+// CreateCallRecord 创建通话记录
+func (r *CallRecordRepo) CreateCallRecord(record *model.CallRecord) (*model.CallRecord, error) {
+	now := time.Now()
+	record.CreatedAt = now
+	record.UpdatedAt = now
+
+	result := r.db.Create(record)
+	if result.Error != nil {
+		logrus.Error("CreateCallRecord failed: ", result.Error)
+		return nil, result.Error
+	}
+	return record, nil
+}
+
+// GetCallRecordByCallID 根据通话唯一标识查询通话记录
+func (r *CallRecordRepo) GetCallRecordByCallID(callID string) (*model.CallRecord, error) {
+	var record model.CallRecord
+	result := r.db.Where("call_id = ?", callID).First(&record)
+	if result.Error != nil {
+		logrus.Error("GetCallRecordByCallID failed: ", result.Error)
+		return nil, result.Error
+	}
+	return &record, nil
+}
+
+// UpdateCallRecordPartial 部分更新通话记录（只更新指定字段）
+func (r *CallRecordRepo) UpdateCallRecordPartial(id uint, updates map[string]interface{}) error {
+	updates["updated_at"] = time.Now()
+	result := r.db.Model(&model.CallRecord{}).Where("id = ?", id).Updates(updates)
+	if result.Error != nil {
+		logrus.Error("UpdateCallRecordPartial failed: ", result.Error)
+	}
+	return result.Error
+}
+
+// ListCallRecords 按查询参数分页查询指定用户的通话记录，支持按主被叫号码搜索，robotID为0、answeredBy为空时不过滤
+func (r *CallRecordRepo) ListCallRecords(userID uint, robotID uint, answeredBy string, query ListQuery) (*ListResult[model.CallRecord], error) {
+	db := r.db.Model(&model.CallRecord{}).Where("user_id = ?", userID)
+	if robotID != 0 {
+		db = db.Where("robot_id = ?", robotID)
+	}
+	if answeredBy != "" {
+		db = db.Where("answered_by = ?", answeredBy)
+	}
+	result, err := listPage(db, query, []string{"caller", "callee"}, func(record *model.CallRecord) (time.Time, uint) {
+		return sortTime(query, record.CreatedAt, record.UpdatedAt), record.ID
+	})
+	if err != nil {
+		logrus.Error("ListCallRecords failed: ", err)
+		return nil, err
+	}
+	return result, nil
+}
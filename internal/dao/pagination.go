@@ -0,0 +1,157 @@
+package dao
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 分页默认值与上限
+const (
+	DefaultPageSize = 10
+	MaxPageSize     = 100
+)
+
+// 支持排序的字段
+const (
+	SortByCreatedAt = "created_at"
+	SortByUpdatedAt = "updated_at"
+)
+
+// ListQuery 列表查询参数，Cursor不为空时使用游标分页并忽略Page
+type ListQuery struct {
+	Page   int
+	Size   int
+	Cursor string
+	Name   string // 模糊搜索关键字
+	SortBy string // created_at|updated_at，默认created_at
+	Desc   bool   // 是否倒序
+}
+
+// normalize 填充默认值并限制分页大小
+func (q *ListQuery) normalize() {
+	if q.Page < 1 {
+		q.Page = 1
+	}
+	if q.Size < 1 {
+		q.Size = DefaultPageSize
+	}
+	if q.Size > MaxPageSize {
+		q.Size = MaxPageSize
+	}
+	if q.SortBy != SortByUpdatedAt {
+		q.SortBy = SortByCreatedAt
+	}
+}
+
+// ListResult 列表查询结果
+type ListResult[T any] struct {
+	Items      []T
+	Total      int64
+	NextCursor string // 为空表示没有更多数据
+}
+
+// likeEscaper 转义搜索关键字中的通配符，使其按字面匹配。
+// 使用!作为转义字符，避免反斜杠在不同数据库字符串字面量中的差异
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// listPage 对已带过滤条件的查询执行计数、搜索、排序和分页
+// searchColumns 为模糊搜索的字段，cursorOf 返回记录的排序时间和主键用于生成下一页游标
+func listPage[T any](db *gorm.DB, q ListQuery, searchColumns []string, cursorOf func(item *T) (time.Time, uint)) (*ListResult[T], error) {
+	q.normalize()
+	if q.Name != "" && len(searchColumns) > 0 {
+		conditions := make([]string, 0, len(searchColumns))
+		args := make([]any, 0, len(searchColumns))
+		for _, column := range searchColumns {
+			conditions = append(conditions, column+" LIKE ? ESCAPE '!'")
+			args = append(args, "%"+likeEscaper.Replace(q.Name)+"%")
+		}
+		db = db.Where("("+strings.Join(conditions, " OR ")+")", args...)
+	}
+
+	var total int64
+	if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	order := "ASC"
+	compare := ">"
+	if q.Desc {
+		order = "DESC"
+		compare = "<"
+	}
+	query := db.Session(&gorm.Session{})
+	if q.Cursor != "" {
+		sortValue, id, err := decodeCursor(q.Cursor, q)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(fmt.Sprintf("(%s %s ? OR (%s = ? AND id %s ?))", q.SortBy, compare, q.SortBy, compare), sortValue, sortValue, id)
+	} else {
+		query = query.Offset((q.Page - 1) * q.Size)
+	}
+
+	var items []T
+	// 多查一条用于判断是否还有下一页
+	if err := query.Order(q.SortBy + " " + order).Order("id " + order).Limit(q.Size + 1).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	result := &ListResult[T]{Total: total}
+	if len(items) > q.Size {
+		items = items[:q.Size]
+		sortValue, id := cursorOf(&items[len(items)-1])
+		result.NextCursor = encodeCursor(q, sortValue, id)
+	}
+	result.Items = items
+	return result, nil
+}
+
+// encodeCursor 游标格式为 base64(排序字段,排序方向,排序时间纳秒,主键)，
+// 排序字段和方向用于拒绝换了排序方式后继续使用的游标
+func encodeCursor(q ListQuery, sortValue time.Time, id uint) string {
+	raw := fmt.Sprintf("%s,%s,%d,%d", q.SortBy, cursorOrder(q), sortValue.UnixNano(), id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string, q ListQuery) (time.Time, uint, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, errors.New("invalid cursor")
+	}
+	parts := strings.Split(string(raw), ",")
+	if len(parts) != 4 {
+		return time.Time{}, 0, errors.New("invalid cursor")
+	}
+	if parts[0] != q.SortBy || parts[1] != cursorOrder(q) {
+		return time.Time{}, 0, errors.New("cursor does not match sort_by and order")
+	}
+	nanos, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return time.Time{}, 0, errors.New("invalid cursor")
+	}
+	id, err := strconv.ParseUint(parts[3], 10, 64)
+	if err != nil {
+		return time.Time{}, 0, errors.New("invalid cursor")
+	}
+	return time.Unix(0, nanos), uint(id), nil
+}
+
+// cursorOrder 游标中记录的排序方向
+func cursorOrder(q ListQuery) string {
+	if q.Desc {
+		return "desc"
+	}
+	return "asc"
+}
+
+// sortTime 返回记录在当前排序字段上的时间
+func sortTime(q ListQuery, createdAt, updatedAt time.Time) time.Time {
+	if q.SortBy == SortByUpdatedAt {
+		return updatedAt
+	}
+	return createdAt
+}
@@ -0,0 +1,113 @@
+package dao
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+func TestCursor(t *testing.T) {
+	tests := []struct {
+		name      string
+		sortValue time.Time
+		id        uint
+	}{
+		{name: "nanoseconds are kept", sortValue: time.Date(2024, 5, 1, 8, 30, 0, 123456789, time.UTC), id: 42},
+		{name: "zero id", sortValue: time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC), id: 0},
+		{name: "before 1970", sortValue: time.Date(1969, 12, 31, 23, 59, 59, 0, time.UTC), id: 7},
+		{name: "large id", sortValue: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), id: 1<<32 + 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := ListQuery{SortBy: SortByUpdatedAt, Desc: true}
+			sortValue, id, err := decodeCursor(encodeCursor(query, tt.sortValue, tt.id), query)
+			if err != nil {
+				t.Fatalf("decodeCursor: %v", err)
+			}
+			if !sortValue.Equal(tt.sortValue) || id != tt.id {
+				t.Errorf("decodeCursor = %v, %d, want %v, %d", sortValue, id, tt.sortValue, tt.id)
+			}
+		})
+	}
+}
+
+func TestDecodeInvalidCursor(t *testing.T) {
+	encode := func(raw string) string { return base64.RawURLEncoding.EncodeToString([]byte(raw)) }
+	query := ListQuery{SortBy: SortByCreatedAt, Desc: true}
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{name: "not base64", cursor: "not a cursor!"},
+		{name: "padded base64", cursor: base64.URLEncoding.EncodeToString([]byte("created_at,desc,10,2"))},
+		{name: "missing id", cursor: encode("created_at,desc,1714552200000000000")},
+		{name: "extra part", cursor: encode("created_at,desc,1,2,3")},
+		{name: "time not a number", cursor: encode("created_at,desc,yesterday,2")},
+		{name: "negative id", cursor: encode("created_at,desc,1,-2")},
+		{name: "empty parts", cursor: encode(",,,")},
+		{name: "other sort field", cursor: encodeCursor(ListQuery{SortBy: SortByUpdatedAt, Desc: true}, time.Now(), 2)},
+		{name: "other order", cursor: encodeCursor(ListQuery{SortBy: SortByCreatedAt}, time.Now(), 2)},
+		{name: "cursor without sort", cursor: encode("1,2")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := decodeCursor(tt.cursor, query); err == nil {
+				t.Errorf("decodeCursor(%q) succeeded, want an error", tt.cursor)
+			}
+		})
+	}
+}
+
+func TestListQueryNormalize(t *testing.T) {
+	tests := []struct {
+		name  string
+		query ListQuery
+		want  ListQuery
+	}{
+		{
+			name:  "defaults",
+			query: ListQuery{},
+			want:  ListQuery{Page: 1, Size: DefaultPageSize, SortBy: SortByCreatedAt},
+		},
+		{
+			name:  "size is capped",
+			query: ListQuery{Page: 3, Size: 1000, SortBy: SortByUpdatedAt, Desc: true},
+			want:  ListQuery{Page: 3, Size: MaxPageSize, SortBy: SortByUpdatedAt, Desc: true},
+		},
+		{
+			name:  "unknown sort field",
+			query: ListQuery{Page: -1, Size: 20, SortBy: "password"},
+			want:  ListQuery{Page: 1, Size: 20, SortBy: SortByCreatedAt},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := tt.query
+			query.normalize()
+			if query != tt.want {
+				t.Errorf("normalize() = %+v, want %+v", query, tt.want)
+			}
+		})
+	}
+}
+
+func TestLikeEscaper(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "plain", text: "客服", want: "客服"},
+		{name: "percent", text: "100%", want: "100!%"},
+		{name: "underscore", text: "robot_1", want: "robot!_1"},
+		{name: "escape character", text: "hi!", want: "hi!!"},
+		{name: "backslash is literal", text: `a\b`, want: `a\b`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := likeEscaper.Replace(tt.text); got != tt.want {
+				t.Errorf("likeEscaper.Replace(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
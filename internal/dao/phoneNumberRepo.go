@@ -0,0 +1,81 @@
+package dao
+
+import (
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"miniRustpbxgo/internal/model"
+	"time"
+)
+
+type PhoneNumberRepo struct {
+	db *gorm.DB
+}
+
+func NewPhoneNumberRepo(db *gorm.DB) *PhoneNumberRepo {
+	return &PhoneNumberRepo{db: db}
+}
+
+// CreatePhoneNumber 创建呼入号码配置
+func (r *PhoneNumberRepo) CreatePhoneNumber(phoneNumber *model.PhoneNumber) (*model.PhoneNumber, error) {
+	now := time.Now()
+	phoneNumber.CreatedAt = now
+	phoneNumber.UpdatedAt = now
+
+	result := r.db.Create(phoneNumber)
+	if result.Error != nil {
+		logrus.Error("CreatePhoneNumber failed: ", result.Error)
+		return nil, result.Error
+	}
+	return phoneNumber, nil
+}
+
+// GetPhoneNumberByID 根据ID查询呼入号码配置
+func (r *PhoneNumberRepo) GetPhoneNumberByID(id uint) (*model.PhoneNumber, error) {
+	var phoneNumber model.PhoneNumber
+	result := r.db.Where("id = ?", id).First(&phoneNumber)
+	if result.Error != nil {
+		logrus.Error("GetPhoneNumberByID failed: ", result.Error)
+		return nil, result.Error
+	}
+	return &phoneNumber, nil
+}
+
+// FindPhoneNumber 按候选号码的顺序查询第一个已配置的号码，都未配置时返回nil
+func (r *PhoneNumberRepo) FindPhoneNumber(numbers []string) (*model.PhoneNumber, error) {
+	var phoneNumbers []model.PhoneNumber
+	if err := r.db.Where("number IN ?", numbers).Find(&phoneNumbers).Error; err != nil {
+		logrus.Error("FindPhoneNumber failed: ", err)
+		return nil, err
+	}
+	for _, number := range numbers {
+		for i := range phoneNumbers {
+			if phoneNumbers[i].Number == number {
+				return &phoneNumbers[i], nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// ListPhoneNumbers 按查询参数分页查询指定用户的呼入号码，name按号码搜索
+func (r *PhoneNumberRepo) ListPhoneNumbers(userID uint, query ListQuery) (*ListResult[model.PhoneNumber], error) {
+	db := r.db.Model(&model.PhoneNumber{}).Where("user_id = ?", userID)
+	result, err := listPage(db, query, []string{"number"}, func(phoneNumber *model.PhoneNumber) (time.Time, uint) {
+		return sortTime(query, phoneNumber.CreatedAt, phoneNumber.UpdatedAt), phoneNumber.ID
+	})
+	if err != nil {
+		logrus.Error("ListPhoneNumbers failed: ", err)
+		return nil, err
+	}
+	return result, nil
+}
+
+// UpdatePhoneNumber 全量更新呼入号码配置
+func (r *PhoneNumberRepo) UpdatePhoneNumber(phoneNumber *model.PhoneNumber) error {
+	phoneNumber.UpdatedAt = time.Now()
+	result := r.db.Save(phoneNumber)
+	if result.Error != nil {
+		logrus.Error("UpdatePhoneNumber failed: ", result.Error)
+	}
+	return result.Error
+}
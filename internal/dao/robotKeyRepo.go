@@ -80,6 +80,19 @@ func (r *RobotKeyRepo) ListRobotKeysByUserID(userID uint, page, pageSize int) ([
 	return robotKeys, total, nil
 }
 
+// ListRobotKeys 按查询参数分页查询指定用户的RobotKey列表，支持按名称搜索
+func (r *RobotKeyRepo) ListRobotKeys(userID uint, query ListQuery) (*ListResult[model.RobotKey], error) {
+	db := r.db.Model(&model.RobotKey{}).Where("user_id = ?", userID)
+	result, err := listPage(db, query, []string{"name"}, func(robotKey *model.RobotKey) (time.Time, uint) {
+		return sortTime(query, robotKey.CreatedAt, robotKey.UpdatedAt), robotKey.ID
+	})
+	if err != nil {
+		logrus.Error("ListRobotKeys failed: ", err)
+		return nil, err
+	}
+	return result, nil
+}
+
 // UpdateRobotKey 全量更新 RobotKey 记录（需传入完整结构体）
 func (r *RobotKeyRepo) UpdateRobotKey(robotKey *model.RobotKey) error {
 	// 更新时间戳
@@ -1,6 +1,7 @@
 package dao
 
 import (
+	"errors"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 	"miniRustpbxgo/internal/model"
@@ -51,6 +52,20 @@ func (r *RobotRepo) GetRobotByUsrID(id uint) (*model.Robot, error) {
 	return &robot, nil
 }
 
+// GetRobotByUserIDAndName 查询指定用户下同名的Robot，不存在时返回nil
+func (r *RobotRepo) GetRobotByUserIDAndName(userID uint, name string) (*model.Robot, error) {
+	var robot model.Robot
+	result := r.db.Where("user_id = ? AND name = ?", userID, name).First(&robot)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		logrus.Error("GetRobotByUserIDAndName Failed: ", result.Error)
+		return nil, result.Error
+	}
+	return &robot, nil
+}
+
 // ListRobotsByUserID 分页查询指定用户的Robot列表
 func (r *RobotRepo) ListRobotsByUserID(userID uint, page, pageSize int) ([]model.Robot, int64, error) {
 	var (
@@ -79,6 +94,19 @@ func (r *RobotRepo) ListRobotsByUserID(userID uint, page, pageSize int) ([]model
 	return robots, total, nil
 }
 
+// ListRobots 按查询参数分页查询指定用户的Robot列表，支持按名称搜索
+func (r *RobotRepo) ListRobots(userID uint, query ListQuery) (*ListResult[model.Robot], error) {
+	db := r.db.Model(&model.Robot{}).Where("user_id = ?", userID)
+	result, err := listPage(db, query, []string{"name"}, func(robot *model.Robot) (time.Time, uint) {
+		return sortTime(query, robot.CreatedAt, robot.UpdatedAt), robot.ID
+	})
+	if err != nil {
+		logrus.Error("ListRobots failed: ", err)
+		return nil, err
+	}
+	return result, nil
+}
+
 // UpdateRobotPartial 部分更新Robot记录（只更新提供的字段）
 func (r *RobotRepo) UpdateRobotPartial(id uint, updates map[string]interface{}) error {
 	updates["updated_at"] = time.Now()
@@ -115,7 +115,7 @@ func (r *UserRepo) IsExist(username string, email string) (bool, error) {
 	}
 
 	if err != nil {
-		logrus.Error("username isExist = [%v]", err)
+		logrus.Errorf("username isExist = [%v]", err)
 		return false, err
 	}
 
@@ -125,7 +125,7 @@ func (r *UserRepo) IsExist(username string, email string) (bool, error) {
 	}
 
 	if err != nil {
-		logrus.Error("email isExist = [%v]", err)
+		logrus.Errorf("email isExist = [%v]", err)
 		return false, err
 	}
 	return true, nil
@@ -0,0 +1,313 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/sirupsen/logrus"
+)
+
+// Context strategies decide what happens to old turns once the history exceeds the budget
+const (
+	// ContextStrategyTruncate drops the oldest turns
+	ContextStrategyTruncate = "truncate"
+	// ContextStrategySummarize folds the oldest turns into a rolling summary generated by the LLM
+	ContextStrategySummarize = "summarize"
+)
+
+// DefaultContextMaxTokens is the history budget used when the robot does not set one
+const DefaultContextMaxTokens = 8000
+
+const (
+	// compactTargetRatio shrinks the history below the budget so compaction does not run every turn
+	compactTargetRatio = 0.7
+	// summaryTimeout bounds the secondary LLM call that updates the summary
+	summaryTimeout = 15 * time.Second
+	// summaryPrefix marks the system message holding the rolling summary
+	summaryPrefix = "Summary of the earlier conversation:\n"
+	// messageOverheadTokens approximates the per message framing tokens of chat models
+	messageOverheadTokens = 4
+)
+
+const summaryInstruction = "You maintain a running summary of a phone call between a caller and an AI voice assistant. " +
+	"Merge the previous summary with the new part of the conversation into one concise summary written in the language of the conversation. " +
+	"Keep names, numbers, dates, decisions, commitments and open questions. Reply with the summary only."
+
+// ContextPolicy controls how the conversation history is kept within the model context
+type ContextPolicy struct {
+	// MaxTokens is the history budget, zero means DefaultContextMaxTokens
+	MaxTokens int
+	// Strategy is ContextStrategyTruncate or ContextStrategySummarize, empty means truncate
+	Strategy string
+}
+
+func (p ContextPolicy) maxTokens() int {
+	if p.MaxTokens <= 0 {
+		return DefaultContextMaxTokens
+	}
+	return p.MaxTokens
+}
+
+// SetContextPolicy replaces the history budget and strategy
+func (h *LLMHandler) SetContextPolicy(policy ContextPolicy) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.contextPolicy = policy
+}
+
+// EstimateTokens approximates the token count of text without a tokenizer:
+// one token per CJK character and one per four other characters
+func EstimateTokens(text string) int {
+	tokens, others := 0, 0
+	for _, r := range text {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			tokens++
+		} else {
+			others++
+		}
+	}
+	return tokens + (others+3)/4
+}
+
+// EstimateMessagesTokens approximates the prompt tokens of messages
+func EstimateMessagesTokens(messages []openai.ChatCompletionMessage) int {
+	total := 0
+	for _, message := range messages {
+		total += estimateMessageTokens(message)
+	}
+	return total
+}
+
+func estimateMessageTokens(message openai.ChatCompletionMessage) int {
+	tokens := messageOverheadTokens + EstimateTokens(message.Content) + EstimateTokens(message.Name)
+	for _, call := range message.ToolCalls {
+		tokens += EstimateTokens(call.Function.Name) + EstimateTokens(call.Function.Arguments)
+	}
+	return tokens
+}
+
+// splitTurns splits history into the leading system messages and the turns after them,
+// a turn starts at a user message, a greeting said before the first user message is a turn of its own
+func splitTurns(messages []openai.ChatCompletionMessage) (head []openai.ChatCompletionMessage, turns [][]openai.ChatCompletionMessage) {
+	i := 0
+	for i < len(messages) && messages[i].Role == openai.ChatMessageRoleSystem {
+		i++
+	}
+	head = messages[:i]
+	for ; i < len(messages); i++ {
+		if messages[i].Role == openai.ChatMessageRoleUser || len(turns) == 0 {
+			turns = append(turns, nil)
+		}
+		turns[len(turns)-1] = append(turns[len(turns)-1], messages[i])
+	}
+	return head, turns
+}
+
+// pinnedMessages keeps the tool calls and their results of a turn, the spoken text is dropped
+func pinnedMessages(turn []openai.ChatCompletionMessage) []openai.ChatCompletionMessage {
+	var pinned []openai.ChatCompletionMessage
+	for _, message := range turn {
+		switch {
+		case message.Role == openai.ChatMessageRoleAssistant && len(message.ToolCalls) > 0:
+			message.Content = ""
+			pinned = append(pinned, message)
+		case message.Role == openai.ChatMessageRoleTool:
+			pinned = append(pinned, message)
+		}
+	}
+	return pinned
+}
+
+// trimMessages drops the oldest turns until messages fit in target tokens. The system
+// messages and the latest turn are always kept, tool results are kept as long as possible.
+// dropped returns the removed conversation for summarization.
+func trimMessages(messages []openai.ChatCompletionMessage, target int) (kept, dropped []openai.ChatCompletionMessage) {
+	total := EstimateMessagesTokens(messages)
+	if total <= target {
+		return messages, nil
+	}
+	head, turns := splitTurns(messages)
+	for i := 0; i < len(turns)-1 && total > target; i++ {
+		pinned := pinnedMessages(turns[i])
+		total -= EstimateMessagesTokens(turns[i]) - EstimateMessagesTokens(pinned)
+		dropped = append(dropped, turns[i]...)
+		turns[i] = pinned
+	}
+	// Still over budget with only tool results left, drop them oldest first
+	for i := 0; i < len(turns)-1 && total > target; i++ {
+		total -= EstimateMessagesTokens(turns[i])
+		turns[i] = nil
+	}
+	kept = append([]openai.ChatCompletionMessage{}, head...)
+	for _, turn := range turns {
+		kept = append(kept, turn...)
+	}
+	return kept, dropped
+}
+
+// fitContext trims messages of a request that would exceed the budget, used before every request
+// The caller must hold h.mutex.
+func (h *LLMHandler) fitContext(messages []openai.ChatCompletionMessage) []openai.ChatCompletionMessage {
+	kept, dropped := trimMessages(messages, h.contextPolicy.maxTokens())
+	if len(dropped) > 0 {
+		h.logger.WithFields(logrus.Fields{
+			"dropped": len(dropped),
+			"tokens":  EstimateMessagesTokens(kept),
+		}).Warn("Request exceeds context budget, oldest turns dropped")
+	}
+	return kept
+}
+
+// compactHistory shrinks h.messages once it exceeds the budget by dropping the oldest turns.
+// With the summarize strategy the dropped turns are folded into the rolling summary by a
+// goroutine, so the LLM call does not block the turn. The caller must hold h.mutex.
+func (h *LLMHandler) compactHistory(model string) {
+	maxTokens := h.contextPolicy.maxTokens()
+	before := EstimateMessagesTokens(h.messages)
+	if before <= maxTokens {
+		return
+	}
+	kept, dropped := trimMessages(h.messages, int(float64(maxTokens)*compactTargetRatio))
+	h.messages = kept
+	h.logger.WithFields(logrus.Fields{
+		"strategy":     h.contextPolicy.Strategy,
+		"tokensBefore": before,
+		"tokensAfter":  EstimateMessagesTokens(h.messages),
+		"dropped":      len(dropped),
+	}).Info("Conversation history compacted")
+	if h.contextPolicy.Strategy != ContextStrategySummarize || len(dropped) == 0 {
+		return
+	}
+	h.pendingSummary = append(h.pendingSummary, dropped...)
+	if !h.summarizing {
+		h.summarizing = true
+		go h.runSummary(model, h.historyEpoch)
+	}
+}
+
+// runSummary folds the pending dropped turns into the summary without holding h.mutex
+// during the LLM call, then swaps the new summary in. Turns dropped while a summary is
+// being generated are folded in by the next round.
+func (h *LLMHandler) runSummary(model string, epoch int) {
+	for {
+		h.mutex.Lock()
+		if h.historyEpoch != epoch {
+			h.mutex.Unlock()
+			return
+		}
+		if len(h.pendingSummary) == 0 {
+			h.summarizing = false
+			h.mutex.Unlock()
+			return
+		}
+		dropped := h.pendingSummary
+		h.pendingSummary = nil
+		previous := h.currentSummary()
+		h.mutex.Unlock()
+
+		summary, err := h.summarize(model, previous, dropped)
+
+		h.mutex.Lock()
+		if h.historyEpoch != epoch {
+			h.mutex.Unlock()
+			return
+		}
+		if err != nil {
+			h.logger.WithError(err).Error("Failed to summarize history, oldest turns dropped")
+		} else if summary != "" {
+			h.messages = withSummary(h.messages, summary)
+		}
+		h.mutex.Unlock()
+	}
+}
+
+// currentSummary returns the rolling summary kept after the system prompt
+// The caller must hold h.mutex.
+func (h *LLMHandler) currentSummary() string {
+	for _, message := range h.messages {
+		if message.Role != openai.ChatMessageRoleSystem {
+			break
+		}
+		if strings.HasPrefix(message.Content, summaryPrefix) {
+			return strings.TrimPrefix(message.Content, summaryPrefix)
+		}
+	}
+	return ""
+}
+
+// withSummary places the summary right after the system prompt, replacing the previous one
+func withSummary(messages []openai.ChatCompletionMessage, summary string) []openai.ChatCompletionMessage {
+	summaryMessage := openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleSystem,
+		Content: summaryPrefix + summary,
+	}
+	for i, message := range messages {
+		if message.Role != openai.ChatMessageRoleSystem {
+			break
+		}
+		if strings.HasPrefix(message.Content, summaryPrefix) {
+			messages[i] = summaryMessage
+			return messages
+		}
+	}
+	index := 0
+	if len(messages) > 0 && messages[0].Role == openai.ChatMessageRoleSystem {
+		index = 1
+	}
+	result := append([]openai.ChatCompletionMessage{}, messages[:index]...)
+	result = append(result, summaryMessage)
+	return append(result, messages[index:]...)
+}
+
+// summarize asks the LLM to merge the dropped turns into the previous summary
+func (h *LLMHandler) summarize(model, previous string, dropped []openai.ChatCompletionMessage) (string, error) {
+	var transcript strings.Builder
+	for _, message := range dropped {
+		switch message.Role {
+		case openai.ChatMessageRoleUser:
+			transcript.WriteString("Caller: " + message.Content + "\n")
+		case openai.ChatMessageRoleAssistant:
+			if message.Content != "" {
+				transcript.WriteString("Assistant: " + message.Content + "\n")
+			}
+		}
+	}
+	if transcript.Len() == 0 {
+		return previous, nil
+	}
+	if previous == "" {
+		previous = "(none)"
+	}
+	if model == "" {
+		model = h.provider.DefaultModel()
+	}
+	ctx, cancel := context.WithTimeout(h.ctx, summaryTimeout)
+	defer cancel()
+	response, err := h.provider.Chat(ctx, ChatRequest{
+		Model:       model,
+		Temperature: 0.2,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: summaryInstruction},
+			{Role: openai.ChatMessageRoleUser, Content: fmt.Sprintf("Previous summary:\n%s\n\nNew conversation:\n%s", previous, transcript.String())},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("error summarizing history: %w", err)
+	}
+	summary := strings.TrimSpace(response.Message.Content)
+	if summary == "" {
+		return "", errors.New("error summarizing history: empty summary")
+	}
+	fields := logrus.Fields{"summaryTokens": EstimateTokens(summary)}
+	if response.Usage != nil {
+		fields["promptTokens"] = response.Usage.PromptTokens
+		fields["completionTokens"] = response.Usage.CompletionTokens
+	}
+	h.logger.WithFields(fields).Info("History summary updated")
+	return summary, nil
+}
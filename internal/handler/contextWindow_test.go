@@ -0,0 +1,268 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/sirupsen/logrus"
+)
+
+// Every message below is 5 tokens: 4 of framing and 1 for its four ASCII characters
+func system(content string) openai.ChatCompletionMessage {
+	return openai.ChatCompletionMessage{Role: openai.ChatMessageRoleSystem, Content: content}
+}
+
+func user(content string) openai.ChatCompletionMessage {
+	return openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: content}
+}
+
+func assistant(content string) openai.ChatCompletionMessage {
+	return openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: content}
+}
+
+// toolCall is 7 tokens with content and 6 once pinned
+func toolCall(content string) openai.ChatCompletionMessage {
+	return openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleAssistant,
+		Content: content,
+		ToolCalls: []openai.ToolCall{{
+			ID:       "call",
+			Type:     openai.ToolTypeFunction,
+			Function: openai.FunctionCall{Name: "look", Arguments: "{}"},
+		}},
+	}
+}
+
+func toolResult(content string) openai.ChatCompletionMessage {
+	return openai.ChatCompletionMessage{Role: openai.ChatMessageRoleTool, Content: content, ToolCallID: "call"}
+}
+
+func TestTrimMessages(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages []openai.ChatCompletionMessage
+		target   int
+		want     []openai.ChatCompletionMessage
+		// dropped is the conversation handed to the summary
+		dropped []openai.ChatCompletionMessage
+	}{
+		{
+			name:     "within budget",
+			messages: []openai.ChatCompletionMessage{system("rule"), user("q111"), assistant("a111")},
+			target:   15,
+			want:     []openai.ChatCompletionMessage{system("rule"), user("q111"), assistant("a111")},
+		},
+		{
+			name:     "oldest turn dropped",
+			messages: []openai.ChatCompletionMessage{system("rule"), user("q111"), assistant("a111"), user("q222"), assistant("a222"), user("q333")},
+			target:   25,
+			want:     []openai.ChatCompletionMessage{system("rule"), user("q222"), assistant("a222"), user("q333")},
+			dropped:  []openai.ChatCompletionMessage{user("q111"), assistant("a111")},
+		},
+		{
+			name:     "system prompt and latest turn are kept",
+			messages: []openai.ChatCompletionMessage{system("rule"), user("q111"), assistant("a111"), user("q222"), assistant("a222"), user("q333")},
+			target:   1,
+			want:     []openai.ChatCompletionMessage{system("rule"), user("q333")},
+			dropped:  []openai.ChatCompletionMessage{user("q111"), assistant("a111"), user("q222"), assistant("a222")},
+		},
+		{
+			name:     "greeting is a turn of its own",
+			messages: []openai.ChatCompletionMessage{system("rule"), assistant("helo"), user("q111"), assistant("a111"), user("q222")},
+			target:   20,
+			want:     []openai.ChatCompletionMessage{system("rule"), user("q111"), assistant("a111"), user("q222")},
+			dropped:  []openai.ChatCompletionMessage{assistant("helo")},
+		},
+		{
+			name:     "tool results are pinned",
+			messages: []openai.ChatCompletionMessage{system("rule"), user("q111"), toolCall("call"), toolResult("r111"), assistant("a111"), user("q222")},
+			target:   25,
+			want:     []openai.ChatCompletionMessage{system("rule"), toolCall(""), toolResult("r111"), user("q222")},
+			dropped:  []openai.ChatCompletionMessage{user("q111"), toolCall("call"), toolResult("r111"), assistant("a111")},
+		},
+		{
+			name:     "tool results dropped when still over budget",
+			messages: []openai.ChatCompletionMessage{system("rule"), user("q111"), toolCall("call"), toolResult("r111"), assistant("a111"), user("q222")},
+			target:   12,
+			want:     []openai.ChatCompletionMessage{system("rule"), user("q222")},
+			dropped:  []openai.ChatCompletionMessage{user("q111"), toolCall("call"), toolResult("r111"), assistant("a111")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept, dropped := trimMessages(tt.messages, tt.target)
+			if !reflect.DeepEqual(kept, tt.want) {
+				t.Errorf("kept = %+v, want %+v", kept, tt.want)
+			}
+			if !reflect.DeepEqual(dropped, tt.dropped) {
+				t.Errorf("dropped = %+v, want %+v", dropped, tt.dropped)
+			}
+		})
+	}
+}
+
+// summaryProvider answers summary requests, a non nil hold blocks them until it is closed
+type summaryProvider struct {
+	mutex    sync.Mutex
+	summary  string
+	err      error
+	hold     chan struct{}
+	requests []ChatRequest
+	started  chan struct{}
+}
+
+func (p *summaryProvider) Name() string { return "fake" }
+
+func (p *summaryProvider) DefaultModel() string { return "fake" }
+
+func (p *summaryProvider) ChatStream(ctx context.Context, request ChatRequest) (ChatStream, error) {
+	return nil, io.ErrUnexpectedEOF
+}
+
+func (p *summaryProvider) Chat(ctx context.Context, request ChatRequest) (ChatResponse, error) {
+	p.mutex.Lock()
+	p.requests = append(p.requests, request)
+	p.mutex.Unlock()
+	p.started <- struct{}{}
+	if p.hold != nil {
+		<-p.hold
+	}
+	if p.err != nil {
+		return ChatResponse{}, p.err
+	}
+	return ChatResponse{Message: assistant(p.summary)}, nil
+}
+
+func (p *summaryProvider) Requests() []ChatRequest {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return append([]ChatRequest(nil), p.requests...)
+}
+
+func TestCompactHistory(t *testing.T) {
+	// 40 tokens, a budget of 30 compacts it to 21 by dropping the first two turns
+	history := []openai.ChatCompletionMessage{
+		system("rule"), user("q111"), assistant("a111"), user("q222"), assistant("a222"), user("q333"), assistant("a333"), user("q444"),
+	}
+	tests := []struct {
+		name     string
+		strategy string
+		// previous is a summary already in the history
+		previous string
+		summary  string
+		err      error
+		// reset starts a new conversation while the summary is generated
+		reset bool
+		want  []openai.ChatCompletionMessage
+		// transcript is expected in the summary request
+		transcript string
+	}{
+		{
+			name:     "truncate drops the oldest turns",
+			strategy: ContextStrategyTruncate,
+			want:     []openai.ChatCompletionMessage{system("rule"), user("q333"), assistant("a333"), user("q444")},
+		},
+		{
+			name:       "summarize folds dropped turns into a summary",
+			strategy:   ContextStrategySummarize,
+			summary:    "caller asked twice",
+			want:       []openai.ChatCompletionMessage{system("rule"), system(summaryPrefix + "caller asked twice"), user("q333"), assistant("a333"), user("q444")},
+			transcript: "Caller: q111\nAssistant: a111\nCaller: q222\nAssistant: a222\n",
+		},
+		{
+			name:     "summarize replaces the previous summary",
+			strategy: ContextStrategySummarize,
+			previous: "old",
+			summary:  "new",
+			// the previous summary takes 14 tokens of the budget, only the latest turn fits
+			want:       []openai.ChatCompletionMessage{system("rule"), system(summaryPrefix + "new"), user("q444")},
+			transcript: "Previous summary:\nold\n\nNew conversation:\nCaller: q111\nAssistant: a111\nCaller: q222\nAssistant: a222\nCaller: q333\nAssistant: a333\n",
+		},
+		{
+			name:     "failed summary keeps the trimmed history",
+			strategy: ContextStrategySummarize,
+			err:      errors.New("timeout"),
+			want:     []openai.ChatCompletionMessage{system("rule"), user("q333"), assistant("a333"), user("q444")},
+		},
+		{
+			name:     "summary is not applied after reset",
+			strategy: ContextStrategySummarize,
+			summary:  "caller asked twice",
+			reset:    true,
+			want:     []openai.ChatCompletionMessage{system("rule")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &summaryProvider{summary: tt.summary, err: tt.err, hold: make(chan struct{}), started: make(chan struct{}, 1)}
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+			h := NewLLMHandlerWithProvider(context.Background(), provider, "rule", logger)
+			h.SetContextPolicy(ContextPolicy{MaxTokens: 30, Strategy: tt.strategy})
+			messages := append([]openai.ChatCompletionMessage(nil), history...)
+			if tt.previous != "" {
+				messages = withSummary(messages, tt.previous)
+			}
+
+			h.mutex.Lock()
+			h.messages = messages
+			h.compactHistory("")
+			h.mutex.Unlock()
+
+			if tt.strategy == ContextStrategySummarize {
+				select {
+				case <-provider.started:
+				case <-time.After(2 * time.Second):
+					t.Fatal("timed out waiting for the summary request")
+				}
+				if tt.reset {
+					h.Reset()
+				}
+			}
+			close(provider.hold)
+			waitSummary(t, h)
+
+			h.mutex.Lock()
+			got := h.messages
+			h.mutex.Unlock()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("messages = %+v, want %+v", got, tt.want)
+			}
+			if requests := provider.Requests(); tt.transcript != "" {
+				if len(requests) != 1 {
+					t.Fatalf("summary requests = %d, want 1", len(requests))
+				}
+				if content := requests[0].Messages[1].Content; !strings.Contains(content, tt.transcript) {
+					t.Errorf("summary request = %q, want it to contain %q", content, tt.transcript)
+				}
+			}
+		})
+	}
+}
+
+// waitSummary waits until no summary goroutine is running
+func waitSummary(t *testing.T, h *LLMHandler) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		h.mutex.Lock()
+		summarizing := h.summarizing
+		h.mutex.Unlock()
+		if !summarizing {
+			// Reset clears the flag before the goroutine sees the new epoch
+			time.Sleep(10 * time.Millisecond)
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the summary")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,298 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// failoverAttempts is how many times one endpoint is tried before failing over
+	failoverAttempts = 2
+	// failoverBackoff is the wait before the first retry, doubled on every retry
+	failoverBackoff = 300 * time.Millisecond
+	// firstTokenTimeout bounds the wait for the first delta of a stream
+	firstTokenTimeout = 10 * time.Second
+	// breakerThreshold consecutive failures open the circuit of an endpoint
+	breakerThreshold = 3
+	// breakerCooldown is how long an open circuit skips the endpoint before a trial request
+	breakerCooldown = 30 * time.Second
+)
+
+// ErrAllProvidersFailed is returned when every endpoint failed or is circuit broken
+var ErrAllProvidersFailed = errors.New("all llm providers failed")
+
+// HTTPStatusError is returned by providers for a non-2xx response
+type HTTPStatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("status %d: %s", e.StatusCode, e.Message)
+}
+
+// FailoverEndpoint is one entry of the ordered endpoint list
+type FailoverEndpoint struct {
+	Provider LLMProvider
+	// Model used on this endpoint, empty means the provider's default
+	Model string
+	// BreakerKey identifies the endpoint and credentials across calls, so one caller's
+	// bad key does not open the circuit for others using the same url
+	BreakerKey string
+}
+
+// FailoverProvider tries its endpoints in order. Each endpoint is retried with backoff
+// until the first token arrives, endpoints failing repeatedly are skipped for a while.
+type FailoverProvider struct {
+	endpoints []FailoverEndpoint
+	logger    *logrus.Logger
+}
+
+// NewFailoverProvider creates a provider over endpoints in priority order
+func NewFailoverProvider(endpoints []FailoverEndpoint, logger *logrus.Logger) *FailoverProvider {
+	for i := range endpoints {
+		if endpoints[i].Model == "" {
+			endpoints[i].Model = endpoints[i].Provider.DefaultModel()
+		}
+	}
+	return &FailoverProvider{endpoints: endpoints, logger: logger}
+}
+
+func (p *FailoverProvider) Name() string {
+	if len(p.endpoints) == 0 {
+		return ""
+	}
+	return p.endpoints[0].Provider.Name()
+}
+
+func (p *FailoverProvider) DefaultModel() string {
+	if len(p.endpoints) == 0 {
+		return ""
+	}
+	return p.endpoints[0].Model
+}
+
+// ChatStream returns the stream of the first endpoint that produces a first delta.
+// request.Model is replaced by the model of each endpoint.
+func (p *FailoverProvider) ChatStream(ctx context.Context, request ChatRequest) (ChatStream, error) {
+	var stream ChatStream
+	err := p.try(ctx, func(endpoint FailoverEndpoint) error {
+		request.Model = endpoint.Model
+		opened, err := openFirstToken(ctx, endpoint.Provider, request)
+		if err != nil {
+			return err
+		}
+		stream = opened
+		return nil
+	})
+	return stream, err
+}
+
+func (p *FailoverProvider) Chat(ctx context.Context, request ChatRequest) (ChatResponse, error) {
+	var response ChatResponse
+	err := p.try(ctx, func(endpoint FailoverEndpoint) error {
+		request.Model = endpoint.Model
+		result, err := endpoint.Provider.Chat(ctx, request)
+		if err != nil {
+			return err
+		}
+		response = result
+		return nil
+	})
+	return response, err
+}
+
+// try runs call on each endpoint in order with retries until one succeeds
+func (p *FailoverProvider) try(ctx context.Context, call func(endpoint FailoverEndpoint) error) error {
+	var lastErr error
+	for index, endpoint := range p.endpoints {
+		breaker := breakerFor(endpoint.BreakerKey)
+		if !breaker.allow() {
+			p.logger.WithField("endpoint", index).Warn("LLM endpoint circuit open, skipped")
+			continue
+		}
+		backoff := failoverBackoff
+		retryable := false
+		for attempt := 1; attempt <= failoverAttempts; attempt++ {
+			err := call(endpoint)
+			if err == nil {
+				breaker.success()
+				return nil
+			}
+			// The caller cancelled, e.g. barge-in, this is not the endpoint's fault
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			lastErr = err
+			p.logger.WithError(err).WithFields(logrus.Fields{
+				"endpoint": index,
+				"provider": endpoint.Provider.Name(),
+				"attempt":  attempt,
+			}).Warn("LLM endpoint failed")
+			retryable = isRetryable(err)
+			if !retryable {
+				break
+			}
+			if attempt < failoverAttempts {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(backoff):
+				}
+				backoff *= 2
+			}
+		}
+		// A wrong key or a rejected request says nothing about the endpoint's health,
+		// only outages count towards opening the circuit
+		if retryable {
+			breaker.failure()
+		}
+	}
+	if lastErr == nil {
+		return ErrAllProvidersFailed
+	}
+	return fmt.Errorf("%w: %w", ErrAllProvidersFailed, lastErr)
+}
+
+// isRetryable reports whether an error is worth retrying: rate limits, server errors,
+// timeouts and network failures. Other client errors fail over without retry.
+func isRetryable(err error) bool {
+	statusCode := 0
+	var apiError *openai.APIError
+	var requestError *openai.RequestError
+	var statusError *HTTPStatusError
+	switch {
+	case errors.As(err, &apiError):
+		statusCode = apiError.HTTPStatusCode
+	case errors.As(err, &requestError):
+		statusCode = requestError.HTTPStatusCode
+	case errors.As(err, &statusError):
+		statusCode = statusError.StatusCode
+	}
+	if statusCode != 0 {
+		return statusCode == http.StatusTooManyRequests || statusCode == http.StatusRequestTimeout || statusCode >= 500
+	}
+	var netError net.Error
+	return errors.As(err, &netError) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// openFirstToken opens a stream and waits for its first delta within firstTokenTimeout
+func openFirstToken(ctx context.Context, provider LLMProvider, request ChatRequest) (ChatStream, error) {
+	streamCtx, cancel := context.WithCancel(ctx)
+	timedOut := false
+	var timeoutMutex sync.Mutex
+	timer := time.AfterFunc(firstTokenTimeout, func() {
+		timeoutMutex.Lock()
+		timedOut = true
+		timeoutMutex.Unlock()
+		cancel()
+	})
+	fail := func(err error) (ChatStream, error) {
+		timer.Stop()
+		cancel()
+		timeoutMutex.Lock()
+		defer timeoutMutex.Unlock()
+		if timedOut && ctx.Err() == nil {
+			return nil, fmt.Errorf("no first token within %s: %w", firstTokenTimeout, context.DeadlineExceeded)
+		}
+		return nil, err
+	}
+
+	stream, err := provider.ChatStream(streamCtx, request)
+	if err != nil {
+		return fail(err)
+	}
+	first, err := stream.Recv()
+	if err != nil && !errors.Is(err, io.EOF) {
+		stream.Close()
+		return fail(err)
+	}
+	timer.Stop()
+	return &peekedStream{stream: stream, first: &first, firstErr: err, cancel: cancel}, nil
+}
+
+// peekedStream replays the delta read while waiting for the first token
+type peekedStream struct {
+	stream   ChatStream
+	first    *ChatDelta
+	firstErr error
+	cancel   context.CancelFunc
+}
+
+func (s *peekedStream) Recv() (ChatDelta, error) {
+	if s.first != nil {
+		first := *s.first
+		s.first = nil
+		return first, s.firstErr
+	}
+	return s.stream.Recv()
+}
+
+func (s *peekedStream) Close() error {
+	defer s.cancel()
+	return s.stream.Close()
+}
+
+// circuitBreaker skips an endpoint after repeated failures until the cooldown passes
+type circuitBreaker struct {
+	mutex     sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+var (
+	breakersMutex sync.Mutex
+	// breakers are shared by all calls so an endpoint failing for one call is skipped by the next
+	breakers = map[string]*circuitBreaker{}
+)
+
+func breakerFor(key string) *circuitBreaker {
+	breakersMutex.Lock()
+	defer breakersMutex.Unlock()
+	breaker, ok := breakers[key]
+	if !ok {
+		breaker = &circuitBreaker{}
+		breakers[key] = breaker
+	}
+	return breaker
+}
+
+// allow lets a request through when the circuit is closed or the cooldown has passed,
+// after the cooldown one trial request decides whether the circuit closes again
+func (b *circuitBreaker) allow() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.failures < breakerThreshold {
+		return true
+	}
+	if time.Now().Before(b.openUntil) {
+		return false
+	}
+	// Half open: let this request try and keep others out until it reports back
+	b.openUntil = time.Now().Add(breakerCooldown)
+	return true
+}
+
+func (b *circuitBreaker) success() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.failures = 0
+}
+
+func (b *circuitBreaker) failure() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.failures++
+	if b.failures >= breakerThreshold {
+		b.openUntil = time.Now().Add(breakerCooldown)
+	}
+}
@@ -0,0 +1,355 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	errUnavailable = &HTTPStatusError{StatusCode: http.StatusServiceUnavailable, Message: "overloaded"}
+	errBadRequest  = &HTTPStatusError{StatusCode: http.StatusBadRequest, Message: "bad model"}
+)
+
+// flakyProvider fails its calls with errs in order and answers with its name afterwards
+type flakyProvider struct {
+	mutex sync.Mutex
+	name  string
+	errs  []error
+	// inStream fails the first Recv of a stream instead of opening it
+	inStream bool
+	calls    int
+	models   []string
+}
+
+func (p *flakyProvider) Name() string { return p.name }
+
+func (p *flakyProvider) DefaultModel() string { return p.name + "-model" }
+
+func (p *flakyProvider) next(request ChatRequest) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.models = append(p.models, request.Model)
+	p.calls++
+	if p.calls <= len(p.errs) {
+		return p.errs[p.calls-1]
+	}
+	return nil
+}
+
+func (p *flakyProvider) ChatStream(ctx context.Context, request ChatRequest) (ChatStream, error) {
+	err := p.next(request)
+	if err != nil && !p.inStream {
+		return nil, err
+	}
+	return &flakyStream{deltas: []string{p.name}, err: err}, nil
+}
+
+func (p *flakyProvider) Chat(ctx context.Context, request ChatRequest) (ChatResponse, error) {
+	if err := p.next(request); err != nil {
+		return ChatResponse{}, err
+	}
+	return ChatResponse{Message: openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: p.name}}, nil
+}
+
+type flakyStream struct {
+	deltas []string
+	err    error
+}
+
+func (s *flakyStream) Recv() (ChatDelta, error) {
+	if s.err != nil {
+		return ChatDelta{}, s.err
+	}
+	if len(s.deltas) == 0 {
+		return ChatDelta{}, io.EOF
+	}
+	delta := s.deltas[0]
+	s.deltas = s.deltas[1:]
+	return ChatDelta{Content: delta}, nil
+}
+
+func (s *flakyStream) Close() error { return nil }
+
+func newTestFailover(t *testing.T, providers []*flakyProvider) *FailoverProvider {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	endpoints := make([]FailoverEndpoint, len(providers))
+	for i, provider := range providers {
+		// breakers are shared by key, every test gets its own
+		endpoints[i] = FailoverEndpoint{Provider: provider, BreakerKey: fmt.Sprintf("%s/%d", t.Name(), i)}
+	}
+	return NewFailoverProvider(endpoints, logger)
+}
+
+func TestFailoverProvider(t *testing.T) {
+	tests := []struct {
+		name string
+		// errs are the failures of each endpoint in order
+		errs     [][]error
+		inStream bool
+		// want is the endpoint that answered, empty when all failed
+		want string
+		// calls are the requests each endpoint received
+		calls   []int
+		wantErr error
+	}{
+		{
+			name:  "first endpoint answers",
+			errs:  [][]error{nil, nil},
+			want:  "primary",
+			calls: []int{1, 0},
+		},
+		{
+			name:  "retryable error is retried on the same endpoint",
+			errs:  [][]error{{errUnavailable}, nil},
+			want:  "primary",
+			calls: []int{2, 0},
+		},
+		{
+			name:  "endpoint failing every attempt fails over",
+			errs:  [][]error{{errUnavailable, errUnavailable}, nil},
+			want:  "backup",
+			calls: []int{2, 1},
+		},
+		{
+			name:  "client error fails over without retry",
+			errs:  [][]error{{errBadRequest}, nil},
+			want:  "backup",
+			calls: []int{1, 1},
+		},
+		{
+			name:     "error before the first token fails over",
+			errs:     [][]error{{errBadRequest}, nil},
+			inStream: true,
+			want:     "backup",
+			calls:    []int{1, 1},
+		},
+		{
+			name:    "all endpoints failed",
+			errs:    [][]error{{errBadRequest}, {errBadRequest}},
+			calls:   []int{1, 1},
+			wantErr: ErrAllProvidersFailed,
+		},
+	}
+	for _, tt := range tests {
+		for _, stream := range []bool{false, true} {
+			name := tt.name + "/chat"
+			if stream {
+				name = tt.name + "/stream"
+			} else if tt.inStream {
+				continue
+			}
+			t.Run(name, func(t *testing.T) {
+				providers := []*flakyProvider{
+					{name: "primary", errs: tt.errs[0], inStream: tt.inStream},
+					{name: "backup", errs: tt.errs[1], inStream: tt.inStream},
+				}
+				failover := newTestFailover(t, providers)
+				request := ChatRequest{Model: "ignored"}
+				var got string
+				var err error
+				if stream {
+					var chatStream ChatStream
+					chatStream, err = failover.ChatStream(context.Background(), request)
+					if err == nil {
+						got = readStream(t, chatStream)
+					}
+				} else {
+					var response ChatResponse
+					response, err = failover.Chat(context.Background(), request)
+					got = response.Message.Content
+				}
+				if tt.wantErr != nil {
+					if !errors.Is(err, tt.wantErr) {
+						t.Fatalf("err = %v, want %v", err, tt.wantErr)
+					}
+				} else if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got != tt.want {
+					t.Errorf("answered by %q, want %q", got, tt.want)
+				}
+				for i, provider := range providers {
+					if provider.calls != tt.calls[i] {
+						t.Errorf("%s calls = %d, want %d", provider.name, provider.calls, tt.calls[i])
+					}
+					for _, model := range provider.models {
+						if model != provider.DefaultModel() {
+							t.Errorf("%s got model %q, want %q", provider.name, model, provider.DefaultModel())
+						}
+					}
+				}
+			})
+		}
+	}
+}
+
+func readStream(t *testing.T, stream ChatStream) string {
+	t.Helper()
+	defer stream.Close()
+	var content strings.Builder
+	for {
+		delta, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return content.String()
+		}
+		if err != nil {
+			t.Fatalf("stream error: %v", err)
+		}
+		content.WriteString(delta.Content)
+	}
+}
+
+func TestFailoverProviderCancelled(t *testing.T) {
+	providers := []*flakyProvider{
+		{name: "primary", errs: []error{context.Canceled}},
+		{name: "backup"},
+	}
+	failover := newTestFailover(t, providers)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := failover.Chat(ctx, ChatRequest{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want %v", err, context.Canceled)
+	}
+	if providers[1].calls != 0 {
+		t.Errorf("backup calls = %d, want 0 after the caller cancelled", providers[1].calls)
+	}
+}
+
+func TestFailoverProviderCircuit(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		// primaryCalls are the requests the primary received over breakerThreshold+1 calls
+		primaryCalls int
+	}{
+		{
+			name:         "outages open the circuit",
+			err:          errUnavailable,
+			primaryCalls: breakerThreshold * failoverAttempts,
+		},
+		{
+			name:         "client errors do not open the circuit",
+			err:          errBadRequest,
+			primaryCalls: breakerThreshold + 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var errs []error
+			for i := 0; i < (breakerThreshold+1)*failoverAttempts; i++ {
+				errs = append(errs, tt.err)
+			}
+			providers := []*flakyProvider{{name: "primary", errs: errs}, {name: "backup"}}
+			failover := newTestFailover(t, providers)
+			for i := 0; i < breakerThreshold+1; i++ {
+				response, err := failover.Chat(context.Background(), ChatRequest{})
+				if err != nil || response.Message.Content != "backup" {
+					t.Fatalf("call %d = %q, %v, want the backup to answer", i, response.Message.Content, err)
+				}
+			}
+			if providers[0].calls != tt.primaryCalls {
+				t.Errorf("primary calls = %d, want %d", providers[0].calls, tt.primaryCalls)
+			}
+		})
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	tests := []struct {
+		name string
+		// steps are failure, success, expire (the cooldown passes) and allow,
+		// each allow is checked against the next value of want
+		steps []string
+		want  []bool
+	}{
+		{
+			name:  "closed below the threshold",
+			steps: []string{"failure", "failure", "allow"},
+			want:  []bool{true},
+		},
+		{
+			name:  "opens at the threshold",
+			steps: []string{"failure", "failure", "failure", "allow"},
+			want:  []bool{false},
+		},
+		{
+			name:  "success resets the failures",
+			steps: []string{"failure", "failure", "success", "failure", "allow"},
+			want:  []bool{true},
+		},
+		{
+			name:  "one trial request after the cooldown",
+			steps: []string{"failure", "failure", "failure", "expire", "allow", "allow"},
+			want:  []bool{true, false},
+		},
+		{
+			name:  "successful trial closes the circuit",
+			steps: []string{"failure", "failure", "failure", "expire", "allow", "success", "allow", "allow"},
+			want:  []bool{true, true, true},
+		},
+		{
+			name:  "failed trial opens the circuit again",
+			steps: []string{"failure", "failure", "failure", "expire", "allow", "failure", "allow"},
+			want:  []bool{true, false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			breaker := &circuitBreaker{}
+			var got []bool
+			for _, step := range tt.steps {
+				switch step {
+				case "failure":
+					breaker.failure()
+				case "success":
+					breaker.success()
+				case "expire":
+					breaker.openUntil = time.Now().Add(-time.Second)
+				case "allow":
+					got = append(got, breaker.allow())
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("allow = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "rate limited", err: &HTTPStatusError{StatusCode: http.StatusTooManyRequests}, want: true},
+		{name: "server error", err: errUnavailable, want: true},
+		{name: "request timeout", err: &HTTPStatusError{StatusCode: http.StatusRequestTimeout}, want: true},
+		{name: "bad request", err: errBadRequest, want: false},
+		{name: "unauthorized", err: &openai.APIError{HTTPStatusCode: http.StatusUnauthorized}, want: false},
+		{name: "openai server error", err: &openai.RequestError{HTTPStatusCode: http.StatusBadGateway}, want: true},
+		{name: "wrapped status", err: fmt.Errorf("chat: %w", errUnavailable), want: true},
+		{name: "deadline", err: fmt.Errorf("no first token: %w", context.DeadlineExceeded), want: true},
+		{name: "connection cut", err: io.ErrUnexpectedEOF, want: true},
+		{name: "other error", err: errors.New("invalid tool arguments"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryable(tt.err); got != tt.want {
+				t.Errorf("isRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -3,8 +3,9 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"regexp"
+	"io"
 	"sync"
 
 	"github.com/google/uuid"
@@ -14,26 +15,61 @@ import (
 
 // LLMHandler manages interactions with openai
 type LLMHandler struct {
-	client      *openai.Client
-	systemMsg   string
-	mutex       sync.Mutex
-	logger      *logrus.Logger
-	ctx         context.Context
-	messages    []openai.ChatCompletionMessage
-	hangupChan  chan struct{}
-	interruptCh chan struct{}
+	provider   LLMProvider
+	systemMsg  string
+	mutex      sync.Mutex
+	logger     *logrus.Logger
+	ctx        context.Context
+	messages   []openai.ChatCompletionMessage
+	hangupChan chan struct{}
+	tools      *ToolRegistry
+	// contextPolicy keeps the history within the model context on long calls
+	contextPolicy ContextPolicy
+	// summarizing is set while a goroutine folds dropped turns into the summary,
+	// turns dropped in the meantime wait in pendingSummary
+	summarizing    bool
+	pendingSummary []openai.ChatCompletionMessage
+	// historyEpoch changes on Reset so a late summary is not applied to a new conversation
+	historyEpoch int
+	// streamingTTS forwards content as it arrives instead of waiting for punctuation,
+	// only a word or number cut by the chunk is held back
+	streamingTTS bool
+	// segmenterConfig sets the segment lengths when replies are cut into sentences
+	segmenterConfig SegmenterConfig
+	// toolCallListener is told when a tool starts running, e.g. to mask its latency
+	toolCallListener func(name string)
+
+	// cancelMutex guards cancelTurn, it is separate from mutex which is held for the whole turn
+	cancelMutex sync.Mutex
+	cancelTurn  context.CancelFunc
 }
 
+// TTSCallback receives the text of a reply to synthesize. All segments of one reply share
+// playID, endOfStream is set on the last call of the reply whose segment may be empty.
+// Returning ErrInterrupted means the segment was dropped and the turn should stop.
+type TTSCallback func(segment string, playID string, autoHangup, endOfStream bool) error
+
+// ErrInterrupted is returned when a turn is cancelled by Interrupt, e.g. the caller barged in
+var ErrInterrupted = errors.New("llm generation interrupted")
+
+// maxToolRounds bounds how many times one turn may go back to the LLM with tool results
+const maxToolRounds = 5
+
 // HangupTool ToolCall represents a function call from the LLM
 type HangupTool struct {
 	Reason string `json:"reason"`
 }
 
-// NewLLMHandler creates a new LLM handler
+// NewLLMHandler creates a new LLM handler for an OpenAI-compatible endpoint
 func NewLLMHandler(ctx context.Context, apiKey, endpoint, systemPrompt string, logger *logrus.Logger) *LLMHandler {
-	config := openai.DefaultConfig(apiKey)
-	config.BaseURL = endpoint
-	client := openai.NewClientWithConfig(config)
+	return NewLLMHandlerWithProvider(ctx, NewOpenAIProvider(LLMProviderOpenAI, apiKey, endpoint, defaultQwenModel), systemPrompt, logger)
+}
+
+// NewLLMHandlerWithProvider creates a new LLM handler backed by provider
+func NewLLMHandlerWithProvider(ctx context.Context, provider LLMProvider, systemPrompt string, logger *logrus.Logger) *LLMHandler {
+	// Only hangup is enabled until the caller sets the robot's tools
+	tools := NewToolRegistry()
+	tools.Register(NewHangupTool(nil))
 	// Create system message
 	messages := []openai.ChatCompletionMessage{
 		{
@@ -43,18 +79,18 @@ func NewLLMHandler(ctx context.Context, apiKey, endpoint, systemPrompt string, l
 	}
 
 	return &LLMHandler{
-		client:      client,
-		systemMsg:   systemPrompt,
-		logger:      logger,
-		ctx:         ctx,
-		messages:    messages,
-		hangupChan:  make(chan struct{}),
-		interruptCh: make(chan struct{}, 1),
+		provider:   provider,
+		systemMsg:  systemPrompt,
+		logger:     logger,
+		ctx:        ctx,
+		messages:   messages,
+		hangupChan: make(chan struct{}),
+		tools:      tools,
 	}
 }
 
 // QueryStream processes the LLM response as a stream and sends segments to TTS as they arrive
-func (h *LLMHandler) QueryStream(model, text string, ttsCallback func(segment string, playID string, autoHangup bool) error) (string, error) {
+func (h *LLMHandler) QueryStream(model, text string, ttsCallback TTSCallback) (string, error) {
 	h.mutex.Lock()
 	defer h.mutex.Unlock()
 
@@ -64,121 +100,324 @@ func (h *LLMHandler) QueryStream(model, text string, ttsCallback func(segment st
 		Content: text,
 	})
 
-	// Define the function for hanging up
-	functionDefinition := openai.FunctionDefinition{
-		Name:        "hangup",
-		Description: "End the conversation and hang up the call",
-		Parameters: json.RawMessage(`{
-			"type": "object",
-			"properties": {
-				"reason": {
-					"type": "string",
-					"description": "Reason for hanging up the call"
-				}
-			},
-			"required": []
-		}`),
+	ctx, done := h.beginTurn()
+	defer done()
+	response, err := h.streamCompletion(ctx, model, h.messages, ttsCallback)
+	// Trimming is cheap, the summary is generated after the lock is released
+	h.compactHistory(model)
+	return response, err
+}
+
+// GenerateOpening asks the LLM for an opening line following the given instruction.
+// The instruction itself is not kept in history, only the generated opening is.
+func (h *LLMHandler) GenerateOpening(model, instruction string, ttsCallback TTSCallback) (string, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	messages := append(append([]openai.ChatCompletionMessage{}, h.messages...), openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleUser,
+		Content: instruction,
+	})
+	ctx, done := h.beginTurn()
+	defer done()
+	return h.streamCompletion(ctx, model, messages, ttsCallback)
+}
+
+// Interrupt cancels the in-flight generation, segments not yet sent to TTS are dropped
+// and only the text already spoken is kept in history. It reports whether a turn was running.
+func (h *LLMHandler) Interrupt() bool {
+	h.cancelMutex.Lock()
+	defer h.cancelMutex.Unlock()
+	if h.cancelTurn == nil {
+		return false
+	}
+	h.cancelTurn()
+	h.cancelTurn = nil
+	return true
+}
+
+// Generating reports whether a turn is in flight
+func (h *LLMHandler) Generating() bool {
+	h.cancelMutex.Lock()
+	defer h.cancelMutex.Unlock()
+	return h.cancelTurn != nil
+}
+
+// beginTurn creates the cancellable context of one turn, done must be called when the turn ends
+func (h *LLMHandler) beginTurn() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(h.ctx)
+	h.cancelMutex.Lock()
+	h.cancelTurn = cancel
+	h.cancelMutex.Unlock()
+	return ctx, func() {
+		h.cancelMutex.Lock()
+		h.cancelTurn = nil
+		h.cancelMutex.Unlock()
+		cancel()
 	}
+}
 
-	// Construct the openai request
-	if model == "" {
-		model = openai.GPT4o
+// RetractLastUserTurn removes the last user message and the tool calls answering it when
+// nothing of the answer was said, so a turn cancelled before speaking can be asked again
+// together with what the caller added. It returns the removed user text.
+func (h *LLMHandler) RetractLastUserTurn() (string, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for i := len(h.messages) - 1; i >= 0; i-- {
+		message := h.messages[i]
+		switch {
+		case message.Role == openai.ChatMessageRoleUser:
+			h.messages = h.messages[:i]
+			return message.Content, true
+		case message.Role == openai.ChatMessageRoleSystem,
+			message.Role == openai.ChatMessageRoleAssistant && message.Content != "":
+			return "", false
+		}
 	}
-	request := openai.ChatCompletionRequest{
-		Model:       model,
-		Messages:    h.messages,
-		Temperature: 0.7,
-		Stream:      true,
-		Tools: []openai.Tool{
-			{
-				Type:     openai.ToolTypeFunction,
-				Function: &functionDefinition,
-			},
-		},
+	return "", false
+}
+
+// TruncateLastAssistantMessage keeps only the first spokenRunes runes of what the
+// assistant said since the last user message, the rest was never heard by the caller
+func (h *LLMHandler) TruncateLastAssistantMessage(spokenRunes int) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	start := len(h.messages)
+	for start > 0 && h.messages[start-1].Role != openai.ChatMessageRoleUser && h.messages[start-1].Role != openai.ChatMessageRoleSystem {
+		start--
 	}
+	remaining := spokenRunes
+	messages := h.messages[:start]
+	for _, message := range h.messages[start:] {
+		if message.Role == openai.ChatMessageRoleAssistant {
+			content := []rune(message.Content)
+			if len(content) > remaining {
+				content = content[:remaining]
+			}
+			remaining -= len(content)
+			message.Content = string(content)
+			// An assistant message needs either content or tool calls
+			if message.Content == "" && len(message.ToolCalls) == 0 {
+				continue
+			}
+		}
+		messages = append(messages, message)
+	}
+	h.messages = messages
+}
+
+// SetStreamingTTS makes replies go to TTS as they stream in, for a streaming TTS play
+// that receives the whole reply. Otherwise the reply is cut into sentences at punctuation.
+func (h *LLMHandler) SetStreamingTTS(enabled bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.streamingTTS = enabled
+}
+
+// SetSegmenterConfig sets the segment lengths used to cut replies for TTS
+func (h *LLMHandler) SetSegmenterConfig(config SegmenterConfig) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.segmenterConfig = config
+}
+
+// SetToolCallListener sets a function called before each tool the LLM requested is executed
+func (h *LLMHandler) SetToolCallListener(listener func(name string)) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.toolCallListener = listener
+}
+
+// AddAssistantMessage records text the robot has already said as an assistant turn
+func (h *LLMHandler) AddAssistantMessage(text string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.messages = append(h.messages, openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleAssistant,
+		Content: text,
+	})
+}
+
+// streamCompletion streams a completion for messages, sends segments to TTS and
+// appends the assistant's response to history. When the LLM calls tools, the
+// calls are executed and their results fed back until the model answers in text.
+// When ctx is cancelled the text already sent to TTS is kept and ErrInterrupted is returned.
+// The caller must hold h.mutex.
+func (h *LLMHandler) streamCompletion(ctx context.Context, model string, messages []openai.ChatCompletionMessage, ttsCallback TTSCallback) (string, error) {
+	if model == "" {
+		model = h.provider.DefaultModel()
+	}
+	// Copy so appending tool rounds never writes into h.messages' backing array
+	messages = append([]openai.ChatCompletionMessage{}, messages...)
 
 	// Generate a unique playID for this conversation
 	playID := fmt.Sprintf("llm-%s", uuid.New().String())
 	h.logger.WithField("playID", playID).Info("Starting LLM stream with playID")
 
-	// Stream for handling responses
-	stream, err := h.client.CreateChatCompletionStream(h.ctx, request)
-	if err != nil {
-		return "", fmt.Errorf("error creating chat completion stream: %w", err)
-	}
-	defer stream.Close()
-
-	// Buffer to collect text until punctuation
-	var buffer string
+	// Collects text until a sentence is complete
+	segmenter := NewSegmenter(h.segmenterConfig)
 	fullResponse := ""
 	var shouldHangup bool
 
-	// Regular expression to detect punctuation followed by space or end of string
-	punctuationRegex := regexp.MustCompile(`([.,;:!?，。！？；：])\s*`)
+	// Text of the current round handed to TTS, kept in history when the turn is interrupted
+	roundSent := ""
+	spoken := ""
+	speak := func(segment string, autoHangup, endOfStream bool) {
+		// Drop segments once the caller barged in
+		if ctx.Err() != nil {
+			return
+		}
+		if err := ttsCallback(segment, playID, autoHangup, endOfStream); err != nil {
+			if errors.Is(err, ErrInterrupted) {
+				return
+			}
+			h.logger.WithError(err).Error("Failed to send TTS segment")
+		}
+		roundSent += segment
+		spoken += segment
+	}
+	interrupted := func() (string, error) {
+		if roundSent != "" {
+			h.messages = append(h.messages, openai.ChatCompletionMessage{
+				Role:    openai.ChatMessageRoleAssistant,
+				Content: roundSent,
+			})
+		}
+		h.logger.WithFields(logrus.Fields{
+			"playID": playID,
+			"spoken": len(spoken),
+		}).Info("LLM stream interrupted")
+		return spoken, ErrInterrupted
+	}
+
+	for round := 0; round < maxToolRounds; round++ {
+		messages = h.fitContext(messages)
+		request := ChatRequest{
+			Model:       model,
+			Messages:    messages,
+			Temperature: 0.7,
+			Tools:       h.tools.OpenAITools(),
+		}
+		estimatedTokens := EstimateMessagesTokens(messages)
 
-	// Process the stream of responses
-	for {
-		response, err := stream.Recv()
+		// Stream for handling responses
+		stream, err := h.provider.ChatStream(ctx, request)
 		if err != nil {
-			if err.Error() == "EOF" {
-				// Stream closed normally
-				break
+			if ctx.Err() != nil {
+				return interrupted()
 			}
-			return "", fmt.Errorf("error receiving from stream: %w", err)
+			return "", err
 		}
 
-		// Check for function calls (hangup)
-		if len(response.Choices) > 0 && len(response.Choices[0].Delta.ToolCalls) > 0 {
-			for _, toolCall := range response.Choices[0].Delta.ToolCalls {
-				if toolCall.Function.Name == "hangup" {
-					h.logger.Info("LLM requested hangup")
-					shouldHangup = true
+		roundContent := ""
+		roundSent = ""
+		var usage *openai.Usage
+		// Tool calls arrive in fragments keyed by index, arguments are split across chunks
+		var toolCalls []openai.ToolCall
+
+		// Process the stream of responses
+		for {
+			delta, err := stream.Recv()
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					// Stream closed normally
+					break
 				}
+				stream.Close()
+				if ctx.Err() != nil {
+					return interrupted()
+				}
+				return "", fmt.Errorf("error receiving from stream: %w", err)
+			}
+			if delta.Usage != nil {
+				usage = delta.Usage
+			}
+
+			for _, toolCall := range delta.ToolCalls {
+				toolCalls = mergeToolCallDelta(toolCalls, toolCall)
 			}
-		}
 
-		// Process content if available
-		if len(response.Choices) > 0 && response.Choices[0].Delta.Content != "" {
-			content := response.Choices[0].Delta.Content
-			buffer += content
-			fullResponse += content
-
-			// Check for punctuation in the buffer
-			matches := punctuationRegex.FindAllStringSubmatchIndex(buffer, -1)
-			if len(matches) > 0 {
-				lastIdx := 0
-				for _, match := range matches {
-					// Extract the segment up to and including the punctuation
-					segment := buffer[lastIdx:match[1]]
-					if segment != "" {
-						// Send this segment to TTS with the same playId
-						if err := ttsCallback(segment, playID, false); err != nil {
-							h.logger.WithError(err).Error("Failed to send TTS segment")
-						}
+			// Process content if available
+			if delta.Content != "" {
+				content := delta.Content
+				roundContent += content
+				fullResponse += content
+				if h.streamingTTS {
+					if words := segmenter.PushWords(content); words != "" {
+						speak(words, false, false)
 					}
-					lastIdx = match[1]
+					continue
 				}
-
-				// Keep the remainder in the buffer
-				if lastIdx < len(buffer) {
-					buffer = buffer[lastIdx:]
-				} else {
-					buffer = ""
+				for _, segment := range segmenter.Push(content) {
+					// Send this segment to TTS with the same playId
+					speak(segment, false, false)
 				}
 			}
 		}
-	}
+		stream.Close()
+		h.logRoundTokens(round, estimatedTokens, usage)
+
+		// Add assistant's response of this round to conversation history
+		assistantMessage := openai.ChatCompletionMessage{
+			Role:      openai.ChatMessageRoleAssistant,
+			Content:   roundContent,
+			ToolCalls: toolCalls,
+		}
+		messages = append(messages, assistantMessage)
+		h.messages = append(h.messages, assistantMessage)
+		if len(toolCalls) == 0 {
+			break
+		}
+
+		// Speak what the model said before calling tools while they run,
+		// a hangup keeps the text so the last segment can carry autoHangup
+		if !containsToolCall(toolCalls, HangupToolName) {
+			if rest := segmenter.Flush(); rest != "" {
+				speak(rest, false, false)
+			}
+		}
 
-	// Send any remaining text in the buffer
-	if err := ttsCallback(buffer, playID, shouldHangup); err != nil {
-		h.logger.WithError(err).Error("Failed to send final TTS segment")
+		// Every tool call needs a tool message, otherwise the next request is rejected
+		stop := false
+		for _, toolCall := range toolCalls {
+			h.logger.WithFields(logrus.Fields{
+				"tool":      toolCall.Function.Name,
+				"arguments": toolCall.Function.Arguments,
+			}).Info("LLM requested tool call")
+			if h.toolCallListener != nil {
+				h.toolCallListener(toolCall.Function.Name)
+			}
+			output := h.tools.Execute(ctx, toolCall)
+			if output.Stop {
+				stop = true
+				if toolCall.Function.Name == HangupToolName {
+					shouldHangup = true
+				}
+			}
+			toolMessage := openai.ChatCompletionMessage{
+				Role:       openai.ChatMessageRoleTool,
+				Content:    output.Content,
+				Name:       toolCall.Function.Name,
+				ToolCallID: toolCall.ID,
+			}
+			messages = append(messages, toolMessage)
+			h.messages = append(h.messages, toolMessage)
+		}
+		if stop {
+			break
+		}
+		if ctx.Err() != nil {
+			// Tool results are already in history, nothing of the next round was spoken
+			roundSent = ""
+			return interrupted()
+		}
 	}
 
-	// Add assistant's complete response to conversation history
-	h.messages = append(h.messages, openai.ChatCompletionMessage{
-		Role:    openai.ChatMessageRoleAssistant,
-		Content: fullResponse,
-	})
+	// Send any remaining text in the buffer, this call also closes the reply
+	speak(segmenter.Flush(), shouldHangup, true)
 
 	h.logger.WithFields(logrus.Fields{
 		"responseLength": len(fullResponse),
@@ -188,6 +427,58 @@ func (h *LLMHandler) QueryStream(model, text string, ttsCallback func(segment st
 	return fullResponse, nil
 }
 
+// logRoundTokens logs the token usage of one completion, servers that do not report
+// usage in the stream only get the estimate
+func (h *LLMHandler) logRoundTokens(round, estimatedTokens int, usage *openai.Usage) {
+	fields := logrus.Fields{
+		"round":           round,
+		"estimatedTokens": estimatedTokens,
+		"budget":          h.contextPolicy.maxTokens(),
+	}
+	if usage != nil {
+		fields["promptTokens"] = usage.PromptTokens
+		fields["completionTokens"] = usage.CompletionTokens
+		fields["totalTokens"] = usage.TotalTokens
+	}
+	h.logger.WithFields(fields).Info("LLM token usage")
+}
+
+// mergeToolCallDelta accumulates a streamed tool call fragment into calls by its index
+func mergeToolCallDelta(calls []openai.ToolCall, delta openai.ToolCall) []openai.ToolCall {
+	index := len(calls)
+	if delta.Index != nil {
+		index = *delta.Index
+	} else if delta.ID == "" && len(calls) > 0 {
+		// Fragments without index or id continue the last call
+		index = len(calls) - 1
+	}
+	for len(calls) <= index {
+		calls = append(calls, openai.ToolCall{Type: openai.ToolTypeFunction})
+	}
+	call := &calls[index]
+	if delta.ID != "" {
+		call.ID = delta.ID
+	}
+	if delta.Type != "" {
+		call.Type = delta.Type
+	}
+	// Names are not split across chunks, some servers repeat them in every chunk
+	if call.Function.Name == "" {
+		call.Function.Name = delta.Function.Name
+	}
+	call.Function.Arguments += delta.Function.Arguments
+	return calls
+}
+
+func containsToolCall(calls []openai.ToolCall, name string) bool {
+	for _, call := range calls {
+		if call.Function.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
 // Query the LLM with text and get a response (non-streaming version, kept for compatibility)
 func (h *LLMHandler) Query(model, text string) (string, *HangupTool, error) {
 	h.mutex.Lock()
@@ -199,54 +490,38 @@ func (h *LLMHandler) Query(model, text string) (string, *HangupTool, error) {
 		Content: text,
 	})
 
-	// Define the function for hanging up
-	functionDefinition := openai.FunctionDefinition{
-		Name:        "hangup",
-		Description: "End the conversation and hang up the call",
-		Parameters: json.RawMessage(`{
-			"type": "object",
-			"properties": {
-				"reason": {
-					"type": "string",
-					"description": "Reason for hanging up the call"
-				}
-			},
-			"required": []
-		}`),
-	}
-
-	// Construct the openai request
 	if model == "" {
-		model = openai.GPT4o
-	}
-	request := openai.ChatCompletionRequest{
-		Model:       model,
-		Messages:    h.messages,
-		Temperature: 0.7,
-		Tools: []openai.Tool{
-			{
-				Type:     openai.ToolTypeFunction,
-				Function: &functionDefinition,
-			},
-		},
+		model = h.provider.DefaultModel()
 	}
 
-	// Send the request to openai
-	response, err := h.client.CreateChatCompletion(h.ctx, request)
-	if err != nil {
-		return "", nil, fmt.Errorf("error querying openai: %w", err)
-	}
+	var hangupTool *HangupTool
+	content := ""
+	for round := 0; round < maxToolRounds; round++ {
+		request := ChatRequest{
+			Model:       model,
+			Messages:    h.fitContext(h.messages),
+			Temperature: 0.7,
+			Tools:       h.tools.OpenAITools(),
+		}
 
-	// Process the response
-	message := response.Choices[0].Message
-	h.messages = append(h.messages, message)
+		// Send the request to the provider
+		response, err := h.provider.Chat(h.ctx, request)
+		if err != nil {
+			return "", nil, err
+		}
+		h.logRoundTokens(round, EstimateMessagesTokens(request.Messages), response.Usage)
+
+		// Process the response
+		message := response.Message
+		h.messages = append(h.messages, message)
+		content += message.Content
+		if len(message.ToolCalls) == 0 {
+			break
+		}
 
-	// Check if there's a tool call for hangup
-	var hangupTool *HangupTool
-	// Check for tool calls
-	if len(message.ToolCalls) > 0 {
+		stop := false
 		for _, toolCall := range message.ToolCalls {
-			if toolCall.Function.Name == "hangup" {
+			if toolCall.Function.Name == HangupToolName {
 				hangupTool = &HangupTool{}
 				// Parse the arguments
 				if err := json.Unmarshal([]byte(toolCall.Function.Arguments), hangupTool); err != nil {
@@ -255,10 +530,29 @@ func (h *LLMHandler) Query(model, text string) (string, *HangupTool, error) {
 					h.logger.WithField("reason", hangupTool.Reason).Info("llm: Hangup reason")
 				}
 			}
+			output := h.tools.Execute(h.ctx, toolCall)
+			stop = stop || output.Stop
+			h.messages = append(h.messages, openai.ChatCompletionMessage{
+				Role:       openai.ChatMessageRoleTool,
+				Content:    output.Content,
+				Name:       toolCall.Function.Name,
+				ToolCallID: toolCall.ID,
+			})
+		}
+		if stop {
+			break
 		}
 	}
 
-	return message.Content, hangupTool, nil
+	h.compactHistory(model)
+	return content, hangupTool, nil
+}
+
+// SetTools replaces the tools offered to the LLM
+func (h *LLMHandler) SetTools(tools *ToolRegistry) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.tools = tools
 }
 
 // Reset clears the conversation history but keeps the system prompt
@@ -266,6 +560,10 @@ func (h *LLMHandler) Reset() {
 	h.mutex.Lock()
 	defer h.mutex.Unlock()
 
+	// Drop summaries still being generated for the previous conversation
+	h.historyEpoch++
+	h.summarizing = false
+	h.pendingSummary = nil
 	// Reset to just the system message
 	h.messages = []openai.ChatCompletionMessage{
 		{
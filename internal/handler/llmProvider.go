@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// LLM providers selectable by RobotKey.LLMProvider
+const (
+	LLMProviderOpenAI    = "openai"
+	LLMProviderDashScope = "dashscope"
+	LLMProviderOllama    = "ollama"
+)
+
+// ChatRequest is a provider-neutral chat completion request. Messages and tools use
+// the OpenAI chat schema as the common format, providers convert them to their own API.
+type ChatRequest struct {
+	Model       string
+	Messages    []openai.ChatCompletionMessage
+	Tools       []openai.Tool
+	Temperature float32
+}
+
+// ChatDelta is one streamed fragment of a reply
+type ChatDelta struct {
+	Content string
+	// ToolCalls are fragments keyed by Index, arguments may be split across deltas
+	ToolCalls []openai.ToolCall
+	// Usage is set on the delta that reports token usage, usually the last one
+	Usage *openai.Usage
+}
+
+// ChatResponse is a complete reply of a non-streaming request
+type ChatResponse struct {
+	Message openai.ChatCompletionMessage
+	Usage   *openai.Usage
+}
+
+// ChatStream yields the deltas of a reply, Recv returns io.EOF when the reply is complete
+type ChatStream interface {
+	Recv() (ChatDelta, error)
+	Close() error
+}
+
+// LLMProvider is a chat backend with tool calling
+type LLMProvider interface {
+	// Name is the provider name stored on the robot key
+	Name() string
+	// DefaultModel is used when the caller does not pick a model
+	DefaultModel() string
+	// ChatStream starts a streaming completion
+	ChatStream(ctx context.Context, request ChatRequest) (ChatStream, error)
+	// Chat runs a non-streaming completion
+	Chat(ctx context.Context, request ChatRequest) (ChatResponse, error)
+}
+
+// NewLLMProvider creates the provider configured on a robot key. Unknown names are
+// treated as OpenAI-compatible endpoints, which is how keys were used before providers existed.
+func NewLLMProvider(name, apiKey, endpoint string) (LLMProvider, error) {
+	switch strings.ToLower(name) {
+	case LLMProviderOllama:
+		return NewOllamaProvider(endpoint), nil
+	case LLMProviderDashScope, "qwen", "aliyun":
+		if endpoint == "" {
+			endpoint = dashScopeCompatibleURL
+		}
+		return NewOpenAIProvider(LLMProviderDashScope, apiKey, endpoint, defaultQwenModel), nil
+	default:
+		// Keys pointing at a compatible endpoint keep the qwen model they always used
+		if endpoint == "" {
+			return NewOpenAIProvider(name, apiKey, "", openai.GPT4oMini), nil
+		}
+		return NewOpenAIProvider(name, apiKey, endpoint, defaultQwenModel), nil
+	}
+}
+
+// collectStream reads a stream to the end and merges it into one message,
+// used by providers whose non-streaming API is the stream itself
+func collectStream(stream ChatStream) (ChatResponse, error) {
+	defer stream.Close()
+	response := ChatResponse{Message: openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant}}
+	for {
+		delta, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return response, nil
+		}
+		if err != nil {
+			return ChatResponse{}, err
+		}
+		response.Message.Content += delta.Content
+		for _, toolCall := range delta.ToolCalls {
+			response.Message.ToolCalls = mergeToolCallDelta(response.Message.ToolCalls, toolCall)
+		}
+		if delta.Usage != nil {
+			response.Usage = delta.Usage
+		}
+	}
+}
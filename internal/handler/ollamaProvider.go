@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	// defaultOllamaURL is where a local Ollama server listens by default
+	defaultOllamaURL = "http://localhost:11434"
+	// defaultOllamaModel is used when the robot does not pick a model
+	defaultOllamaModel = "qwen2.5"
+)
+
+// OllamaProvider talks to an Ollama-style local server through its native /api/chat endpoint
+type OllamaProvider struct {
+	endpoint   string
+	httpClient *http.Client
+}
+
+// NewOllamaProvider creates a provider for a local server, an empty endpoint means localhost:11434
+func NewOllamaProvider(endpoint string) *OllamaProvider {
+	if endpoint == "" {
+		endpoint = defaultOllamaURL
+	}
+	return &OllamaProvider{
+		endpoint:   strings.TrimSuffix(endpoint, "/"),
+		httpClient: &http.Client{},
+	}
+}
+
+func (p *OllamaProvider) Name() string {
+	return LLMProviderOllama
+}
+
+func (p *OllamaProvider) DefaultModel() string {
+	return defaultOllamaModel
+}
+
+type ollamaToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+}
+
+type ollamaChatRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Tools    []openai.Tool   `json:"tools,omitempty"`
+	Stream   bool            `json:"stream"`
+	Options  map[string]any  `json:"options,omitempty"`
+}
+
+type ollamaChatResponse struct {
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error"`
+}
+
+func (p *OllamaProvider) ChatStream(ctx context.Context, request ChatRequest) (ChatStream, error) {
+	messages := make([]ollamaMessage, 0, len(request.Messages))
+	for _, message := range request.Messages {
+		converted := ollamaMessage{Role: message.Role, Content: message.Content}
+		if message.Role == openai.ChatMessageRoleTool {
+			converted.ToolName = message.Name
+		}
+		for _, toolCall := range message.ToolCalls {
+			var call ollamaToolCall
+			call.Function.Name = toolCall.Function.Name
+			// Ollama takes the arguments as a JSON object rather than a string
+			call.Function.Arguments = json.RawMessage(toolCall.Function.Arguments)
+			if !json.Valid(call.Function.Arguments) {
+				call.Function.Arguments = json.RawMessage("{}")
+			}
+			converted.ToolCalls = append(converted.ToolCalls, call)
+		}
+		messages = append(messages, converted)
+	}
+	body, err := json.Marshal(ollamaChatRequest{
+		Model:    request.Model,
+		Messages: messages,
+		Tools:    request.Tools,
+		Stream:   true,
+		Options:  map[string]any{"temperature": request.Temperature},
+	})
+	if err != nil {
+		return nil, err
+	}
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint+"/api/chat", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+	response, err := p.httpClient.Do(httpRequest)
+	if err != nil {
+		return nil, fmt.Errorf("error creating ollama chat stream: %w", err)
+	}
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		defer response.Body.Close()
+		content, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return nil, fmt.Errorf("error creating ollama chat stream: %w", &HTTPStatusError{
+			StatusCode: response.StatusCode,
+			Message:    strings.TrimSpace(string(content)),
+		})
+	}
+	scanner := bufio.NewScanner(response.Body)
+	// A chunk carrying tool calls can exceed the default 64KB line limit
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	return &ollamaStream{body: response.Body, scanner: scanner}, nil
+}
+
+func (p *OllamaProvider) Chat(ctx context.Context, request ChatRequest) (ChatResponse, error) {
+	stream, err := p.ChatStream(ctx, request)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	return collectStream(stream)
+}
+
+// ollamaStream reads the newline delimited JSON stream of /api/chat
+type ollamaStream struct {
+	body      io.ReadCloser
+	scanner   *bufio.Scanner
+	toolIndex int
+	done      bool
+}
+
+func (s *ollamaStream) Recv() (ChatDelta, error) {
+	for !s.done && s.scanner.Scan() {
+		line := bytes.TrimSpace(s.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var chunk ollamaChatResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return ChatDelta{}, fmt.Errorf("error decoding ollama stream: %w", err)
+		}
+		if chunk.Error != "" {
+			return ChatDelta{}, fmt.Errorf("error receiving from ollama stream: %s", chunk.Error)
+		}
+		delta := ChatDelta{Content: chunk.Message.Content}
+		// Ollama sends each tool call complete in one chunk and without an id
+		for _, call := range chunk.Message.ToolCalls {
+			index := s.toolIndex
+			s.toolIndex++
+			delta.ToolCalls = append(delta.ToolCalls, openai.ToolCall{
+				Index: &index,
+				ID:    fmt.Sprintf("call_%d", index),
+				Type:  openai.ToolTypeFunction,
+				Function: openai.FunctionCall{
+					Name:      call.Function.Name,
+					Arguments: string(call.Function.Arguments),
+				},
+			})
+		}
+		if chunk.Done {
+			s.done = true
+			delta.Usage = &openai.Usage{
+				PromptTokens:     chunk.PromptEvalCount,
+				CompletionTokens: chunk.EvalCount,
+				TotalTokens:      chunk.PromptEvalCount + chunk.EvalCount,
+			}
+		}
+		return delta, nil
+	}
+	if err := s.scanner.Err(); err != nil {
+		return ChatDelta{}, fmt.Errorf("error receiving from ollama stream: %w", err)
+	}
+	return ChatDelta{}, io.EOF
+}
+
+func (s *ollamaStream) Close() error {
+	return s.body.Close()
+}
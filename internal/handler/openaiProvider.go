@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	// dashScopeCompatibleURL is DashScope's OpenAI-compatible endpoint for qwen models
+	dashScopeCompatibleURL = "https://dashscope.aliyuncs.com/compatible-mode/v1"
+	// defaultQwenModel is the model used before models were configurable
+	defaultQwenModel = "qwen-turbo"
+)
+
+// OpenAIProvider talks to OpenAI or any OpenAI-compatible endpoint such as DashScope
+type OpenAIProvider struct {
+	name         string
+	defaultModel string
+	client       *openai.Client
+}
+
+// NewOpenAIProvider creates an OpenAI-compatible provider, an empty endpoint means api.openai.com
+func NewOpenAIProvider(name, apiKey, endpoint, defaultModel string) *OpenAIProvider {
+	config := openai.DefaultConfig(apiKey)
+	if endpoint != "" {
+		config.BaseURL = endpoint
+	}
+	return &OpenAIProvider{
+		name:         name,
+		defaultModel: defaultModel,
+		client:       openai.NewClientWithConfig(config),
+	}
+}
+
+func (p *OpenAIProvider) Name() string {
+	return p.name
+}
+
+func (p *OpenAIProvider) DefaultModel() string {
+	return p.defaultModel
+}
+
+func (p *OpenAIProvider) ChatStream(ctx context.Context, request ChatRequest) (ChatStream, error) {
+	stream, err := p.client.CreateChatCompletionStream(ctx, openai.ChatCompletionRequest{
+		Model:       request.Model,
+		Messages:    request.Messages,
+		Temperature: request.Temperature,
+		Stream:      true,
+		Tools:       request.Tools,
+		// The last chunk carries the token usage of the request
+		StreamOptions: &openai.StreamOptions{IncludeUsage: true},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating chat completion stream: %w", err)
+	}
+	return &openAIStream{stream: stream}, nil
+}
+
+func (p *OpenAIProvider) Chat(ctx context.Context, request ChatRequest) (ChatResponse, error) {
+	response, err := p.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model:       request.Model,
+		Messages:    request.Messages,
+		Temperature: request.Temperature,
+		Tools:       request.Tools,
+	})
+	if err != nil {
+		return ChatResponse{}, fmt.Errorf("error querying openai: %w", err)
+	}
+	if len(response.Choices) == 0 {
+		return ChatResponse{}, errors.New("error querying openai: empty choices")
+	}
+	return ChatResponse{Message: response.Choices[0].Message, Usage: &response.Usage}, nil
+}
+
+type openAIStream struct {
+	stream *openai.ChatCompletionStream
+}
+
+func (s *openAIStream) Recv() (ChatDelta, error) {
+	for {
+		response, err := s.stream.Recv()
+		if err != nil {
+			return ChatDelta{}, err
+		}
+		delta := ChatDelta{Usage: response.Usage}
+		if len(response.Choices) > 0 {
+			delta.Content = response.Choices[0].Delta.Content
+			delta.ToolCalls = response.Choices[0].Delta.ToolCalls
+		}
+		if delta.Content == "" && len(delta.ToolCalls) == 0 && delta.Usage == nil {
+			continue
+		}
+		return delta, nil
+	}
+}
+
+func (s *openAIStream) Close() error {
+	return s.stream.Close()
+}
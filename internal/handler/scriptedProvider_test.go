@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// scriptedChunkRunes is how many runes of content the scripted provider sends per delta
+const scriptedChunkRunes = 4
+
+// ScriptedToolCall is a tool call a scripted reply makes
+type ScriptedToolCall struct {
+	Name      string
+	Arguments string
+}
+
+// ScriptedReply is one canned reply of the scripted provider
+type ScriptedReply struct {
+	Content   string
+	ToolCalls []ScriptedToolCall
+}
+
+// ScriptedProvider replies with canned messages in order, so tests run deterministically
+// without a model. Without replies it echoes the last user message.
+type ScriptedProvider struct {
+	mutex   sync.Mutex
+	replies []ScriptedReply
+	next    int
+	calls   int
+}
+
+// NewScriptedProvider creates a provider that returns replies in order, the last reply repeats
+func NewScriptedProvider(replies ...ScriptedReply) *ScriptedProvider {
+	return &ScriptedProvider{replies: replies}
+}
+
+func (p *ScriptedProvider) Name() string {
+	return "scripted"
+}
+
+func (p *ScriptedProvider) DefaultModel() string {
+	return "scripted"
+}
+
+// nextReply picks the reply for a request
+func (p *ScriptedProvider) nextReply(request ChatRequest) ScriptedReply {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.calls++
+	if len(p.replies) == 0 {
+		for i := len(request.Messages) - 1; i >= 0; i-- {
+			if request.Messages[i].Role == openai.ChatMessageRoleUser {
+				return ScriptedReply{Content: request.Messages[i].Content}
+			}
+		}
+		return ScriptedReply{}
+	}
+	reply := p.replies[p.next]
+	if p.next < len(p.replies)-1 {
+		p.next++
+	}
+	return reply
+}
+
+// Calls returns how many requests the provider has answered
+func (p *ScriptedProvider) Calls() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.calls
+}
+
+func (p *ScriptedProvider) ChatStream(ctx context.Context, request ChatRequest) (ChatStream, error) {
+	reply := p.nextReply(request)
+	var deltas []ChatDelta
+	content := []rune(reply.Content)
+	for start := 0; start < len(content); start += scriptedChunkRunes {
+		end := start + scriptedChunkRunes
+		if end > len(content) {
+			end = len(content)
+		}
+		deltas = append(deltas, ChatDelta{Content: string(content[start:end])})
+	}
+	for i, call := range reply.ToolCalls {
+		index := i
+		arguments := call.Arguments
+		if arguments == "" {
+			arguments = "{}"
+		}
+		deltas = append(deltas, ChatDelta{ToolCalls: []openai.ToolCall{{
+			Index:    &index,
+			ID:       fmt.Sprintf("call_%d_%d", p.Calls(), i),
+			Type:     openai.ToolTypeFunction,
+			Function: openai.FunctionCall{Name: call.Name, Arguments: arguments},
+		}}})
+	}
+	completionTokens := EstimateTokens(reply.Content)
+	promptTokens := EstimateMessagesTokens(request.Messages)
+	deltas = append(deltas, ChatDelta{Usage: &openai.Usage{
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		TotalTokens:      promptTokens + completionTokens,
+	}})
+	return &scriptedStream{ctx: ctx, deltas: deltas}, nil
+}
+
+func (p *ScriptedProvider) Chat(ctx context.Context, request ChatRequest) (ChatResponse, error) {
+	stream, err := p.ChatStream(ctx, request)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	return collectStream(stream)
+}
+
+type scriptedStream struct {
+	ctx    context.Context
+	deltas []ChatDelta
+}
+
+func (s *scriptedStream) Recv() (ChatDelta, error) {
+	if err := s.ctx.Err(); err != nil {
+		return ChatDelta{}, err
+	}
+	if len(s.deltas) == 0 {
+		return ChatDelta{}, io.EOF
+	}
+	delta := s.deltas[0]
+	s.deltas = s.deltas[1:]
+	return delta, nil
+}
+
+func (s *scriptedStream) Close() error {
+	return nil
+}
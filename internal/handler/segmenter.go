@@ -0,0 +1,368 @@
+package handler
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	// DefaultSegmentMinRunes is the shortest segment sent to TTS after the first one
+	DefaultSegmentMinRunes = 6
+	// DefaultSegmentMaxRunes is the longest segment sent to TTS, longer text is cut without a boundary
+	DefaultSegmentMaxRunes = 80
+)
+
+// SegmenterConfig sets the segment lengths in runes, zero values use the defaults
+type SegmenterConfig struct {
+	// MinRunes is the shortest segment, shorter sentences are merged with the following text.
+	// The first segment of a reply ignores it so speech starts as early as possible.
+	MinRunes int
+	// MaxRunes is the longest segment, text without a boundary is cut at a space or at the limit
+	MaxRunes int
+}
+
+func (c SegmenterConfig) minRunes() int {
+	if c.MinRunes <= 0 {
+		return DefaultSegmentMinRunes
+	}
+	return c.MinRunes
+}
+
+func (c SegmenterConfig) maxRunes() int {
+	if c.MaxRunes <= 0 {
+		return DefaultSegmentMaxRunes
+	}
+	if c.MaxRunes < c.minRunes() {
+		return c.minRunes()
+	}
+	return c.MaxRunes
+}
+
+// segmentAbbreviations end with a period that does not end a sentence, compared in lower case
+var segmentAbbreviations = map[string]bool{
+	"mr": true, "mrs": true, "ms": true, "dr": true, "prof": true, "sr": true, "jr": true, "st": true,
+	"mt": true, "vs": true, "etc": true, "e.g": true, "i.e": true, "inc": true, "ltd": true,
+	"co": true, "corp": true, "dept": true, "approx": true, "fig": true, "jan": true, "feb": true,
+	"mar": true, "apr": true, "jun": true, "jul": true, "aug": true, "sep": true, "sept": true,
+	"oct": true, "nov": true, "dec": true, "a.m": true, "p.m": true,
+}
+
+type boundaryKind int
+
+const (
+	noBoundary boundaryKind = iota
+	// clauseBoundary is a comma or colon, a pause inside a sentence
+	clauseBoundary
+	// sentenceBoundary ends a sentence
+	sentenceBoundary
+)
+
+// Segmenter cuts streamed LLM text into segments for TTS. It knows Chinese and English
+// punctuation, and does not cut inside decimals, thousands separators, times, urls,
+// abbreviations or list numbering. Concatenating all segments gives back the input.
+type Segmenter struct {
+	config  SegmenterConfig
+	buffer  []rune
+	emitted bool
+}
+
+// NewSegmenter creates a segmenter for one reply
+func NewSegmenter(config SegmenterConfig) *Segmenter {
+	return &Segmenter{config: config}
+}
+
+// Push adds streamed text and returns the segments completed by it
+func (s *Segmenter) Push(text string) []string {
+	s.buffer = append(s.buffer, []rune(text)...)
+	var segments []string
+	for {
+		end := s.nextCut()
+		if end == 0 {
+			return segments
+		}
+		segments = append(segments, string(s.buffer[:end]))
+		s.buffer = s.buffer[end:]
+		s.emitted = true
+	}
+}
+
+// PushWords adds streamed text and returns it up to the last word boundary. Streaming TTS
+// takes text as it comes, but numbers, words and markup must reach normalization whole:
+// links are held until they are closed, and text is not cut where the rest would start
+// like a line of markdown.
+func (s *Segmenter) PushWords(text string) string {
+	s.buffer = append(s.buffer, []rune(text)...)
+	cut := s.wordCut(len(s.buffer))
+	// A link or image is normalized to its text, its url must not be sent on its own
+	if open := s.openLink(cut); open >= 0 && cut-open <= s.config.maxRunes() {
+		cut = s.wordCut(open)
+	}
+	for cut > 0 && s.looksLikeLineStart(cut) {
+		for cut > 0 && (s.buffer[cut-1] == ' ' || s.buffer[cut-1] == '\t') {
+			cut--
+		}
+		cut = s.wordCut(cut)
+	}
+	head := string(s.buffer[:cut])
+	s.buffer = append([]rune{}, s.buffer[cut:]...)
+	if head != "" {
+		s.emitted = true
+	}
+	return head
+}
+
+// wordCut moves cut back to the start of the word it is in
+func (s *Segmenter) wordCut(cut int) int {
+	for cut > 0 && isWordRune(s.buffer[cut-1]) {
+		cut--
+	}
+	return cut
+}
+
+// openLink returns where a link or image that is still open before cut starts, or -1
+func (s *Segmenter) openLink(cut int) int {
+	open := -1
+	inURL := false
+	for i := 0; i < cut; i++ {
+		switch r := s.buffer[i]; {
+		case r == '[' && open < 0:
+			open = i
+			if i > 0 && s.buffer[i-1] == '!' {
+				open = i - 1
+			}
+		case r == ']' && open >= 0 && !inURL:
+			// Brackets without a url are plain text
+			if i+1 < len(s.buffer) && s.buffer[i+1] == '(' {
+				inURL = true
+				i++
+			} else if i+1 < len(s.buffer) {
+				open = -1
+			}
+		case r == ')' && inURL:
+			open = -1
+			inURL = false
+		}
+	}
+	return open
+}
+
+// looksLikeLineStart reports whether cut is inside a line and the text after it, once
+// sent on its own, would start with a heading, quote or list marker
+func (s *Segmenter) looksLikeLineStart(cut int) bool {
+	if r := s.buffer[cut-1]; r != ' ' && r != '\t' {
+		return false
+	}
+	rest := s.buffer[cut:]
+	for len(rest) > 0 && (rest[0] == ' ' || rest[0] == '\t') {
+		rest = rest[1:]
+	}
+	if len(rest) == 0 {
+		// What follows is not known yet
+		return true
+	}
+	marker := rest[0]
+	if marker == '>' {
+		return true
+	}
+	if !strings.ContainsRune("#-*+", marker) {
+		return false
+	}
+	// A marker is followed by a space, "**bold**" or "-5" are not markers
+	run := 1
+	for run < len(rest) && rest[run] == marker && marker == '#' {
+		run++
+	}
+	return run == len(rest) || rest[run] == ' ' || rest[run] == '\t'
+}
+
+// Flush returns the text not yet segmented, at the end of a reply or before a pause
+func (s *Segmenter) Flush() string {
+	rest := string(s.buffer)
+	s.buffer = nil
+	if rest != "" {
+		s.emitted = true
+	}
+	return rest
+}
+
+// nextCut returns the length of the next complete segment in the buffer, or 0 when more text is needed
+func (s *Segmenter) nextCut() int {
+	minRunes := s.config.minRunes()
+	maxRunes := s.config.maxRunes()
+	// Clauses are only cut off once they are long, sentences are preferred
+	clauseRunes := maxRunes / 2
+	if clauseRunes < minRunes {
+		clauseRunes = minRunes
+	}
+	// lastBreak is the best place to cut when the segment grows too long
+	lastBreak := 0
+	for i := 0; i < len(s.buffer); i++ {
+		if i >= maxRunes {
+			if lastBreak > 0 {
+				return lastBreak
+			}
+			return maxRunes
+		}
+		kind, decided := s.boundaryAt(i)
+		if !decided {
+			return 0
+		}
+		if kind == noBoundary {
+			if unicode.IsSpace(s.buffer[i]) && hasContent(s.buffer[:i]) {
+				lastBreak = i + 1
+			}
+			continue
+		}
+		end := s.boundaryEnd(i + 1)
+		if !hasContent(s.buffer[:end]) {
+			i = end - 1
+			continue
+		}
+		switch {
+		case !s.emitted:
+			// First segment fast path: speak the first clause as soon as it is complete
+			return end
+		case kind == sentenceBoundary && end >= minRunes:
+			return end
+		case kind == clauseBoundary && end >= clauseRunes:
+			return end
+		}
+		if end <= maxRunes {
+			lastBreak = end
+		}
+		i = end - 1
+	}
+	return 0
+}
+
+// boundaryAt reports whether the rune at i ends a sentence or clause. decided is false when
+// the answer depends on text that has not arrived yet.
+func (s *Segmenter) boundaryAt(i int) (kind boundaryKind, decided bool) {
+	r := s.buffer[i]
+	switch r {
+	case '。', '！', '？', '；', '…', '\n':
+		return sentenceBoundary, true
+	case '，', '、', '：':
+		return clauseBoundary, true
+	case '.', '!', '?', ';':
+		kind = sentenceBoundary
+	case ',', ':':
+		kind = clauseBoundary
+	default:
+		return noBoundary, true
+	}
+	// ASCII punctuation only ends a sentence before a space or a CJK character,
+	// "3.5", "1,000", "10:30" and "example.com" are followed by other text
+	next := i + 1
+	for next < len(s.buffer) && (isClosingRune(s.buffer[next]) || s.buffer[next] == r) {
+		next++
+	}
+	if next == len(s.buffer) {
+		return noBoundary, false
+	}
+	following := s.buffer[next]
+	if !unicode.IsSpace(following) && !isCJK(following) {
+		return noBoundary, true
+	}
+	if r == '.' && (s.isAbbreviation(i) || s.isListNumber(i)) {
+		return noBoundary, true
+	}
+	return kind, true
+}
+
+// boundaryEnd extends a boundary over repeated punctuation, closing quotes and spaces
+func (s *Segmenter) boundaryEnd(end int) int {
+	for end < len(s.buffer) {
+		r := s.buffer[end]
+		if !isClosingRune(r) && !unicode.IsSpace(r) && !isSegmentPunct(r) {
+			break
+		}
+		end++
+	}
+	return end
+}
+
+// isAbbreviation reports whether the period at i ends an abbreviation or an initial like "J."
+func (s *Segmenter) isAbbreviation(i int) bool {
+	start := i
+	for start > 0 && (unicode.IsLetter(s.buffer[start-1]) || s.buffer[start-1] == '.') && !isCJK(s.buffer[start-1]) {
+		start--
+	}
+	// "1st." is an ordinal, not an abbreviation
+	if start > 0 && unicode.IsDigit(s.buffer[start-1]) {
+		return false
+	}
+	word := string(s.buffer[start:i])
+	// "I." ends a sentence far more often than it is an initial
+	if word == "" || word == "I" {
+		return false
+	}
+	if segmentAbbreviations[strings.ToLower(word)] {
+		return true
+	}
+	// Initials and initialisms such as "J." and "U.S."
+	for _, part := range strings.Split(word, ".") {
+		if len([]rune(part)) != 1 || !unicode.IsUpper([]rune(part)[0]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isListNumber reports whether the period at i follows list numbering like "1." at the start of a line
+func (s *Segmenter) isListNumber(i int) bool {
+	start := i
+	for start > 0 && unicode.IsDigit(s.buffer[start-1]) {
+		start--
+	}
+	if start == i {
+		return false
+	}
+	for j := start - 1; j >= 0; j-- {
+		if s.buffer[j] == '\n' {
+			return true
+		}
+		if !unicode.IsSpace(s.buffer[j]) {
+			return false
+		}
+	}
+	return true
+}
+
+// hasContent reports whether text has anything to speak besides punctuation and spaces
+func hasContent(text []rune) bool {
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func isSegmentPunct(r rune) bool {
+	switch r {
+	case '。', '！', '？', '；', '…', '，', '、', '：', '.', '!', '?', ';', ',', ':':
+		return true
+	}
+	return false
+}
+
+func isClosingRune(r rune) bool {
+	switch r {
+	case '"', '\'', ')', ']', '”', '’', '）', '」', '』', '》', '】':
+		return true
+	}
+	return false
+}
+
+// isWordRune reports whether r may continue a word, number, url or markup in the next chunk
+func isWordRune(r rune) bool {
+	if isCJK(r) || unicode.IsSpace(r) {
+		return false
+	}
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune(".,:-/+%*_`~#>[]()!$¥￥€£@&=?'", r)
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
@@ -0,0 +1,289 @@
+package handler
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"miniRustpbxgo/internal/normalize"
+)
+
+func TestSegmenter(t *testing.T) {
+	tests := []struct {
+		name   string
+		config SegmenterConfig
+		// chunks are pushed in order, like deltas of a stream
+		chunks []string
+		// want are the segments returned by Push followed by what Flush returns
+		want []string
+	}{
+		{
+			name:   "first clause is sent early",
+			chunks: []string{"好的，我来帮您查询一下订单状态。"},
+			want:   []string{"好的，", "我来帮您查询一下订单状态。"},
+		},
+		{
+			name:   "chinese sentences",
+			chunks: []string{"好的。", "您的订单已经发货了。预计明天送达。"},
+			want:   []string{"好的。", "您的订单已经发货了。", "预计明天送达。"},
+		},
+		{
+			name:   "short clauses are merged after the first segment",
+			chunks: []string{"您好！请问，有什么，可以帮您？"},
+			want:   []string{"您好！", "请问，有什么，可以帮您？"},
+		},
+		{
+			name:   "short sentences are merged after the first segment",
+			chunks: []string{"Sure. Yes. OK. I can help with that."},
+			want:   []string{"Sure. ", "Yes. OK. ", "I can help with that."},
+		},
+		{
+			name:   "english sentences",
+			chunks: []string{"Hello there! How can I help you today? I am here."},
+			want:   []string{"Hello there! ", "How can I help you today? ", "I am here."},
+		},
+		{
+			name:   "decimal is not cut",
+			chunks: []string{"The price is 3.5 dollars per unit. Anything else?"},
+			want:   []string{"The price is 3.5 dollars per unit. ", "Anything else?"},
+		},
+		{
+			name:   "decimal split across chunks",
+			chunks: []string{"It costs 3", ".", "5 dollars in total. ", "Thanks."},
+			want:   []string{"It costs 3.5 dollars in total. ", "Thanks."},
+		},
+		{
+			name:   "chinese decimal and thousands separator",
+			chunks: []string{"总价是1,234.50元。请确认。"},
+			want:   []string{"总价是1,234.50元。", "请确认。"},
+		},
+		{
+			name:   "time is not cut",
+			chunks: []string{"We open at 10:30 every day. See you then."},
+			want:   []string{"We open at 10:30 every day. ", "See you then."},
+		},
+		{
+			name:   "url is not cut",
+			chunks: []string{"Please visit example.com/help?id=3 for details. Bye now."},
+			want:   []string{"Please visit example.com/help?id=3 for details. ", "Bye now."},
+		},
+		{
+			name:   "abbreviations are not cut",
+			chunks: []string{"Dr. Smith and Mr. Lee will call you. They are nice."},
+			want:   []string{"Dr. Smith and Mr. Lee will call you. ", "They are nice."},
+		},
+		{
+			name:   "e.g. and initialisms are not cut",
+			chunks: []string{"Pick a fruit, e.g. an apple. We ship in the U.S. and Canada."},
+			want:   []string{"Pick a fruit, ", "e.g. an apple. ", "We ship in the U.S. and Canada."},
+		},
+		{
+			name:   "initial is not cut but I is",
+			chunks: []string{"Ask J. Smith about it. So do I. Thanks again."},
+			want:   []string{"Ask J. Smith about it. ", "So do I. ", "Thanks again."},
+		},
+		{
+			name:   "ordinal period ends a sentence",
+			chunks: []string{"You finished 1st. Well done everyone."},
+			want:   []string{"You finished 1st. ", "Well done everyone."},
+		},
+		{
+			name:   "list numbering is not cut",
+			chunks: []string{"Steps:\n1. Open the app\n2. Tap login\n"},
+			want:   []string{"Steps:\n", "1. Open the app\n", "2. Tap login\n"},
+		},
+		{
+			name:   "period at the end of a chunk waits for the next chunk",
+			chunks: []string{"Call Dr.", " Smith tomorrow morning."},
+			want:   []string{"Call Dr. Smith tomorrow morning."},
+		},
+		{
+			name:   "ascii punctuation before chinese",
+			chunks: []string{"好的,我知道了.请稍等一下哦!"},
+			want:   []string{"好的,", "我知道了.请稍等一下哦!"},
+		},
+		{
+			name:   "ellipsis and repeated punctuation stay together",
+			chunks: []string{"Well... let me think!! Okay then."},
+			want:   []string{"Well... ", "let me think!! ", "Okay then."},
+		},
+		{
+			name:   "closing quotes stay with the sentence",
+			chunks: []string{"他说：“好的，没问题。”然后就挂了电话。"},
+			want:   []string{"他说：", "“好的，没问题。”", "然后就挂了电话。"},
+		},
+		{
+			name:   "leading punctuation is not a segment",
+			chunks: []string{"。，好的。"},
+			want:   []string{"。，好的。"},
+		},
+		{
+			name:   "long chinese text is cut at the limit",
+			config: SegmenterConfig{MinRunes: 2, MaxRunes: 10},
+			chunks: []string{"一二三四五六七八九十甲乙丙丁"},
+			want:   []string{"一二三四五六七八九十", "甲乙丙丁"},
+		},
+		{
+			name:   "long english text is cut at a space",
+			config: SegmenterConfig{MinRunes: 2, MaxRunes: 12},
+			chunks: []string{"alpha beta gamma delta"},
+			want:   []string{"alpha beta ", "gamma delta"},
+		},
+		{
+			name:   "long clause is cut at max half",
+			config: SegmenterConfig{MinRunes: 2, MaxRunes: 20},
+			chunks: []string{"嗯，这是一个比较长的分句内容，后面还有。"},
+			want:   []string{"嗯，", "这是一个比较长的分句内容，", "后面还有。"},
+		},
+		{
+			name:   "long text falls back to the last boundary",
+			config: SegmenterConfig{MinRunes: 8, MaxRunes: 12},
+			chunks: []string{"好。一二，三四五六七八九十甲乙"},
+			want:   []string{"好。", "一二，", "三四五六七八九十甲乙"},
+		},
+		{
+			name:   "min above max uses min",
+			config: SegmenterConfig{MinRunes: 6, MaxRunes: 3},
+			chunks: []string{"好。一二三四五六七"},
+			want:   []string{"好。", "一二三四五六", "七"},
+		},
+		{
+			name:   "text without punctuation is flushed",
+			chunks: []string{"好的没问题"},
+			want:   []string{"好的没问题"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segmenter := NewSegmenter(tt.config)
+			var got []string
+			for _, chunk := range tt.chunks {
+				got = append(got, segmenter.Push(chunk)...)
+			}
+			if rest := segmenter.Flush(); rest != "" {
+				got = append(got, rest)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("segments = %q, want %q", got, tt.want)
+			}
+			if joined := strings.Join(got, ""); joined != strings.Join(tt.chunks, "") {
+				t.Errorf("joined segments = %q, want the input back", joined)
+			}
+		})
+	}
+}
+
+func TestSegmenterStreamedByRune(t *testing.T) {
+	text := "好的，您的订单号是A12.5B。Dr. Li will call at 10:30, e.g. tomorrow. 谢谢！"
+	whole := NewSegmenter(SegmenterConfig{})
+	want := append(whole.Push(text), whole.Flush())
+
+	// Streaming one rune at a time must give the same segments as one chunk
+	streamed := NewSegmenter(SegmenterConfig{})
+	var got []string
+	for _, r := range text {
+		got = append(got, streamed.Push(string(r))...)
+	}
+	got = append(got, streamed.Flush())
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("streamed segments = %q, want %q", got, want)
+	}
+}
+
+func TestSegmenterPushWords(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+		want   []string
+	}{
+		{
+			name:   "chinese is sent as it comes",
+			chunks: []string{"您好", "，请问"},
+			want:   []string{"您好", "，请问"},
+		},
+		{
+			name:   "numbers are held until complete",
+			chunks: []string{"总价3", ".5", "元，电话138-", "1234-5678"},
+			want:   []string{"总价", "3.5元，电话", "138-1234-5678"},
+		},
+		{
+			name:   "english words are held until the next word",
+			chunks: []string{"Hel", "lo wor", "ld, see ", "you."},
+			want:   []string{"Hello ", "world, ", "see ", "you."},
+		},
+		{
+			name:   "markup is held with its word",
+			chunks: []string{"see **bo", "ld** now"},
+			want:   []string{"see ", "**bold** ", "now"},
+		},
+		{
+			name:   "links are held until closed",
+			chunks: []string{"详见[帮助", "中心](https://help.", "example.com/a)，谢谢"},
+			want:   []string{"详见", "[帮助中心](https://help.example.com/a)，谢谢"},
+		},
+		{
+			name:   "brackets without a url are text",
+			chunks: []string{"选项[A", "]和[B]", "都可以"},
+			want:   []string{"选项", "[A]和", "[B]都可以"},
+		},
+		{
+			name:   "text after a space is not cut like a list item",
+			chunks: []string{"5 ", "- 3 = 2"},
+			want:   []string{"5 - 3 = ", "2"},
+		},
+		{
+			name:   "markers at a line start are sent with their line",
+			chunks: []string{"步骤：\n", "- 第一步"},
+			want:   []string{"步骤：\n", "- 第一步"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segmenter := NewSegmenter(SegmenterConfig{})
+			var got []string
+			for _, chunk := range tt.chunks {
+				if words := segmenter.PushWords(chunk); words != "" {
+					got = append(got, words)
+				}
+			}
+			if rest := segmenter.Flush(); rest != "" {
+				got = append(got, rest)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("words = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSegmenterPushWordsNormalize(t *testing.T) {
+	// streamed text normalized chunk by chunk must sound the same as the whole reply
+	tests := []struct {
+		language string
+		reply    string
+	}{
+		{language: "zh", reply: "请**务必**带上`身份证`，详见[帮助中心](https://help.example.com/a)。"},
+		{language: "zh", reply: "## 办理步骤\n- 第一步：登录\n- 第二步：付款1,299.00元"},
+		{language: "zh", reply: "> 提示：10:05前到店，电话400-123-4567"},
+		{language: "en", reply: "Total is 5 - 3 = 2, see www.example.com/help for more."},
+	}
+	for _, tt := range tests {
+		for size := 1; size <= 5; size++ {
+			t.Run(fmt.Sprintf("%s/%d", tt.reply, size), func(t *testing.T) {
+				normalizer := normalize.New(tt.language, nil)
+				segmenter := NewSegmenter(SegmenterConfig{})
+				var got strings.Builder
+				runes := []rune(tt.reply)
+				for i := 0; i < len(runes); i += size {
+					chunk := string(runes[i:min(i+size, len(runes))])
+					got.WriteString(normalizer.Normalize(segmenter.PushWords(chunk)))
+				}
+				got.WriteString(normalizer.Normalize(segmenter.Flush()))
+				if want := normalizer.Normalize(tt.reply); got.String() != want {
+					t.Errorf("streamed = %q, want %q", got.String(), want)
+				}
+			})
+		}
+	}
+}
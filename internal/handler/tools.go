@@ -0,0 +1,256 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// DefaultToolTimeout bounds a single tool call when the tool does not set its own timeout
+const DefaultToolTimeout = 10 * time.Second
+
+// HangupToolName is the built-in tool the LLM calls to end the conversation
+const HangupToolName = "hangup"
+
+// TransferToolName is the built-in tool the LLM calls to hand the call over to a human agent
+const TransferToolName = "transfer"
+
+// CollectDigitsToolName is the built-in tool the LLM calls to read digits typed on the keypad
+const CollectDigitsToolName = "collect_digits"
+
+// collectDigitsTimeout bounds a whole digit collection, the caller pausing ends it earlier
+const collectDigitsTimeout = 2 * time.Minute
+
+// ToolOutput is the result of a tool call
+type ToolOutput struct {
+	// Content is returned to the LLM as the tool message
+	Content string
+	// Stop ends generation for this turn after the tool call, e.g. hangup
+	Stop bool
+}
+
+// Tool is a function the LLM can call during a conversation
+type Tool interface {
+	// Definition describes the tool to the LLM, Parameters is a JSON schema
+	Definition() openai.FunctionDefinition
+	// Timeout bounds a single call, zero means DefaultToolTimeout
+	Timeout() time.Duration
+	// Call executes the tool with the JSON arguments produced by the LLM
+	Call(ctx context.Context, arguments string) (ToolOutput, error)
+}
+
+// FuncTool adapts a plain function to the Tool interface, used for built-in tools
+type FuncTool struct {
+	Def         openai.FunctionDefinition
+	CallTimeout time.Duration
+	Fn          func(ctx context.Context, arguments string) (ToolOutput, error)
+}
+
+func (t *FuncTool) Definition() openai.FunctionDefinition {
+	return t.Def
+}
+
+func (t *FuncTool) Timeout() time.Duration {
+	return t.CallTimeout
+}
+
+func (t *FuncTool) Call(ctx context.Context, arguments string) (ToolOutput, error) {
+	return t.Fn(ctx, arguments)
+}
+
+// ToolRegistry holds the tools enabled for one conversation
+type ToolRegistry struct {
+	mutex sync.RWMutex
+	tools map[string]Tool
+}
+
+// NewToolRegistry creates an empty tool registry
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{tools: map[string]Tool{}}
+}
+
+// Register adds a tool, replacing any tool with the same name
+func (r *ToolRegistry) Register(tool Tool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.tools[tool.Definition().Name] = tool
+}
+
+// Get returns the tool with the given name
+func (r *ToolRegistry) Get(name string) (Tool, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	tool, ok := r.tools[name]
+	return tool, ok
+}
+
+// OpenAITools returns the tool definitions for a chat completion request, sorted by name
+func (r *ToolRegistry) OpenAITools() []openai.Tool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	names := make([]string, 0, len(r.tools))
+	for name := range r.tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	tools := make([]openai.Tool, 0, len(names))
+	for _, name := range names {
+		definition := r.tools[name].Definition()
+		tools = append(tools, openai.Tool{
+			Type:     openai.ToolTypeFunction,
+			Function: &definition,
+		})
+	}
+	return tools
+}
+
+// Execute runs a tool call with its timeout. Errors are reported back to the LLM
+// as the tool content so the model can recover instead of failing the turn.
+func (r *ToolRegistry) Execute(ctx context.Context, call openai.ToolCall) ToolOutput {
+	tool, ok := r.Get(call.Function.Name)
+	if !ok {
+		return ToolOutput{Content: fmt.Sprintf(`{"error":"unknown tool %s"}`, call.Function.Name)}
+	}
+	timeout := tool.Timeout()
+	if timeout <= 0 {
+		timeout = DefaultToolTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	arguments := call.Function.Arguments
+	if arguments == "" {
+		arguments = "{}"
+	}
+	output, err := tool.Call(ctx, arguments)
+	if err != nil {
+		content, _ := json.Marshal(map[string]string{"error": err.Error()})
+		return ToolOutput{Content: string(content), Stop: output.Stop}
+	}
+	return output
+}
+
+// NewHangupTool creates the built-in hangup tool, onHangup receives the reason given by the LLM
+func NewHangupTool(onHangup func(reason string)) Tool {
+	return &FuncTool{
+		Def: openai.FunctionDefinition{
+			Name:        HangupToolName,
+			Description: "End the conversation and hang up the call",
+			Parameters: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"reason": {
+						"type": "string",
+						"description": "Reason for hanging up the call"
+					}
+				},
+				"required": []
+			}`),
+		},
+		Fn: func(ctx context.Context, arguments string) (ToolOutput, error) {
+			var hangupTool HangupTool
+			if err := json.Unmarshal([]byte(arguments), &hangupTool); err != nil {
+				return ToolOutput{Stop: true}, fmt.Errorf("invalid hangup arguments: %w", err)
+			}
+			if onHangup != nil {
+				onHangup(hangupTool.Reason)
+			}
+			return ToolOutput{Content: `{"status":"hanging up"}`, Stop: true}, nil
+		},
+	}
+}
+
+// NewTransferTool creates the built-in transfer tool, onTransfer receives the reason given by the LLM
+// and returns the handoff text said to the caller, which goes back to the LLM as the tool result.
+// When onTransfer fails the error is returned to the LLM so it can keep serving the caller.
+func NewTransferTool(onTransfer func(reason string) (string, error)) Tool {
+	return &FuncTool{
+		Def: openai.FunctionDefinition{
+			Name:        TransferToolName,
+			Description: "Transfer the call to a human agent when the caller asks for one or the request cannot be handled",
+			Parameters: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"reason": {
+						"type": "string",
+						"description": "Reason for transferring the call"
+					}
+				},
+				"required": []
+			}`),
+		},
+		Fn: func(ctx context.Context, arguments string) (ToolOutput, error) {
+			var args struct {
+				Reason string `json:"reason"`
+			}
+			if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+				return ToolOutput{}, fmt.Errorf("invalid transfer arguments: %w", err)
+			}
+			result := map[string]string{"status": "transferring"}
+			if onTransfer != nil {
+				handoff, err := onTransfer(args.Reason)
+				if err != nil {
+					return ToolOutput{}, err
+				}
+				result["handoff"] = handoff
+			}
+			content, err := json.Marshal(result)
+			if err != nil {
+				return ToolOutput{}, err
+			}
+			return ToolOutput{Content: string(content), Stop: true}, nil
+		},
+	}
+}
+
+// DigitsResult is what the caller typed on the keypad, returned to the LLM as the tool content
+type DigitsResult struct {
+	Digits string `json:"digits"`
+	// Status tells how collection ended: terminator, max_digits or timeout
+	Status string `json:"status"`
+}
+
+// NewCollectDigitsTool creates the built-in tool that waits for the caller to type digits on the
+// keypad, e.g. an account number. onCollect blocks until the digits are collected or ctx is done.
+func NewCollectDigitsTool(onCollect func(ctx context.Context, maxDigits int) (DigitsResult, error)) Tool {
+	return &FuncTool{
+		Def: openai.FunctionDefinition{
+			Name: CollectDigitsToolName,
+			Description: "Wait for the caller to enter digits on the phone keypad, such as an account or order number. " +
+				"Ask the caller to enter them before calling this tool.",
+			Parameters: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"max_digits": {
+						"type": "integer",
+						"description": "Number of digits expected, collection stops once reached. Omit when the length varies"
+					}
+				},
+				"required": []
+			}`),
+		},
+		CallTimeout: collectDigitsTimeout,
+		Fn: func(ctx context.Context, arguments string) (ToolOutput, error) {
+			var args struct {
+				MaxDigits int `json:"max_digits"`
+			}
+			if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+				return ToolOutput{}, fmt.Errorf("invalid collect_digits arguments: %w", err)
+			}
+			result, err := onCollect(ctx, args.MaxDigits)
+			if err != nil {
+				return ToolOutput{}, err
+			}
+			content, err := json.Marshal(result)
+			if err != nil {
+				return ToolOutput{}, err
+			}
+			return ToolOutput{Content: string(content)}, nil
+		},
+	}
+}
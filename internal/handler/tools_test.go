@@ -0,0 +1,284 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/sirupsen/logrus"
+)
+
+func TestMergeToolCallDelta(t *testing.T) {
+	index := func(i int) *int { return &i }
+	tests := []struct {
+		name   string
+		deltas []openai.ToolCall
+		want   []openai.ToolCall
+	}{
+		{
+			name: "arguments split across chunks",
+			deltas: []openai.ToolCall{
+				{Index: index(0), ID: "call_1", Type: openai.ToolTypeFunction, Function: openai.FunctionCall{Name: "lookup", Arguments: `{"order`}},
+				{Index: index(0), Function: openai.FunctionCall{Arguments: `_id":"A1"}`}},
+			},
+			want: []openai.ToolCall{
+				{ID: "call_1", Type: openai.ToolTypeFunction, Function: openai.FunctionCall{Name: "lookup", Arguments: `{"order_id":"A1"}`}},
+			},
+		},
+		{
+			name: "parallel calls by index",
+			deltas: []openai.ToolCall{
+				{Index: index(0), ID: "call_1", Function: openai.FunctionCall{Name: "lookup", Arguments: `{"a":`}},
+				{Index: index(1), ID: "call_2", Function: openai.FunctionCall{Name: "weather", Arguments: `{"city":`}},
+				{Index: index(0), Function: openai.FunctionCall{Arguments: `1}`}},
+				{Index: index(1), Function: openai.FunctionCall{Arguments: `"上海"}`}},
+			},
+			want: []openai.ToolCall{
+				{ID: "call_1", Type: openai.ToolTypeFunction, Function: openai.FunctionCall{Name: "lookup", Arguments: `{"a":1}`}},
+				{ID: "call_2", Type: openai.ToolTypeFunction, Function: openai.FunctionCall{Name: "weather", Arguments: `{"city":"上海"}`}},
+			},
+		},
+		{
+			name: "fragments without index continue the last call",
+			deltas: []openai.ToolCall{
+				{ID: "call_1", Function: openai.FunctionCall{Name: "lookup", Arguments: `{"a"`}},
+				{Function: openai.FunctionCall{Arguments: `:1}`}},
+				{ID: "call_2", Function: openai.FunctionCall{Name: "hangup", Arguments: `{}`}},
+			},
+			want: []openai.ToolCall{
+				{ID: "call_1", Type: openai.ToolTypeFunction, Function: openai.FunctionCall{Name: "lookup", Arguments: `{"a":1}`}},
+				{ID: "call_2", Type: openai.ToolTypeFunction, Function: openai.FunctionCall{Name: "hangup", Arguments: `{}`}},
+			},
+		},
+		{
+			name: "repeated names are kept once",
+			deltas: []openai.ToolCall{
+				{Index: index(0), ID: "call_1", Function: openai.FunctionCall{Name: "lookup", Arguments: `{`}},
+				{Index: index(0), Function: openai.FunctionCall{Name: "lookup", Arguments: `}`}},
+			},
+			want: []openai.ToolCall{
+				{ID: "call_1", Type: openai.ToolTypeFunction, Function: openai.FunctionCall{Name: "lookup", Arguments: `{}`}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []openai.ToolCall
+			for _, delta := range tt.deltas {
+				got = mergeToolCallDelta(got, delta)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("calls = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// recordingTool answers every call with content or err and records the arguments
+type recordingTool struct {
+	name      string
+	content   string
+	err       error
+	stop      bool
+	arguments []string
+}
+
+func (t *recordingTool) Definition() openai.FunctionDefinition {
+	return openai.FunctionDefinition{Name: t.name, Parameters: json.RawMessage(`{"type":"object","properties":{}}`)}
+}
+
+func (t *recordingTool) Timeout() time.Duration { return 0 }
+
+func (t *recordingTool) Call(ctx context.Context, arguments string) (ToolOutput, error) {
+	t.arguments = append(t.arguments, arguments)
+	return ToolOutput{Content: t.content, Stop: t.stop}, t.err
+}
+
+// ttsCall is one call of the TTS callback
+type ttsCall struct {
+	segment     string
+	autoHangup  bool
+	endOfStream bool
+}
+
+func TestQueryStreamToolRounds(t *testing.T) {
+	lookup := ScriptedToolCall{Name: "lookup", Arguments: `{"order_id":"A1"}`}
+	tests := []struct {
+		name    string
+		replies []ScriptedReply
+		tool    recordingTool
+		// want is the reply text, calls the requests the provider answered
+		want  string
+		calls int
+		// roles are the roles of the history after the system prompt
+		roles []string
+		// toolContent is the tool message fed back to the LLM
+		toolContent string
+		// last is the final TTS call of the turn
+		last ttsCall
+	}{
+		{
+			name:        "tool result goes back to the LLM",
+			replies:     []ScriptedReply{{Content: "稍等，", ToolCalls: []ScriptedToolCall{lookup}}, {Content: "订单已发货。"}},
+			tool:        recordingTool{name: "lookup", content: `{"status":"shipped"}`},
+			want:        "稍等，订单已发货。",
+			calls:       2,
+			roles:       []string{"user", "assistant", "tool", "assistant"},
+			toolContent: `{"status":"shipped"}`,
+			last:        ttsCall{endOfStream: true},
+		},
+		{
+			name:        "tool errors are reported to the LLM",
+			replies:     []ScriptedReply{{ToolCalls: []ScriptedToolCall{lookup}}, {Content: "暂时查不到。"}},
+			tool:        recordingTool{name: "lookup", err: errors.New("order service down")},
+			want:        "暂时查不到。",
+			calls:       2,
+			roles:       []string{"user", "assistant", "tool", "assistant"},
+			toolContent: `{"error":"order service down"}`,
+			last:        ttsCall{endOfStream: true},
+		},
+		{
+			name:        "unknown tools are reported to the LLM",
+			replies:     []ScriptedReply{{ToolCalls: []ScriptedToolCall{{Name: "refund"}}}, {Content: "无法退款。"}},
+			tool:        recordingTool{name: "lookup"},
+			want:        "无法退款。",
+			calls:       2,
+			roles:       []string{"user", "assistant", "tool", "assistant"},
+			toolContent: `{"error":"unknown tool refund"}`,
+			last:        ttsCall{endOfStream: true},
+		},
+		{
+			name:        "a stopping tool ends the turn",
+			replies:     []ScriptedReply{{Content: "再见。", ToolCalls: []ScriptedToolCall{{Name: HangupToolName}}}, {Content: "不应请求"}},
+			tool:        recordingTool{name: "lookup"},
+			want:        "再见。",
+			calls:       1,
+			roles:       []string{"user", "assistant", "tool"},
+			toolContent: `{"status":"hanging up"}`,
+			last:        ttsCall{autoHangup: true, endOfStream: true},
+		},
+		{
+			name:        "tool rounds are bounded",
+			replies:     []ScriptedReply{{ToolCalls: []ScriptedToolCall{lookup}}},
+			tool:        recordingTool{name: "lookup", content: `{}`},
+			calls:       maxToolRounds,
+			roles:       append([]string{"user"}, strings.Fields(strings.Repeat("assistant tool ", maxToolRounds))...),
+			toolContent: `{}`,
+			last:        ttsCall{endOfStream: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := NewScriptedProvider(tt.replies...)
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+			h := NewLLMHandlerWithProvider(context.Background(), provider, "rule", logger)
+			tool := tt.tool
+			tools := NewToolRegistry()
+			tools.Register(NewHangupTool(nil))
+			tools.Register(&tool)
+			h.SetTools(tools)
+
+			var spoken []ttsCall
+			got, err := h.QueryStream("", "查订单", func(segment, playID string, autoHangup, endOfStream bool) error {
+				spoken = append(spoken, ttsCall{segment: segment, autoHangup: autoHangup, endOfStream: endOfStream})
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("reply = %q, want %q", got, tt.want)
+			}
+			if provider.Calls() != tt.calls {
+				t.Errorf("provider calls = %d, want %d", provider.Calls(), tt.calls)
+			}
+			if len(tool.arguments) > 0 && tool.arguments[0] != lookup.Arguments {
+				t.Errorf("tool arguments = %q, want %q", tool.arguments[0], lookup.Arguments)
+			}
+
+			var roles []string
+			var toolContent string
+			for _, message := range h.messages[1:] {
+				roles = append(roles, message.Role)
+				if message.Role == openai.ChatMessageRoleTool {
+					toolContent = message.Content
+				}
+			}
+			if !reflect.DeepEqual(roles, tt.roles) {
+				t.Errorf("history roles = %v, want %v", roles, tt.roles)
+			}
+			if toolContent != tt.toolContent {
+				t.Errorf("tool message = %q, want %q", toolContent, tt.toolContent)
+			}
+			if len(spoken) == 0 || spoken[len(spoken)-1] != tt.last {
+				t.Errorf("tts calls = %+v, want the last to be %+v", spoken, tt.last)
+			}
+		})
+	}
+}
+
+func TestWebhookTool(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		delay   time.Duration
+		timeout time.Duration
+		// want is the tool content returned to the LLM
+		want string
+	}{
+		{name: "response body", status: http.StatusOK, body: `{"status":"shipped"}`, want: `{"status":"shipped"}`},
+		{name: "empty response", status: http.StatusNoContent, want: `{"status":"ok"}`},
+		{name: "error status", status: http.StatusBadGateway, body: "upstream down", want: `{"error":"webhook lookup returned status 502: upstream down"}`},
+		{name: "timeout", status: http.StatusOK, delay: time.Second, timeout: 50 * time.Millisecond, want: `context deadline exceeded`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var method, contentType, token, body string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				method = r.Method
+				contentType = r.Header.Get("Content-Type")
+				token = r.Header.Get("X-Token")
+				raw, _ := io.ReadAll(r.Body)
+				body = string(raw)
+				select {
+				case <-time.After(tt.delay):
+				case <-r.Context().Done():
+					return
+				}
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			tool := NewWebhookTool("lookup", "look up an order", nil, server.URL, "", map[string]string{"X-Token": "secret"}, tt.timeout)
+			tools := NewToolRegistry()
+			tools.Register(tool)
+			output := tools.Execute(context.Background(), openai.ToolCall{
+				ID:       "call_1",
+				Function: openai.FunctionCall{Name: "lookup", Arguments: `{"order_id":"A1"}`},
+			})
+			if !strings.Contains(output.Content, tt.want) {
+				t.Errorf("content = %q, want %q", output.Content, tt.want)
+			}
+			if tt.delay > 0 {
+				return
+			}
+			if method != http.MethodPost || contentType != "application/json" || token != "secret" {
+				t.Errorf("request = %s %s token %q, want POST application/json token %q", method, contentType, token, "secret")
+			}
+			if body != `{"order_id":"A1"}` {
+				t.Errorf("request body = %q, want the tool arguments", body)
+			}
+		})
+	}
+}
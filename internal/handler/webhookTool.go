@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// maxWebhookResponseSize limits how much of a webhook response is fed back to the LLM
+const maxWebhookResponseSize = 8 * 1024
+
+// WebhookTool calls a user-defined HTTP endpoint with the LLM's arguments as the JSON body
+type WebhookTool struct {
+	Name        string
+	Description string
+	Parameters  json.RawMessage
+	URL         string
+	Method      string
+	Headers     map[string]string
+	CallTimeout time.Duration
+	client      *http.Client
+}
+
+// NewWebhookTool creates a webhook tool, method defaults to POST
+func NewWebhookTool(name, description string, parameters json.RawMessage, url, method string, headers map[string]string, timeout time.Duration) *WebhookTool {
+	if method == "" {
+		method = http.MethodPost
+	}
+	if len(parameters) == 0 {
+		parameters = json.RawMessage(`{"type":"object","properties":{}}`)
+	}
+	return &WebhookTool{
+		Name:        name,
+		Description: description,
+		Parameters:  parameters,
+		URL:         url,
+		Method:      method,
+		Headers:     headers,
+		CallTimeout: timeout,
+		client:      &http.Client{},
+	}
+}
+
+func (t *WebhookTool) Definition() openai.FunctionDefinition {
+	return openai.FunctionDefinition{
+		Name:        t.Name,
+		Description: t.Description,
+		Parameters:  t.Parameters,
+	}
+}
+
+func (t *WebhookTool) Timeout() time.Duration {
+	return t.CallTimeout
+}
+
+func (t *WebhookTool) Call(ctx context.Context, arguments string) (ToolOutput, error) {
+	req, err := http.NewRequestWithContext(ctx, t.Method, t.URL, bytes.NewBufferString(arguments))
+	if err != nil {
+		return ToolOutput{}, fmt.Errorf("create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range t.Headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return ToolOutput{}, fmt.Errorf("call webhook %s: %w", t.Name, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxWebhookResponseSize))
+	if err != nil {
+		return ToolOutput{}, fmt.Errorf("read webhook %s response: %w", t.Name, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return ToolOutput{}, fmt.Errorf("webhook %s returned status %d: %s", t.Name, resp.StatusCode, string(body))
+	}
+	if len(body) == 0 {
+		body = []byte(`{"status":"ok"}`)
+	}
+	return ToolOutput{Content: string(body)}, nil
+}
@@ -0,0 +1,77 @@
+package model
+
+import (
+	"time"
+)
+
+// CallRecord 通话记录，与call_records表映射
+type CallRecord struct {
+	ID              uint       `gorm:"column:id;primaryKey" json:"id"`                                   // 主键ID
+	CallID          string     `gorm:"column:call_id;size:64;not null;unique" json:"call_id"`            // 通话唯一标识
+	UserID          uint       `gorm:"column:user_id;not null;index" json:"user_id"`                     // 关联用户ID
+	RobotID         uint       `gorm:"column:robot_id;index" json:"robot_id"`                            // 接听的机器人ID
+	RobotKeyID      uint       `gorm:"column:robot_key_id" json:"robot_key_id"`                          // 使用的机器人密钥ID
+	Direction       string     `gorm:"column:direction;size:20" json:"direction"`                        // 呼叫方向：webrtc|inbound|outbound
+	Caller          string     `gorm:"column:caller;size:255" json:"caller"`                             // 主叫
+	Callee          string     `gorm:"column:callee;size:255" json:"callee"`                             // 被叫
+	Status          string     `gorm:"column:status;size:20" json:"status"`                              // 通话状态
+	HangupReason    string     `gorm:"column:hangup_reason;size:255" json:"hangup_reason"`               // 挂断原因
+	HangupInitiator string     `gorm:"column:hangup_initiator;size:50" json:"hangup_initiator"`          // 挂断发起方
+	TransferTarget  string     `gorm:"column:transfer_target;size:255" json:"transfer_target,omitempty"` // 转人工目标
+	TransferStatus  string     `gorm:"column:transfer_status;size:20" json:"transfer_status,omitempty"`  // 转人工状态
+	AnsweredBy      string     `gorm:"column:answered_by;size:20" json:"answered_by,omitempty"`          // 外呼接听方：human|machine
+	TurnCount       int        `gorm:"column:turn_count" json:"turn_count"`                              // 机器人回答的用户发言轮数
+	TurnState       string     `gorm:"column:turn_state;size:20" json:"turn_state,omitempty"`            // 最近一轮发言的状态
+	SupersededTurns int        `gorm:"column:superseded_turns" json:"superseded_turns"`                  // 被新发言取代而未答完的轮数
+	IgnoredTurns    int        `gorm:"column:ignored_turns" json:"ignored_turns"`                        // 机器人回答期间被忽略的发言数
+	StartedAt       time.Time  `gorm:"column:started_at" json:"started_at"`                              // 发起时间
+	AnsweredAt      *time.Time `gorm:"column:answered_at" json:"answered_at,omitempty"`                  // 接通时间
+	EndedAt         *time.Time `gorm:"column:ended_at" json:"ended_at,omitempty"`                        // 结束时间
+	Duration        int64      `gorm:"column:duration" json:"duration"`                                  // 接通时长（秒）
+	CreatedAt       time.Time  `gorm:"column:created_at" json:"created_at"`                              // 创建时间
+	UpdatedAt       time.Time  `gorm:"column:updated_at" json:"updated_at"`                              // 更新时间
+}
+
+// 通话方向
+const (
+	CallDirectionWebRTC   = "webrtc"
+	CallDirectionInbound  = "inbound"
+	CallDirectionOutbound = "outbound"
+)
+
+// 通话状态
+const (
+	CallStatusCalling  = "calling"  // 呼叫中
+	CallStatusAnswered = "answered" // 已接通
+	CallStatusEnded    = "ended"    // 已结束
+	CallStatusFailed   = "failed"   // 未接通
+)
+
+// 外呼接听方，由答录机检测得出
+const (
+	AnsweredByHuman   = "human"   // 真人接听
+	AnsweredByMachine = "machine" // 答录机接听
+)
+
+// 转人工状态
+const (
+	TransferStatusTransferring = "transferring" // 转接中
+	TransferStatusTransferred  = "transferred"  // 坐席已接听
+	TransferStatusFailed       = "failed"       // 转接失败，由机器人继续服务
+)
+
+// 用户发言轮次的状态
+const (
+	TurnStateThinking    = "thinking"    // 等待大模型回复
+	TurnStateSpeaking    = "speaking"    // 回复已开始播报
+	TurnStateDone        = "done"        // 回复完成
+	TurnStateInterrupted = "interrupted" // 回复播报中被用户打断
+	TurnStateMerged      = "merged"      // 回复播报前用户继续说话，与后续发言合并
+	TurnStateSuperseded  = "superseded"  // 被新的发言取代，剩余回复丢弃
+	TurnStateFailed      = "failed"      // 大模型调用失败
+)
+
+// TableName 自定义表名
+func (CallRecord) TableName() string {
+	return "call_records"
+}
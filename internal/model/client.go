@@ -128,17 +128,20 @@ type DTMFEvent struct {
 
 type TrackStartEvent struct {
 	TrackID   string `json:"trackId"`
+	PlayID    string `json:"playId,omitempty"`
 	Timestamp uint64 `json:"timestamp"`
 }
 
 type TrackEndEvent struct {
 	TrackID   string `json:"trackId"`
+	PlayID    string `json:"playId,omitempty"`
 	Timestamp uint64 `json:"timestamp"`
 	Duration  uint64 `json:"duration"`
 }
 
 type InterruptionEvent struct {
 	TrackID   string `json:"trackId"`
+	PlayID    string `json:"playId,omitempty"`
 	Timestamp uint64 `json:"timestamp"`
 	Position  uint64 `json:"position"`
 }
@@ -0,0 +1,25 @@
+package model
+
+import (
+	"time"
+)
+
+// PhoneNumber 呼入号码配置，将被叫的DID号码或SIP URI路由到机器人，与phone_numbers表映射
+type PhoneNumber struct {
+	ID         uint     `gorm:"column:id;primaryKey" json:"id"`                                // 主键ID
+	UserID     uint     `gorm:"column:user_id;not null;index" json:"user_id"`                  // 关联用户ID
+	Number     string   `gorm:"column:number;size:255;not null;unique" json:"number"`          // 被叫号码或SIP URI（如sip:1001@pbx.example.com）
+	RobotID    uint     `gorm:"column:robot_id;not null" json:"robot_id"`                      // 接听的机器人ID
+	RobotKeyID uint     `gorm:"column:robot_key_id;not null" json:"robot_key_id"`              // 使用的机器人密钥ID
+	Enabled    bool     `gorm:"column:enabled" json:"enabled"`                                 // 是否接听呼入
+	AllowList  []string `gorm:"column:allow_list;type:text;serializer:json" json:"allow_list"` // 允许呼入的主叫号码（为空不限制），以*结尾按前缀匹配
+	BlockList  []string `gorm:"column:block_list;type:text;serializer:json" json:"block_list"` // 拒绝呼入的主叫号码，以*结尾按前缀匹配
+
+	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"` // 创建时间
+	UpdatedAt time.Time `gorm:"column:updated_at" json:"updated_at"` // 更新时间
+}
+
+// TableName 自定义表名
+func (PhoneNumber) TableName() string {
+	return "phone_numbers"
+}
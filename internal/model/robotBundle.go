@@ -0,0 +1,186 @@
+package model
+
+import "time"
+
+// RobotBundleVersion 当前导出格式版本，格式不兼容变更时递增
+const RobotBundleVersion = 1
+
+// RobotBundle 机器人导入导出的文档格式，只包含机器人自身配置，不包含任何密钥信息。
+// 机器人目前没有修订历史和提示词片段，文档中不包含这两部分，支持后需递增RobotBundleVersion
+type RobotBundle struct {
+	Version    int       `json:"version" yaml:"version"`
+	ExportedAt time.Time `json:"exported_at" yaml:"exported_at"`
+	Robot      RobotSpec `json:"robot" yaml:"robot"`
+}
+
+// RobotSpec 可迁移的机器人配置，不含ID、用户和时间等环境相关字段
+type RobotSpec struct {
+	Name                string             `json:"name" yaml:"name"`
+	Speed               float32            `json:"speed" yaml:"speed"`
+	Volume              int                `json:"volume" yaml:"volume"`
+	Speaker             string             `json:"speaker" yaml:"speaker"`
+	TTSProvider         string             `json:"tts_provider,omitempty" yaml:"tts_provider,omitempty"`
+	SampleRate          int                `json:"sample_rate,omitempty" yaml:"sample_rate,omitempty"`
+	Emotion             string             `json:"emotion,omitempty" yaml:"emotion,omitempty"`
+	SystemPrompt        string             `json:"system_prompt" yaml:"system_prompt"`
+	GreetingType        string             `json:"greeting_type,omitempty" yaml:"greeting_type,omitempty"`
+	Greeting            string             `json:"greeting,omitempty" yaml:"greeting,omitempty"`
+	GreetingURL         string             `json:"greeting_url,omitempty" yaml:"greeting_url,omitempty"`
+	Timezone            string             `json:"timezone,omitempty" yaml:"timezone,omitempty"`
+	TemplateStrict      bool               `json:"template_strict,omitempty" yaml:"template_strict,omitempty"`
+	Tools               []RobotTool        `json:"tools,omitempty" yaml:"tools,omitempty"`
+	Transfer            *RobotTransfer     `json:"transfer,omitempty" yaml:"transfer,omitempty"`
+	BargeIn             bool               `json:"barge_in,omitempty" yaml:"barge_in,omitempty"`
+	BargeInMinMs        int                `json:"barge_in_min_ms,omitempty" yaml:"barge_in_min_ms,omitempty"`
+	EouWindowMs         int                `json:"eou_window_ms,omitempty" yaml:"eou_window_ms,omitempty"`
+	EouType             string             `json:"eou_type,omitempty" yaml:"eou_type,omitempty"`
+	TurnPolicy          string             `json:"turn_policy,omitempty" yaml:"turn_policy,omitempty"`
+	Fillers             []RobotFiller      `json:"fillers,omitempty" yaml:"fillers,omitempty"`
+	FillerDelayMs       int                `json:"filler_delay_ms,omitempty" yaml:"filler_delay_ms,omitempty"`
+	SilenceTimeoutSec   int                `json:"silence_timeout_sec,omitempty" yaml:"silence_timeout_sec,omitempty"`
+	SilenceReprompt     string             `json:"silence_reprompt,omitempty" yaml:"silence_reprompt,omitempty"`
+	SilenceMaxReprompts int                `json:"silence_max_reprompts,omitempty" yaml:"silence_max_reprompts,omitempty"`
+	IdleGoodbye         string             `json:"idle_goodbye,omitempty" yaml:"idle_goodbye,omitempty"`
+	MaxCallDurationSec  int                `json:"max_call_duration_sec,omitempty" yaml:"max_call_duration_sec,omitempty"`
+	DurationWarningSec  int                `json:"duration_warning_sec,omitempty" yaml:"duration_warning_sec,omitempty"`
+	DurationWarning     string             `json:"duration_warning,omitempty" yaml:"duration_warning,omitempty"`
+	DurationGoodbye     string             `json:"duration_goodbye,omitempty" yaml:"duration_goodbye,omitempty"`
+	Dtmf                *RobotDtmf         `json:"dtmf,omitempty" yaml:"dtmf,omitempty"`
+	AmdPolicy           string             `json:"amd_policy,omitempty" yaml:"amd_policy,omitempty"`
+	VoicemailText       string             `json:"voicemail_text,omitempty" yaml:"voicemail_text,omitempty"`
+	VoicemailURL        string             `json:"voicemail_url,omitempty" yaml:"voicemail_url,omitempty"`
+	StreamingTTS        bool               `json:"streaming_tts,omitempty" yaml:"streaming_tts,omitempty"`
+	SegmentMinRunes     int                `json:"segment_min_runes,omitempty" yaml:"segment_min_runes,omitempty"`
+	SegmentMaxRunes     int                `json:"segment_max_runes,omitempty" yaml:"segment_max_runes,omitempty"`
+	Lexicon             map[string]string  `json:"lexicon,omitempty" yaml:"lexicon,omitempty"`
+	ContextMaxTokens    int                `json:"context_max_tokens,omitempty" yaml:"context_max_tokens,omitempty"`
+	ContextStrategy     string             `json:"context_strategy,omitempty" yaml:"context_strategy,omitempty"`
+	LLMEndpoints        []RobotLLMEndpoint `json:"llm_endpoints,omitempty" yaml:"llm_endpoints,omitempty"`
+	LLMFallbackText     string             `json:"llm_fallback_text,omitempty" yaml:"llm_fallback_text,omitempty"`
+}
+
+// NewRobotSpec 从机器人记录提取可迁移配置
+func NewRobotSpec(robot *Robot) RobotSpec {
+	return RobotSpec{
+		Name:                robot.Name,
+		Speed:               robot.Speed,
+		Volume:              robot.Volume,
+		Speaker:             robot.Speaker,
+		TTSProvider:         robot.TTSProvider,
+		SampleRate:          robot.SampleRate,
+		Emotion:             robot.Emotion,
+		SystemPrompt:        robot.SystemPrompt,
+		GreetingType:        robot.GreetingType,
+		Greeting:            robot.Greeting,
+		GreetingURL:         robot.GreetingURL,
+		Timezone:            robot.Timezone,
+		TemplateStrict:      robot.TemplateStrict,
+		Tools:               exportTools(robot.Tools),
+		Transfer:            robot.Transfer,
+		BargeIn:             robot.BargeIn,
+		BargeInMinMs:        robot.BargeInMinMs,
+		EouWindowMs:         robot.EouWindowMs,
+		EouType:             robot.EouType,
+		TurnPolicy:          robot.TurnPolicy,
+		Fillers:             robot.Fillers,
+		FillerDelayMs:       robot.FillerDelayMs,
+		SilenceTimeoutSec:   robot.SilenceTimeoutSec,
+		SilenceReprompt:     robot.SilenceReprompt,
+		SilenceMaxReprompts: robot.SilenceMaxReprompts,
+		IdleGoodbye:         robot.IdleGoodbye,
+		MaxCallDurationSec:  robot.MaxCallDurationSec,
+		DurationWarningSec:  robot.DurationWarningSec,
+		DurationWarning:     robot.DurationWarning,
+		DurationGoodbye:     robot.DurationGoodbye,
+		Dtmf:                robot.Dtmf,
+		AmdPolicy:           robot.AmdPolicy,
+		VoicemailText:       robot.VoicemailText,
+		VoicemailURL:        robot.VoicemailURL,
+		StreamingTTS:        robot.StreamingTTS,
+		SegmentMinRunes:     robot.SegmentMinRunes,
+		SegmentMaxRunes:     robot.SegmentMaxRunes,
+		Lexicon:             robot.Lexicon,
+		ContextMaxTokens:    robot.ContextMaxTokens,
+		ContextStrategy:     robot.ContextStrategy,
+		LLMEndpoints:        exportLLMEndpoints(robot.LLMEndpoints),
+		LLMFallbackText:     robot.LLMFallbackText,
+	}
+}
+
+// ApplyTo 将配置写入机器人记录，保留记录的ID、用户和时间字段
+func (spec *RobotSpec) ApplyTo(robot *Robot) {
+	robot.Name = spec.Name
+	robot.Speed = spec.Speed
+	robot.Volume = spec.Volume
+	robot.Speaker = spec.Speaker
+	robot.TTSProvider = spec.TTSProvider
+	robot.SampleRate = spec.SampleRate
+	robot.Emotion = spec.Emotion
+	robot.SystemPrompt = spec.SystemPrompt
+	robot.GreetingType = spec.GreetingType
+	robot.Greeting = spec.Greeting
+	robot.GreetingURL = spec.GreetingURL
+	robot.Timezone = spec.Timezone
+	robot.TemplateStrict = spec.TemplateStrict
+	robot.Tools = spec.Tools
+	robot.Transfer = spec.Transfer
+	robot.BargeIn = spec.BargeIn
+	robot.BargeInMinMs = spec.BargeInMinMs
+	robot.EouWindowMs = spec.EouWindowMs
+	robot.EouType = spec.EouType
+	robot.TurnPolicy = spec.TurnPolicy
+	robot.Fillers = spec.Fillers
+	robot.FillerDelayMs = spec.FillerDelayMs
+	robot.SilenceTimeoutSec = spec.SilenceTimeoutSec
+	robot.SilenceReprompt = spec.SilenceReprompt
+	robot.SilenceMaxReprompts = spec.SilenceMaxReprompts
+	robot.IdleGoodbye = spec.IdleGoodbye
+	robot.MaxCallDurationSec = spec.MaxCallDurationSec
+	robot.DurationWarningSec = spec.DurationWarningSec
+	robot.DurationWarning = spec.DurationWarning
+	robot.DurationGoodbye = spec.DurationGoodbye
+	robot.Dtmf = spec.Dtmf
+	robot.AmdPolicy = spec.AmdPolicy
+	robot.VoicemailText = spec.VoicemailText
+	robot.VoicemailURL = spec.VoicemailURL
+	robot.StreamingTTS = spec.StreamingTTS
+	robot.SegmentMinRunes = spec.SegmentMinRunes
+	robot.SegmentMaxRunes = spec.SegmentMaxRunes
+	robot.Lexicon = spec.Lexicon
+	robot.ContextMaxTokens = spec.ContextMaxTokens
+	robot.ContextStrategy = spec.ContextStrategy
+	robot.LLMEndpoints = spec.LLMEndpoints
+	robot.LLMFallbackText = spec.LLMFallbackText
+}
+
+// exportLLMEndpoints 复制备用大模型节点并清空API密钥，导入后需重新填写
+func exportLLMEndpoints(endpoints []RobotLLMEndpoint) []RobotLLMEndpoint {
+	if len(endpoints) == 0 {
+		return nil
+	}
+	exported := make([]RobotLLMEndpoint, len(endpoints))
+	for i, endpoint := range endpoints {
+		endpoint.APIKey = ""
+		exported[i] = endpoint
+	}
+	return exported
+}
+
+// exportTools 复制工具配置并清空webhook请求头的值（通常是鉴权令牌），保留请求头名称，导入后需重新填写
+func exportTools(tools []RobotTool) []RobotTool {
+	if len(tools) == 0 {
+		return nil
+	}
+	exported := make([]RobotTool, len(tools))
+	for i, tool := range tools {
+		if len(tool.Headers) > 0 {
+			headers := make(map[string]string, len(tool.Headers))
+			for name := range tool.Headers {
+				headers[name] = ""
+			}
+			tool.Headers = headers
+		}
+		exported[i] = tool
+	}
+	return exported
+}
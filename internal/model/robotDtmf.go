@@ -0,0 +1,26 @@
+package model
+
+// 按键菜单的动作
+const (
+	DtmfActionPrompt   = "prompt"   // 播报一段话术
+	DtmfActionTransfer = "transfer" // 转人工
+	DtmfActionRobot    = "robot"    // 切换到另一个机器人继续服务
+)
+
+// RobotDtmf 机器人的按键配置，以JSON形式存储在robots.dtmf字段
+type RobotDtmf struct {
+	Menu        []RobotDtmfMenuItem `json:"menu,omitempty" yaml:"menu,omitempty"`                 // 按键菜单，如按1转销售
+	InvalidText string              `json:"invalid_text,omitempty" yaml:"invalid_text,omitempty"` // 菜单中没有该按键时播报的话术（为空使用默认话术）
+	Terminator  string              `json:"terminator,omitempty" yaml:"terminator,omitempty"`     // 收集号码时的结束键（默认#）
+	TimeoutMs   int                 `json:"timeout_ms,omitempty" yaml:"timeout_ms,omitempty"`     // 收集号码时等待下一次按键的毫秒数（默认5000）
+	MaxDigits   int                 `json:"max_digits,omitempty" yaml:"max_digits,omitempty"`     // 收集号码的最多位数（默认20）
+}
+
+// RobotDtmfMenuItem 按键菜单的一项
+type RobotDtmfMenuItem struct {
+	Digit   string `json:"digit" yaml:"digit"`                           // 按键：0-9、*、#
+	Action  string `json:"action" yaml:"action"`                         // 动作：prompt|transfer|robot
+	Text    string `json:"text,omitempty" yaml:"text,omitempty"`         // prompt播报的话术，transfer和robot执行前播报的话术（可选）
+	Target  string `json:"target,omitempty" yaml:"target,omitempty"`     // transfer的转接目标（为空使用转人工配置）
+	RobotID uint   `json:"robot_id,omitempty" yaml:"robot_id,omitempty"` // robot切换到的机器人ID，需属于同一用户
+}
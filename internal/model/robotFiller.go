@@ -0,0 +1,8 @@
+package model
+
+// RobotFiller 大模型迟迟没有回复时播报的填充语，以JSON数组形式存储在robots.fillers字段，
+// 配置了音频地址时播放音频，否则合成文本
+type RobotFiller struct {
+	Text     string `json:"text,omitempty" yaml:"text,omitempty"`           // 填充语文本，如“嗯，好的”
+	AudioURL string `json:"audio_url,omitempty" yaml:"audio_url,omitempty"` // 预先录制的填充语音频地址
+}
@@ -29,6 +29,9 @@ type RobotKey struct {
 	TTSSecretID  string `gorm:"column:tts_secret_id;size:255"`  // 语音合成Secret ID
 	TTSSecretKey string `gorm:"column:tts_secret_key;size:255"` // 语音合成Secret Key
 
+	// 通话限制
+	MaxCallDurationSec int `gorm:"column:max_call_duration_sec;type:int"` // 使用该密钥的通话最长秒数（为0不限制）
+
 	// 新增API密钥
 	APIKey    string `gorm:"column:api_key;size:255"`    // API密钥
 	APISecret string `gorm:"column:api_secret;size:255"` // API密钥Secret
@@ -0,0 +1,10 @@
+package model
+
+// RobotLLMEndpoint 机器人的备用大模型节点，以JSON数组形式存储在robots.llm_endpoints字段，
+// 主节点（密钥上的大模型配置）失败后按数组顺序依次切换
+type RobotLLMEndpoint struct {
+	Provider string `json:"provider" yaml:"provider"`                   // 大模型提供商：openai|dashscope|ollama，其他名称按OpenAI兼容接口处理
+	APIKey   string `json:"api_key,omitempty" yaml:"api_key,omitempty"` // 大模型API密钥（导出时清空）
+	APIURL   string `json:"api_url,omitempty" yaml:"api_url,omitempty"` // 大模型API地址（为空使用提供商默认地址）
+	Model    string `json:"model,omitempty" yaml:"model,omitempty"`     // 模型名称（为空使用提供商默认模型）
+}
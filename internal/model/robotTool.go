@@ -0,0 +1,19 @@
+package model
+
+// 工具类型
+const (
+	ToolTypeBuiltin = "builtin" // 内置工具，如hangup
+	ToolTypeWebhook = "webhook" // 用户自定义的HTTP回调工具
+)
+
+// RobotTool 机器人启用的大模型工具配置，以JSON形式存储在robots.tools字段
+type RobotTool struct {
+	Name        string            `json:"name" yaml:"name"`                                   // 工具名称，内置工具需与内置名称一致
+	Type        string            `json:"type" yaml:"type"`                                   // 工具类型：builtin|webhook
+	Description string            `json:"description,omitempty" yaml:"description,omitempty"` // 工具描述，告诉大模型何时调用
+	Parameters  map[string]any    `json:"parameters,omitempty" yaml:"parameters,omitempty"`   // 参数的JSON Schema
+	URL         string            `json:"url,omitempty" yaml:"url,omitempty"`                 // 回调地址（webhook使用）
+	Method      string            `json:"method,omitempty" yaml:"method,omitempty"`           // 请求方法（webhook使用，默认POST）
+	Headers     map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`         // 请求头（webhook使用）
+	TimeoutMs   int               `json:"timeout_ms,omitempty" yaml:"timeout_ms,omitempty"`   // 调用超时毫秒数（默认10秒）
+}
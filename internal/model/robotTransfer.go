@@ -0,0 +1,10 @@
+package model
+
+// RobotTransfer 机器人转人工配置，以JSON形式存储在robots.transfer字段
+type RobotTransfer struct {
+	Target       string `json:"target" yaml:"target"`                                   // 转接目标，SIP URI（如sip:1001@pbx.example.com）或坐席队列号码
+	HandoffText  string `json:"handoff_text,omitempty" yaml:"handoff_text,omitempty"`   // 转接前播报的话术（为空使用默认话术）
+	FallbackText string `json:"fallback_text,omitempty" yaml:"fallback_text,omitempty"` // 转接失败后机器人继续服务时播报的话术
+	MusicOnHold  string `json:"music_on_hold,omitempty" yaml:"music_on_hold,omitempty"` // 等待坐席接听时播放的音频地址
+	TimeoutSecs  int    `json:"timeout_secs,omitempty" yaml:"timeout_secs,omitempty"`   // 等待坐席接听的超时秒数（默认30秒）
+}
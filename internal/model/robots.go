@@ -7,19 +7,76 @@ import (
 
 // Robot 机器人配置，与robots表映射
 type Robot struct {
-	ID           uint           `gorm:"column:id;primaryKey"` // 主键ID
-	Name         string         `gorm:"column:name"`
-	UserID       uint           `gorm:"column:user_id;not null"`          // 关联用户ID（外键）
-	Speed        float32        `gorm:"column:speed;type:float"`          // 语音语速（可选）
-	Volume       int            `gorm:"column:volume;type:int"`           // 语音音量（可选）
-	Speaker      string         `gorm:"column:speaker;size:50"`           // 发音人（可选）
-	Emotion      string         `gorm:"column:emotion;size:50"`           // 语音情感（可选）
-	SystemPrompt string         `gorm:"column:system_prompt;type:text"`   // 系统提示词（可选）
-	CreatedAt    time.Time      `gorm:"column:created_at"`                // 创建时间
-	UpdatedAt    time.Time      `gorm:"column:updated_at"`                // 更新时间
-	DeletedAt    gorm.DeletedAt `gorm:"column:deleted_at;index" json:"-"` // 软删除支持
+	ID                  uint               `gorm:"column:id;primaryKey"` // 主键ID
+	Name                string             `gorm:"column:name"`
+	UserID              uint               `gorm:"column:user_id;not null"`                        // 关联用户ID（外键）
+	Speed               float32            `gorm:"column:speed;type:float"`                        // 语音语速（可选）
+	Volume              int                `gorm:"column:volume;type:int"`                         // 语音音量（可选）
+	Speaker             string             `gorm:"column:speaker;size:50"`                         // 发音人（可选）
+	TTSProvider         string             `gorm:"column:tts_provider;size:100"`                   // 发音人所属语音合成服务商（可选，默认tencent）
+	SampleRate          int                `gorm:"column:sample_rate;type:int"`                    // 语音合成采样率（可选）
+	Emotion             string             `gorm:"column:emotion;size:50"`                         // 语音情感（可选）
+	SystemPrompt        string             `gorm:"column:system_prompt;type:text"`                 // 系统提示词（可选）
+	GreetingType        string             `gorm:"column:greeting_type;size:20"`                   // 开场白类型：text|audio|llm（可选，为空不播报）
+	Greeting            string             `gorm:"column:greeting;type:text"`                      // 开场白文本，audio类型时为音频对应文字，llm类型时为生成指令
+	GreetingURL         string             `gorm:"column:greeting_url;size:255"`                   // 开场白音频地址（audio类型使用）
+	Timezone            string             `gorm:"column:timezone;size:64"`                        // 时区，用于模板中的日期时间（可选，默认Asia/Shanghai）
+	TemplateStrict      bool               `gorm:"column:template_strict"`                         // 模板缺失变量时是否报错（默认替换为空）
+	Tools               []RobotTool        `gorm:"column:tools;type:text;serializer:json"`         // 启用的大模型工具（为空时仅启用hangup）
+	Transfer            *RobotTransfer     `gorm:"column:transfer;type:text;serializer:json"`      // 转人工配置（可选，启用transfer工具时必填）
+	BargeIn             bool               `gorm:"column:barge_in"`                                // 是否允许用户打断机器人说话
+	BargeInMinMs        int                `gorm:"column:barge_in_min_ms;type:int"`                // 触发打断的最短说话时长毫秒数（默认立即打断）
+	EouWindowMs         int                `gorm:"column:eou_window_ms;type:int"`                  // 最后一段识别结果后等待用户继续说话的毫秒数（默认500，启用断句检测时为最长等待时间）
+	EouType             string             `gorm:"column:eou_type;size:50"`                        // rust端断句检测的服务类型（可选，为空时只按等待时间合并）
+	TurnPolicy          string             `gorm:"column:turn_policy;size:20"`                     // 机器人回答期间用户又说话时的处理方式：queue|replace|ignore（默认queue）
+	Fillers             []RobotFiller      `gorm:"column:fillers;type:text;serializer:json"`       // 等待大模型回复时播报的填充语，随机选取一条（为空不播报）
+	FillerDelayMs       int                `gorm:"column:filler_delay_ms;type:int"`                // 等待多少毫秒仍没有回复时播报填充语（默认1000）
+	SilenceTimeoutSec   int                `gorm:"column:silence_timeout_sec;type:int"`            // 机器人说完后用户沉默多少秒进行追问（为0不追问）
+	SilenceReprompt     string             `gorm:"column:silence_reprompt;type:text"`              // 用户沉默时的追问话术（为空使用默认话术）
+	SilenceMaxReprompts int                `gorm:"column:silence_max_reprompts;type:int"`          // 最多追问次数，之后说告别语并挂断（默认2）
+	IdleGoodbye         string             `gorm:"column:idle_goodbye;type:text"`                  // 用户一直沉默挂断前的告别语（为空使用默认话术）
+	MaxCallDurationSec  int                `gorm:"column:max_call_duration_sec;type:int"`          // 通话最长秒数，与密钥上的限制取较小值（为0不限制）
+	DurationWarningSec  int                `gorm:"column:duration_warning_sec;type:int"`           // 达到最长时长前多少秒播报提醒（为0不提醒）
+	DurationWarning     string             `gorm:"column:duration_warning;type:text"`              // 通话即将结束的提醒话术（为空使用默认话术）
+	DurationGoodbye     string             `gorm:"column:duration_goodbye;type:text"`              // 达到最长时长挂断前的结束语（为空使用默认话术）
+	Dtmf                *RobotDtmf         `gorm:"column:dtmf;type:text;serializer:json"`          // 按键菜单和号码收集配置（可选）
+	AmdPolicy           string             `gorm:"column:amd_policy;size:20"`                      // 外呼检测到答录机时的处理方式：hangup|voicemail|continue（默认continue）
+	VoicemailText       string             `gorm:"column:voicemail_text;type:text"`                // 答录机提示音后合成的留言（voicemail策略，与音频二选一）
+	VoicemailURL        string             `gorm:"column:voicemail_url;size:255"`                  // 答录机提示音后播放的留言音频地址（voicemail策略，优先于文本）
+	StreamingTTS        bool               `gorm:"column:streaming_tts"`                           // 是否使用流式语音合成，整轮回复合成为一段连续语音
+	SegmentMinRunes     int                `gorm:"column:segment_min_runes;type:int"`              // 回复分段合成时每段的最少字数（默认6，首段不受限制）
+	SegmentMaxRunes     int                `gorm:"column:segment_max_runes;type:int"`              // 回复分段合成时每段的最多字数（默认80）
+	Lexicon             map[string]string  `gorm:"column:lexicon;type:text;serializer:json"`       // 发音词典，键为原词，值为朗读时替换成的文本（可选）
+	ContextMaxTokens    int                `gorm:"column:context_max_tokens;type:int"`             // 对话历史的token预算（默认8000）
+	ContextStrategy     string             `gorm:"column:context_strategy;size:20"`                // 超出预算时的处理方式：truncate|summarize（默认truncate）
+	LLMEndpoints        []RobotLLMEndpoint `gorm:"column:llm_endpoints;type:text;serializer:json"` // 备用大模型节点，主节点失败后按顺序切换（可选）
+	LLMFallbackText     string             `gorm:"column:llm_fallback_text;type:text"`             // 所有大模型节点都失败时播报的话术（为空使用默认话术）
+	CreatedAt           time.Time          `gorm:"column:created_at"`                              // 创建时间
+	UpdatedAt           time.Time          `gorm:"column:updated_at"`                              // 更新时间
+	DeletedAt           gorm.DeletedAt     `gorm:"column:deleted_at;index" json:"-"`               // 软删除支持
 }
 
+// 开场白类型
+const (
+	GreetingTypeText  = "text"  // 直接合成固定文本
+	GreetingTypeAudio = "audio" // 通过play命令播放音频
+	GreetingTypeLLM   = "llm"   // 由大模型按指令生成
+)
+
+// 机器人回答期间用户又说话时的处理方式
+const (
+	TurnPolicyQueue   = "queue"   // 说完当前回答后再回答新的发言
+	TurnPolicyReplace = "replace" // 停止当前回答，改为回答新的发言
+	TurnPolicyIgnore  = "ignore"  // 忽略回答期间的发言
+)
+
+// 外呼检测到答录机时的处理方式
+const (
+	AmdPolicyHangup    = "hangup"    // 立即挂断
+	AmdPolicyVoicemail = "voicemail" // 等提示音结束后留言再挂断
+	AmdPolicyContinue  = "continue"  // 按真人继续对话
+)
+
 // TableName 自定义表名
 func (Robot) TableName() string {
 	return "robots"
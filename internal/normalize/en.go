@@ -0,0 +1,197 @@
+package normalize
+
+import (
+	"strconv"
+	"strings"
+)
+
+var (
+	enOnes = []string{"zero", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine",
+		"ten", "eleven", "twelve", "thirteen", "fourteen", "fifteen", "sixteen", "seventeen", "eighteen", "nineteen"}
+	enTens   = []string{"", "", "twenty", "thirty", "forty", "fifty", "sixty", "seventy", "eighty", "ninety"}
+	enScales = []string{"", " thousand", " million", " billion", " trillion"}
+	enMonths = []string{"January", "February", "March", "April", "May", "June",
+		"July", "August", "September", "October", "November", "December"}
+	// enOrdinals 不规则的序数词，其余加th
+	enOrdinals = map[string]string{"one": "first", "two": "second", "three": "third", "five": "fifth",
+		"eight": "eighth", "nine": "ninth", "twelve": "twelfth"}
+	// enCurrencyUnits 货币单位的单数和复数
+	enCurrencyUnits = map[string][2]string{"$": {"dollar", "dollars"}, "€": {"euro", "euros"},
+		"£": {"pound", "pounds"}, "¥": {"yuan", "yuan"}, "￥": {"yuan", "yuan"}}
+)
+
+// enReader 英文数字读法
+type enReader struct{}
+
+func (enReader) date(year, month, day string) string {
+	monthIndex, _ := strconv.Atoi(month)
+	return enMonths[monthIndex-1] + " " + enOrdinal(enInteger(day)) + ", " + enYear(year)
+}
+
+func (enReader) time(hour, minute, second string) string {
+	text := enInteger(hour)
+	switch {
+	case minute == "00":
+		text += " o'clock"
+	case minute[0] == '0':
+		text += " oh " + enOnes[minute[1]-'0']
+	default:
+		text += " " + enInteger(minute)
+	}
+	if second != "" && second != "00" {
+		text += " and " + enInteger(second) + " seconds"
+	}
+	return text
+}
+
+func (enReader) currency(symbol, amount string) string {
+	units := enCurrencyUnits[symbol]
+	integer, fraction, _ := strings.Cut(amount, ".")
+	text := enInteger(integer) + " " + units[1]
+	if strings.TrimLeft(integer, "0") == "1" {
+		text = "one " + units[0]
+	}
+	if fraction == "" {
+		return text
+	}
+	// 美元、欧元和英镑的小数部分读作分
+	if symbol == "$" || symbol == "€" || symbol == "£" {
+		cents, _ := strconv.Atoi((fraction + "0")[:2])
+		switch {
+		case cents == 1:
+			text += " and one cent"
+		case cents > 1:
+			text += " and " + enInteger(strconv.Itoa(cents)) + " cents"
+		}
+		return text
+	}
+	return enInteger(integer) + " point " + enDigitWords(fraction) + " " + units[1]
+}
+
+func (enReader) digits(digits string) string {
+	var words []string
+	for _, r := range digits {
+		switch {
+		case isDigit(r):
+			words = append(words, enOnes[r-'0'])
+		case r == '+':
+			words = append(words, "plus")
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// isCode 英文中电话号码一般带分隔符，十位及以上的数字才按号码读
+func (enReader) isCode(value string, after rune) bool {
+	return len(value) >= 10
+}
+
+func (enReader) rangeWord() string {
+	return " to "
+}
+
+func (enReader) number(sign, value, percent, ordinal string, after rune) string {
+	text := ""
+	if sign == "-" {
+		text = "minus "
+	}
+	integer, fraction, _ := strings.Cut(value, ".")
+	year, _ := strconv.Atoi(integer)
+	switch {
+	case ordinal != "" && fraction == "":
+		text += enOrdinal(enInteger(integer))
+	case len(integer) == 4 && fraction == "" && percent == "" && sign == "" && year >= 1100 && year < 2100:
+		// 四位数按年份读，1500读作fifteen hundred同样适用于数量
+		text += enYear(integer)
+	default:
+		text += enInteger(integer)
+	}
+	if fraction != "" {
+		text += " point " + enDigitWords(fraction)
+	}
+	if percent != "" {
+		text += " percent"
+	}
+	// 3km读作three km
+	if isASCIILetter(after) {
+		text += " "
+	}
+	return text
+}
+
+// enDigitWords 小数部分逐位读出
+func enDigitWords(digits string) string {
+	return enReader{}.digits(digits)
+}
+
+// enInteger 读出整数，超过万亿位时逐位读出
+func enInteger(digits string) string {
+	digits = strings.TrimLeft(digits, "0")
+	if digits == "" {
+		return enOnes[0]
+	}
+	if len(digits) > 3*len(enScales) {
+		return enDigitWords(digits)
+	}
+	var parts []string
+	scale := 0
+	for end := len(digits); end > 0; end -= 3 {
+		start := end - 3
+		if start < 0 {
+			start = 0
+		}
+		group, _ := strconv.Atoi(digits[start:end])
+		if group > 0 {
+			parts = append([]string{enHundreds(group) + enScales[scale]}, parts...)
+		}
+		scale++
+	}
+	return strings.Join(parts, " ")
+}
+
+// enHundreds 读出1到999
+func enHundreds(n int) string {
+	var words []string
+	if n >= 100 {
+		words = append(words, enOnes[n/100]+" hundred")
+		n %= 100
+	}
+	switch {
+	case n >= 20 && n%10 != 0:
+		words = append(words, enTens[n/10]+"-"+enOnes[n%10])
+	case n >= 20:
+		words = append(words, enTens[n/10])
+	case n > 0:
+		words = append(words, enOnes[n])
+	}
+	return strings.Join(words, " ")
+}
+
+// enYear 年份按两位一读，如1984读作nineteen eighty-four，2005读作two thousand five
+func enYear(year string) string {
+	value, _ := strconv.Atoi(year)
+	high, low := value/100, value%100
+	switch {
+	case value >= 2000 && value < 2010:
+		return enInteger(year)
+	case low == 0:
+		return enHundreds(high) + " hundred"
+	case low < 10:
+		return enHundreds(high) + " oh " + enOnes[low]
+	default:
+		return enHundreds(high) + " " + enHundreds(low)
+	}
+}
+
+// enOrdinal 将基数词的最后一个词转为序数词，如twenty-one转为twenty-first
+func enOrdinal(cardinal string) string {
+	cut := strings.LastIndexAny(cardinal, " -") + 1
+	last := cardinal[cut:]
+	if ordinal, ok := enOrdinals[last]; ok {
+		return cardinal[:cut] + ordinal
+	}
+	if strings.HasSuffix(last, "y") {
+		return cardinal[:cut] + strings.TrimSuffix(last, "y") + "ieth"
+	}
+	return cardinal + "th"
+}
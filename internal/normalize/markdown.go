@@ -0,0 +1,64 @@
+package normalize
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	// urlRegex 匹配裸露的网址，网址在中文标点处结束
+	urlRegex = regexp.MustCompile(`(?:https?://|www\.)[^\s，。！？；、（）()<>“”"]+`)
+	// imageRegex ![描述](地址) 只保留描述
+	imageRegex = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	// linkRegex [文字](地址) 只保留文字
+	linkRegex = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	// headingRegex 行首的标题、引用和无序列表标记
+	headingRegex = regexp.MustCompile(`(?m)^[ \t]*(?:#{1,6}[ \t]+|>[ \t]?|[-*+][ \t]+)`)
+	// fenceRegex 代码块的起止行
+	fenceRegex = regexp.MustCompile("(?m)^[ \t]*```[\\w+-]*[ \t]*(?:\n|$)")
+	// ruleRegex 分隔线
+	ruleRegex = regexp.MustCompile(`(?m)^[ \t]*(?:[-*_][ \t]*){3,}$`)
+	// emphasisRegex 粗体、斜体、删除线和行内代码标记
+	emphasisRegex = regexp.MustCompile("\\*{1,3}|_{2,3}|~~|`+")
+)
+
+// stripMarkdown 去除markdown标记，只保留要朗读的文字
+func stripMarkdown(text string) string {
+	text = fenceRegex.ReplaceAllString(text, "")
+	text = ruleRegex.ReplaceAllString(text, "")
+	text = imageRegex.ReplaceAllString(text, "$1")
+	text = linkRegex.ReplaceAllString(text, "$1")
+	text = headingRegex.ReplaceAllString(text, "")
+	text = emphasisRegex.ReplaceAllString(text, "")
+	// 表格的竖线读作停顿
+	return strings.ReplaceAll(text, "|", " ")
+}
+
+// stripEmoji 去除表情符号及其变体选择符和连接符
+func stripEmoji(text string) string {
+	return strings.Map(func(r rune) rune {
+		if isEmoji(r) {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+func isEmoji(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF: // 表情、符号、国旗和扑克牌等
+		return true
+	case r >= 0x2600 && r <= 0x27BF: // 杂项符号和装饰符号
+		return true
+	case r >= 0x2B00 && r <= 0x2BFF: // 箭头和星形等
+		return true
+	case r == 0x200D || r == 0x20E3 || (r >= 0xFE00 && r <= 0xFE0F): // 连接符、组合键帽和变体选择符
+		return true
+	}
+	return false
+}
+
+func firstRune(text string) (rune, int) {
+	return utf8.DecodeRuneInString(text)
+}
@@ -0,0 +1,101 @@
+package normalize
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// 支持的朗读语言
+const (
+	LanguageZh = "zh"
+	LanguageEn = "en"
+)
+
+var spaceRegex = regexp.MustCompile(`[ \t]{2,}`)
+
+// Normalizer 将大模型输出转换为适合语音合成朗读的文本：
+// 去除markdown和表情，把网址、数字、日期、时间、金额和电话号码转换为读法，并应用机器人的发音词典
+type Normalizer struct {
+	language string
+	// lexicon 发音词典，按原词长度从长到短排列，避免短词先替换掉长词的一部分
+	lexicon []lexiconEntry
+}
+
+type lexiconEntry struct {
+	word          string
+	pronunciation string
+}
+
+// New 按语言创建转换器，language为识别语言（如zh、en-US），非英文均按中文读法处理；
+// lexicon为发音词典，键为原词，值为朗读时替换成的文本
+func New(language string, lexicon map[string]string) *Normalizer {
+	normalizer := &Normalizer{language: LanguageZh}
+	if strings.HasPrefix(strings.ToLower(language), LanguageEn) {
+		normalizer.language = LanguageEn
+	}
+	for word, pronunciation := range lexicon {
+		if word != "" {
+			normalizer.lexicon = append(normalizer.lexicon, lexiconEntry{word: word, pronunciation: pronunciation})
+		}
+	}
+	sort.Slice(normalizer.lexicon, func(i, j int) bool {
+		if len(normalizer.lexicon[i].word) != len(normalizer.lexicon[j].word) {
+			return len(normalizer.lexicon[i].word) > len(normalizer.lexicon[j].word)
+		}
+		return normalizer.lexicon[i].word < normalizer.lexicon[j].word
+	})
+	return normalizer
+}
+
+// Normalize 转换一段待合成的文本，结果可能为空（如整段都是表情或代码块标记）
+func (n *Normalizer) Normalize(text string) string {
+	text = stripMarkdown(text)
+	text = stripEmoji(text)
+	text = n.speakURLs(text)
+	text = n.applyLexicon(text)
+	text = n.expandNumbers(text)
+	return spaceRegex.ReplaceAllString(text, " ")
+}
+
+// applyLexicon 按发音词典替换，已替换的部分不会被更短的词再次替换
+func (n *Normalizer) applyLexicon(text string) string {
+	if len(n.lexicon) == 0 {
+		return text
+	}
+	var builder strings.Builder
+	for len(text) > 0 {
+		matched := false
+		for _, entry := range n.lexicon {
+			if strings.HasPrefix(text, entry.word) {
+				builder.WriteString(entry.pronunciation)
+				text = text[len(entry.word):]
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			_, size := firstRune(text)
+			builder.WriteString(text[:size])
+			text = text[size:]
+		}
+	}
+	return builder.String()
+}
+
+// speakURLs 网址只读出域名，如example点com
+func (n *Normalizer) speakURLs(text string) string {
+	dot := "点"
+	if n.language == LanguageEn {
+		dot = " dot "
+	}
+	return urlRegex.ReplaceAllStringFunc(text, func(url string) string {
+		trimmed := strings.TrimRight(url, ".,;:!?")
+		host := strings.TrimPrefix(strings.TrimPrefix(trimmed, "https://"), "http://")
+		host = strings.TrimPrefix(host, "www.")
+		if i := strings.IndexAny(host, "/?#"); i >= 0 {
+			host = host[:i]
+		}
+		return strings.ReplaceAll(host, ".", dot) + url[len(trimmed):]
+	})
+}
@@ -0,0 +1,76 @@
+package normalize
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		lexicon  map[string]string
+		text     string
+		want     string
+	}{
+		// markdown and emoji
+		{name: "emphasis", language: "zh", text: "请**务必**带上`身份证`", want: "请务必带上身份证"},
+		{name: "heading and list", language: "zh", text: "## 办理步骤\n- 第一步\n- 第二步", want: "办理步骤\n第一步\n第二步"},
+		{name: "link keeps its text", language: "zh", text: "详见[帮助中心](https://help.example.com/a)", want: "详见帮助中心"},
+		{name: "code fence", language: "en", text: "```json\n{}\n```", want: "{}\n"},
+		{name: "table", language: "zh", text: "|套餐|价格|", want: " 套餐 价格 "},
+		{name: "emoji", language: "zh", text: "好的😊，马上为您处理👍🏻", want: "好的，马上为您处理"},
+		{name: "only emoji", language: "zh", text: "🎉", want: ""},
+
+		// urls
+		{name: "zh url", language: "zh", text: "请访问https://www.example.com/path?a=1。", want: "请访问example点com。"},
+		{name: "en url", language: "en-US", text: "Visit www.example.com.", want: "Visit example dot com."},
+
+		// zh numbers
+		{name: "zh integer", language: "zh", text: "共1005人", want: "共一千零五人"},
+		{name: "zh teens", language: "zh", text: "15分钟", want: "十五分钟"},
+		{name: "zh ten thousands", language: "zh", text: "10500元", want: "一万零五百元"},
+		{name: "zh thousands separator", language: "zh", text: "总计1,234.50元", want: "总计一千二百三十四点五零元"},
+		{name: "zh two before a measure word", language: "zh", text: "2个订单", want: "两个订单"},
+		{name: "zh two elsewhere", language: "zh", text: "第2", want: "第二"},
+		{name: "zh percent", language: "zh", text: "增长了12.5%", want: "增长了百分之十二点五"},
+		{name: "zh negative", language: "zh", text: "气温-5度", want: "气温负五度"},
+		{name: "zh year", language: "zh", text: "2024年", want: "二零二四年"},
+		{name: "zh date", language: "zh", text: "2024-05-01", want: "二零二四年五月一日"},
+		{name: "zh invalid date", language: "zh", text: "2024-13-01", want: "二零二四幺三零幺"},
+		{name: "zh time", language: "zh", text: "10:05", want: "十点零五分"},
+		{name: "zh time on the hour", language: "zh", text: "9:00", want: "九点"},
+		{name: "zh currency", language: "zh", text: "¥1,299.00", want: "一千二百九十九元"},
+		{name: "zh dollars", language: "zh", text: "$20", want: "二十美元"},
+		{name: "zh phone", language: "zh", text: "400-123-4567", want: "四零零幺二三四五六七"},
+		{name: "zh mobile", language: "zh", text: "13812345678", want: "幺三八幺二三四五六七八"},
+		{name: "zh long amount", language: "zh", text: "12000000人", want: "一千二百万人"},
+		{name: "zh range", language: "zh", text: "3-5天", want: "三到五天"},
+		{name: "zh leading zero", language: "zh", text: "验证码0815", want: "验证码零八幺五"},
+		{name: "zh letters and digits", language: "zh", text: "5G套餐A12", want: "五G套餐A12"},
+
+		// en numbers
+		{name: "en integer", language: "en", text: "123 items", want: "one hundred twenty-three items"},
+		{name: "en year", language: "en", text: "in 1984", want: "in nineteen eighty-four"},
+		{name: "en year 2005", language: "en", text: "in 2005", want: "in two thousand five"},
+		{name: "en ordinal", language: "en", text: "the 21st", want: "the twenty-first"},
+		{name: "en date", language: "en", text: "2024-05-01", want: "May first, twenty twenty-four"},
+		{name: "en time", language: "en", text: "at 9:05", want: "at nine oh five"},
+		{name: "en time on the hour", language: "en", text: "at 10:00", want: "at ten o'clock"},
+		{name: "en dollars and cents", language: "en", text: "$3.50", want: "three dollars and fifty cents"},
+		{name: "en one dollar", language: "en", text: "$1", want: "one dollar"},
+		{name: "en percent", language: "en", text: "5.5%", want: "five point five percent"},
+		{name: "en phone", language: "en", text: "+1-800-555-0199", want: "plus one eight zero zero five five five zero one nine nine"},
+		{name: "en unit", language: "en", text: "3km", want: "three km"},
+
+		// lexicon
+		{name: "lexicon", language: "zh", lexicon: map[string]string{"VIP": "维爱屁"}, text: "您是VIP会员", want: "您是维爱屁会员"},
+		{name: "longer lexicon word wins", language: "zh", lexicon: map[string]string{"银行": "yin2 hang2", "招商银行": "招商银航"}, text: "招商银行", want: "招商银航"},
+		{name: "lexicon before numbers", language: "zh", lexicon: map[string]string{"4S店": "四S店"}, text: "去4S店", want: "去四S店"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := New(tt.language, tt.lexicon).Normalize(tt.text)
+			if got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package normalize
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// numberRegex 依次匹配日期、时间、带货币符号的金额、带连字符的号码和普通数字（可带千分位、小数和百分号）
+var numberRegex = regexp.MustCompile(
+	`(?P<date>(\d{4})[-/.](\d{1,2})[-/.](\d{1,2}))` +
+		`|(?P<time>(\d{1,2}):(\d{2})(?::(\d{2}))?)` +
+		`|(?P<currency>([¥￥$€£])\s?(\d{1,3}(?:,\d{3})+(?:\.\d+)?|\d+(?:\.\d+)?))` +
+		`|(?P<phone>\+?\d+(?:-\d+){1,4})` +
+		`|(?P<number>(-?)(\d{1,3}(?:,\d{3})+(?:\.\d+)?|\d+(?:\.\d+)?)([%％]?)((?:st|nd|rd|th)\b)?)`)
+
+// phoneMinDigits 带连字符的号码达到该位数时逐位朗读
+const phoneMinDigits = 7
+
+// numberMatch 一次匹配的各个分组
+type numberMatch struct {
+	groups []string
+	// before 匹配前的一个字符，after 匹配后的一个字符
+	before, after rune
+}
+
+func (m numberMatch) group(name string) string {
+	return m.groups[numberRegex.SubexpIndex(name)]
+}
+
+// sub 返回分组name之后第offset个分组
+func (m numberMatch) sub(name string, offset int) string {
+	return m.groups[numberRegex.SubexpIndex(name)+offset]
+}
+
+// numberReader 某种语言的数字读法
+type numberReader interface {
+	date(year, month, day string) string
+	time(hour, minute, second string) string
+	currency(symbol, amount string) string
+	digits(digits string) string
+	// isCode 判断不带分隔符的长串数字是否为号码、编号，after为数字后的一个字符
+	isCode(value string, after rune) bool
+	// rangeWord 数字区间中连字符的读法，如3-5中的-
+	rangeWord() string
+	// number 读出数字，ordinal为英文序数词后缀（如1st中的st）
+	number(sign, value, percent, ordinal string, after rune) string
+}
+
+// expandNumbers 将文本中的数字转换为读法
+func (n *Normalizer) expandNumbers(text string) string {
+	var reader numberReader = zhReader{}
+	if n.language == LanguageEn {
+		reader = enReader{}
+	}
+	indexes := numberRegex.FindAllStringSubmatchIndex(text, -1)
+	if len(indexes) == 0 {
+		return text
+	}
+	var builder strings.Builder
+	last := 0
+	for _, index := range indexes {
+		start, end := index[0], index[1]
+		// 字母和数字混排的编号（如A12、5G）中的数字不做转换
+		before, _ := utf8.DecodeLastRuneInString(text[:start])
+		if isASCIILetter(before) {
+			continue
+		}
+		match := numberMatch{groups: make([]string, len(index)/2), before: before}
+		for i := range match.groups {
+			if index[2*i] >= 0 {
+				match.groups[i] = text[index[2*i]:index[2*i+1]]
+			}
+		}
+		match.after, _ = utf8.DecodeRuneInString(text[end:])
+		builder.WriteString(text[last:start])
+		builder.WriteString(readNumber(reader, match))
+		last = end
+	}
+	builder.WriteString(text[last:])
+	return builder.String()
+}
+
+func readNumber(reader numberReader, match numberMatch) string {
+	switch {
+	case match.group("date") != "":
+		month, _ := strconv.Atoi(match.sub("date", 2))
+		day, _ := strconv.Atoi(match.sub("date", 3))
+		if month >= 1 && month <= 12 && day >= 1 && day <= 31 {
+			return reader.date(match.sub("date", 1), match.sub("date", 2), match.sub("date", 3))
+		}
+		return reader.digits(match.group("date"))
+	case match.group("time") != "":
+		hour, _ := strconv.Atoi(match.sub("time", 1))
+		minute, _ := strconv.Atoi(match.sub("time", 2))
+		if hour <= 24 && minute < 60 {
+			return reader.time(match.sub("time", 1), match.sub("time", 2), match.sub("time", 3))
+		}
+		return reader.digits(match.group("time"))
+	case match.group("currency") != "":
+		return reader.currency(match.sub("currency", 1), strings.ReplaceAll(match.sub("currency", 2), ",", ""))
+	case match.group("phone") != "":
+		phone := match.group("phone")
+		if countDigits(phone) >= phoneMinDigits {
+			return reader.digits(phone)
+		}
+		// 较短的如3-5按区间读出
+		parts := strings.Split(phone, "-")
+		for i, part := range parts {
+			parts[i] = reader.number("", part, "", "", 0)
+		}
+		return strings.Join(parts, reader.rangeWord())
+	default:
+		sign, prefix := match.sub("number", 1), ""
+		// 负号紧跟在数字后面时是连字符而不是负号
+		if sign != "" && isDigit(match.before) {
+			sign, prefix = "", sign
+		}
+		value := strings.ReplaceAll(match.sub("number", 2), ",", "")
+		// 以0开头的数字和号码、编号逐位读出
+		if !strings.Contains(value, ".") && ((len(value) > 1 && value[0] == '0') || reader.isCode(value, match.after)) {
+			return prefix + reader.digits(value) + match.sub("number", 3) + match.sub("number", 4)
+		}
+		return prefix + reader.number(sign, value, match.sub("number", 3), match.sub("number", 4), match.after)
+	}
+}
+
+func countDigits(text string) int {
+	count := 0
+	for _, r := range text {
+		if isDigit(r) {
+			count++
+		}
+	}
+	return count
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+func isASCIILetter(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
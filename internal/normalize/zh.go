@@ -0,0 +1,172 @@
+package normalize
+
+import (
+	"strings"
+)
+
+var (
+	zhDigits = []string{"零", "一", "二", "三", "四", "五", "六", "七", "八", "九"}
+	// zhPhoneDigits 号码中的1读作幺，避免与七混淆
+	zhPhoneDigits   = []string{"零", "幺", "二", "三", "四", "五", "六", "七", "八", "九"}
+	zhSectionUnits  = []string{"", "十", "百", "千"}
+	zhGroupUnits    = []string{"", "万", "亿", "万亿"}
+	zhCurrencyUnits = map[string]string{"¥": "元", "￥": "元", "$": "美元", "€": "欧元", "£": "英镑"}
+	// zhLiangBefore 数字2在这些量词前读作两
+	zhLiangBefore = "个位次天周岁张件条只台遍本倍点种家辆瓶杯"
+	// zhAmountBefore 长串数字后跟这些字时是数量而不是号码
+	zhAmountBefore = "元块角美欧英镑万亿人个次件台名位份条张米克吨"
+)
+
+// zhReader 中文数字读法
+type zhReader struct{}
+
+func (zhReader) date(year, month, day string) string {
+	return zhPlainDigits(year) + "年" + zhInteger(month) + "月" + zhInteger(day) + "日"
+}
+
+func (zhReader) time(hour, minute, second string) string {
+	text := zhInteger(hour) + "点"
+	if minute != "00" || (second != "" && second != "00") {
+		text += zhClockPart(minute) + "分"
+	}
+	if second != "" && second != "00" {
+		text += zhClockPart(second) + "秒"
+	}
+	return text
+}
+
+// zhClockPart 时间中的分秒，05读作零五
+func zhClockPart(value string) string {
+	if len(value) == 2 && value[0] == '0' {
+		return "零" + zhDigits[value[1]-'0']
+	}
+	return zhInteger(value)
+}
+
+func (r zhReader) currency(symbol, amount string) string {
+	if strings.Contains(amount, ".") {
+		amount = strings.TrimRight(strings.TrimRight(amount, "0"), ".")
+	}
+	return r.number("", amount, "", "", 0) + zhCurrencyUnits[symbol]
+}
+
+func (zhReader) digits(digits string) string {
+	var builder strings.Builder
+	for _, r := range digits {
+		switch {
+		case isDigit(r):
+			builder.WriteString(zhPhoneDigits[r-'0'])
+		case r == '+':
+			builder.WriteString("加")
+		}
+	}
+	return builder.String()
+}
+
+// isCode 七位及以上的数字通常是电话、订单号，后面跟着单位时才是数量
+func (zhReader) isCode(value string, after rune) bool {
+	return len(value) >= phoneMinDigits && !strings.ContainsRune(zhAmountBefore, after)
+}
+
+func (zhReader) rangeWord() string {
+	return "到"
+}
+
+func (zhReader) number(sign, value, percent, ordinal string, after rune) string {
+	var builder strings.Builder
+	if sign == "-" {
+		builder.WriteString("负")
+	}
+	if percent != "" {
+		builder.WriteString("百分之")
+	}
+	integer, fraction, _ := strings.Cut(value, ".")
+	switch {
+	case integer == "2" && fraction == "" && percent == "" && strings.ContainsRune(zhLiangBefore, after):
+		builder.WriteString("两")
+	case len(integer) == 4 && fraction == "" && after == '年':
+		// 年份逐位读出
+		builder.WriteString(zhPlainDigits(integer))
+	default:
+		builder.WriteString(zhInteger(integer))
+	}
+	if fraction != "" {
+		builder.WriteString("点")
+		builder.WriteString(zhPlainDigits(fraction))
+	}
+	builder.WriteString(ordinal)
+	return builder.String()
+}
+
+// zhPlainDigits 逐位读出，1读作一
+func zhPlainDigits(digits string) string {
+	var builder strings.Builder
+	for _, r := range digits {
+		if isDigit(r) {
+			builder.WriteString(zhDigits[r-'0'])
+		}
+	}
+	return builder.String()
+}
+
+// zhInteger 读出整数，如10读作十，1005读作一千零五，超过万亿位时逐位读出
+func zhInteger(digits string) string {
+	digits = strings.TrimLeft(digits, "0")
+	if digits == "" {
+		return zhDigits[0]
+	}
+	if len(digits) > 4*len(zhGroupUnits) {
+		return zhPlainDigits(digits)
+	}
+	// 从低位起每四位一节
+	var groups []string
+	for end := len(digits); end > 0; end -= 4 {
+		start := end - 4
+		if start < 0 {
+			start = 0
+		}
+		groups = append(groups, digits[start:end])
+	}
+	var builder strings.Builder
+	zero := false
+	for i := len(groups) - 1; i >= 0; i-- {
+		group := strings.TrimLeft(groups[i], "0")
+		if group == "" {
+			zero = builder.Len() > 0
+			continue
+		}
+		// 节与节之间缺位时补零，如一万零五百
+		if builder.Len() > 0 && (zero || len(group) < 4) {
+			builder.WriteString(zhDigits[0])
+		}
+		builder.WriteString(zhSection(group))
+		builder.WriteString(zhGroupUnits[i])
+		zero = false
+	}
+	text := builder.String()
+	// 十几读作十几而不是一十几
+	if strings.HasPrefix(text, "一十") {
+		text = strings.TrimPrefix(text, "一")
+	}
+	return text
+}
+
+// zhSection 读出不超过四位且不以0开头的一节
+func zhSection(digits string) string {
+	var builder strings.Builder
+	zero := false
+	for i, r := range digits {
+		unit := len(digits) - 1 - i
+		if r == '0' {
+			zero = true
+			continue
+		}
+		if zero {
+			builder.WriteString(zhDigits[0])
+			zero = false
+		}
+		builder.WriteString(zhDigits[r-'0'])
+		builder.WriteString(zhSectionUnits[unit])
+	}
+	return builder.String()
+}
@@ -0,0 +1,203 @@
+package prompt
+
+import (
+	"fmt"
+	"maps"
+	"miniRustpbxgo/internal/model"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+	_ "time/tzdata" // 部署环境可能缺少时区数据库
+)
+
+// DefaultTimezone 机器人未配置时区时使用的时区
+const DefaultTimezone = "Asia/Shanghai"
+
+// CallInfo 单通电话中可用于模板渲染的信息
+type CallInfo struct {
+	Variables map[string]string // 调用方传入的自定义变量
+	CallerID  string            // 主叫号码（SIP呼叫）
+	Now       time.Time         // 通话开始时间
+}
+
+// LoadLocation 加载机器人时区，为空时使用默认时区
+func LoadLocation(timezone string) (*time.Location, error) {
+	if timezone == "" {
+		timezone = DefaultTimezone
+	}
+	return time.LoadLocation(timezone)
+}
+
+// BuildData 构建模板数据，模板中可使用：
+// {{.vars.xxx}} 自定义变量，{{.caller_id}} 主叫号码，
+// {{.date}} {{.time}} {{.datetime}} {{.weekday}} 机器人时区下的当前时间，
+// {{.robot.name}} {{.robot.speaker}} 等机器人字段
+func BuildData(robot *model.Robot, info CallInfo) (map[string]any, error) {
+	loc, err := LoadLocation(robot.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %s: %w", robot.Timezone, err)
+	}
+	now := info.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	now = now.In(loc)
+	vars := info.Variables
+	if vars == nil {
+		vars = map[string]string{}
+	}
+	return map[string]any{
+		"vars":      vars,
+		"caller_id": info.CallerID,
+		"date":      now.Format("2006-01-02"),
+		"time":      now.Format("15:04"),
+		"datetime":  now.Format("2006-01-02 15:04:05"),
+		"weekday":   now.Weekday().String(),
+		"timezone":  loc.String(),
+		"robot": map[string]string{
+			"id":      fmt.Sprintf("%d", robot.ID),
+			"name":    robot.Name,
+			"speaker": robot.Speaker,
+			"emotion": robot.Emotion,
+		},
+	}, nil
+}
+
+// Render 渲染模板，strict为true时缺失变量返回错误，否则输出为空字符串
+func Render(text string, data map[string]any, strict bool) (string, error) {
+	// 不含模板语法时直接返回，兼容历史提示词
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	missingKey := "missingkey=zero"
+	if strict {
+		missingKey = "missingkey=error"
+	}
+	tmpl, err := template.New("prompt").Option(missingKey).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("parse template error: %w", err)
+	}
+	if !strict {
+		data = fillMissingKeys(tmpl, data)
+	}
+	var builder strings.Builder
+	if err := tmpl.Execute(&builder, data); err != nil {
+		return "", fmt.Errorf("render template error: %w", err)
+	}
+	return builder.String(), nil
+}
+
+// fillMissingKeys 为模板引用但数据中不存在的顶层变量补上空值，返回数据的副本。
+// 缺失的顶层变量是nil接口值，missingkey=zero时会输出<no value>；
+// 补空字符串后输出为空，带下级字段的（如.unknown.name）补空map，下级字段同样为空
+func fillMissingKeys(tmpl *template.Template, data map[string]any) map[string]any {
+	missing := make(map[string]any)
+	add := func(ident []string) {
+		if len(ident) == 0 {
+			return
+		}
+		if _, ok := data[ident[0]]; ok {
+			return
+		}
+		if len(ident) > 1 {
+			missing[ident[0]] = map[string]string{}
+		} else if _, ok := missing[ident[0]]; !ok {
+			missing[ident[0]] = ""
+		}
+	}
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch node := node.(type) {
+		case *parse.ListNode:
+			if node == nil {
+				return
+			}
+			for _, child := range node.Nodes {
+				walk(child)
+			}
+		case *parse.ActionNode:
+			walk(node.Pipe)
+		case *parse.IfNode:
+			walkBranch(&node.BranchNode, walk)
+		case *parse.RangeNode:
+			walkBranch(&node.BranchNode, walk)
+		case *parse.WithNode:
+			walkBranch(&node.BranchNode, walk)
+		case *parse.TemplateNode:
+			walk(node.Pipe)
+		case *parse.PipeNode:
+			if node == nil {
+				return
+			}
+			for _, cmd := range node.Cmds {
+				walk(cmd)
+			}
+		case *parse.CommandNode:
+			for _, arg := range node.Args {
+				walk(arg)
+			}
+		case *parse.ChainNode:
+			walk(node.Node)
+		case *parse.FieldNode:
+			add(node.Ident)
+		case *parse.VariableNode:
+			// $.xxx 同样引用顶层变量
+			if len(node.Ident) > 1 && node.Ident[0] == "$" {
+				add(node.Ident[1:])
+			}
+		}
+	}
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			walk(t.Tree.Root)
+		}
+	}
+	if len(missing) == 0 {
+		return data
+	}
+	filled := maps.Clone(data)
+	if filled == nil {
+		filled = make(map[string]any, len(missing))
+	}
+	maps.Copy(filled, missing)
+	return filled
+}
+
+// walkBranch 遍历if、range、with的条件和分支
+func walkBranch(branch *parse.BranchNode, walk func(node parse.Node)) {
+	walk(branch.Pipe)
+	walk(branch.List)
+	walk(branch.ElseList)
+}
+
+// RenderRobot 渲染机器人的系统提示词和开场白
+func RenderRobot(robot *model.Robot, info CallInfo) (systemPrompt string, greeting string, err error) {
+	data, err := BuildData(robot, info)
+	if err != nil {
+		return "", "", err
+	}
+	systemPrompt, err = Render(robot.SystemPrompt, data, robot.TemplateStrict)
+	if err != nil {
+		return "", "", fmt.Errorf("system_prompt: %w", err)
+	}
+	// 音频类型的开场白文字只用于记录，同样支持变量
+	greeting, err = Render(robot.Greeting, data, robot.TemplateStrict)
+	if err != nil {
+		return "", "", fmt.Errorf("greeting: %w", err)
+	}
+	return systemPrompt, greeting, nil
+}
+
+// Validate 校验机器人的时区和模板语法
+func Validate(robot *model.Robot) error {
+	if _, err := LoadLocation(robot.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone %s: %w", robot.Timezone, err)
+	}
+	for name, text := range map[string]string{"system_prompt": robot.SystemPrompt, "greeting": robot.Greeting} {
+		if _, err := template.New(name).Parse(text); err != nil {
+			return fmt.Errorf("%s: parse template error: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package prompt
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"miniRustpbxgo/internal/model"
+)
+
+func TestRenderRobot(t *testing.T) {
+	// 2024-05-01 01:30 UTC is a Wednesday morning in Shanghai and still Tuesday in New York
+	now := time.Date(2024, 5, 1, 1, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		robot model.Robot
+		info  CallInfo
+		// wantPrompt and wantGreeting are the rendered texts, wantErr is part of the error
+		wantPrompt   string
+		wantGreeting string
+		wantErr      string
+	}{
+		{
+			name:         "plain text is kept",
+			robot:        model.Robot{SystemPrompt: "你是客服小美", Greeting: "您好"},
+			wantPrompt:   "你是客服小美",
+			wantGreeting: "您好",
+		},
+		{
+			name: "variables and caller",
+			robot: model.Robot{
+				SystemPrompt: "客户{{.vars.name}}，来电号码{{.caller_id}}",
+				Greeting:     "{{.vars.name}}您好",
+			},
+			info:         CallInfo{Variables: map[string]string{"name": "张先生"}, CallerID: "13800000000"},
+			wantPrompt:   "客户张先生，来电号码13800000000",
+			wantGreeting: "张先生您好",
+		},
+		{
+			name:       "default timezone",
+			robot:      model.Robot{SystemPrompt: "{{.datetime}} {{.weekday}} {{.timezone}}"},
+			wantPrompt: "2024-05-01 09:30:00 Wednesday Asia/Shanghai",
+		},
+		{
+			name:       "robot timezone",
+			robot:      model.Robot{Timezone: "America/New_York", SystemPrompt: "{{.date}} {{.time}} {{.weekday}}"},
+			wantPrompt: "2024-04-30 21:30 Tuesday",
+		},
+		{
+			name:       "robot fields",
+			robot:      model.Robot{ID: 7, Name: "小美", Speaker: "xiaomei", SystemPrompt: "{{.robot.id}}/{{.robot.name}}/{{.robot.speaker}}"},
+			wantPrompt: "7/小美/xiaomei",
+		},
+		{
+			name:         "missing variables are empty",
+			robot:        model.Robot{SystemPrompt: "客户{{.vars.name}}，{{.unknown}}结束", Greeting: "您好{{.vars.name}}"},
+			wantPrompt:   "客户，结束",
+			wantGreeting: "您好",
+		},
+		{
+			name:       "missing nested variables are empty",
+			robot:      model.Robot{SystemPrompt: "[{{.customer.name}}]{{if .vip}}VIP{{end}}[{{$.unknown}}]"},
+			wantPrompt: "[][]",
+		},
+		{
+			name:         "literal no value is kept",
+			robot:        model.Robot{SystemPrompt: "缺失时系统会显示<no value>，{{.unknown}}请忽略", Greeting: "{{.vars.text}}"},
+			info:         CallInfo{Variables: map[string]string{"text": "<no value>"}},
+			wantPrompt:   "缺失时系统会显示<no value>，请忽略",
+			wantGreeting: "<no value>",
+		},
+		{
+			name:    "strict mode rejects missing variables",
+			robot:   model.Robot{TemplateStrict: true, SystemPrompt: "客户{{.vars.name}}"},
+			wantErr: "system_prompt: render template error",
+		},
+		{
+			name:    "strict mode checks the greeting",
+			robot:   model.Robot{TemplateStrict: true, Greeting: "{{.vars.name}}您好"},
+			info:    CallInfo{Variables: map[string]string{"city": "上海"}},
+			wantErr: "greeting: render template error",
+		},
+		{
+			name:    "invalid template",
+			robot:   model.Robot{SystemPrompt: "客户{{.vars.name"},
+			wantErr: "system_prompt: parse template error",
+		},
+		{
+			name:    "invalid timezone",
+			robot:   model.Robot{Timezone: "Mars/Olympus", SystemPrompt: "{{.time}}"},
+			wantErr: "invalid timezone Mars/Olympus",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := tt.info
+			info.Now = now
+			systemPrompt, greeting, err := RenderRobot(&tt.robot, info)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if systemPrompt != tt.wantPrompt {
+				t.Errorf("system prompt = %q, want %q", systemPrompt, tt.wantPrompt)
+			}
+			if greeting != tt.wantGreeting {
+				t.Errorf("greeting = %q, want %q", greeting, tt.wantGreeting)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		robot   model.Robot
+		wantErr string
+	}{
+		{name: "valid", robot: model.Robot{Timezone: "Europe/London", SystemPrompt: "{{.vars.name}}", Greeting: "您好"}},
+		{name: "missing variables are allowed", robot: model.Robot{TemplateStrict: true, SystemPrompt: "{{.vars.name}}"}},
+		{name: "invalid timezone", robot: model.Robot{Timezone: "Asia/Nowhere"}, wantErr: "invalid timezone Asia/Nowhere"},
+		{name: "invalid system prompt", robot: model.Robot{SystemPrompt: "{{if .vars.vip}}"}, wantErr: "system_prompt: parse template error"},
+		{name: "invalid greeting", robot: model.Robot{Greeting: "{{.vars.name"}, wantErr: "greeting: parse template error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(&tt.robot)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package provider
+
+import (
+	"miniRustpbxgo/internal/model"
+)
+
+// aliyunProvider 阿里云智能语音交互，AppID 对应 AppKey，SecretID/SecretKey 对应 AccessKey
+type aliyunProvider struct{}
+
+func (p *aliyunProvider) Name() string {
+	return "aliyun"
+}
+
+func (p *aliyunProvider) ASRCredentialFields() []string {
+	return []string{FieldAppID, FieldSecretID, FieldSecretKey}
+}
+
+func (p *aliyunProvider) TTSCredentialFields() []string {
+	return []string{FieldAppID, FieldSecretID, FieldSecretKey}
+}
+
+// Speakers 阿里云发音人 voice，详见阿里云语音合成发音人列表
+func (p *aliyunProvider) Speakers() []string {
+	return []string{
+		"xiaoyun",  // 小云，标准女声
+		"xiaogang", // 小刚，标准男声
+		"ruoxi",    // 若兮，温柔女声
+		"siqi",     // 思琪，温柔女声
+		"sijia",    // 思佳，标准女声
+		"sicheng",  // 思诚，标准男声
+		"aiqi",     // 艾琪，温柔女声
+		"aijia",    // 艾佳，标准女声
+		"aicheng",  // 艾诚，标准男声
+		"aida",     // 艾达，标准男声
+		"ninger",   // 宁儿，标准女声
+		"ruilin",   // 瑞琳，标准女声
+		"aiya",     // 艾雅，严厉女声
+		"aixia",    // 艾夏，亲和女声
+		"aimei",    // 艾美，甜美女声
+		"aiyu",     // 艾雨，自然女声
+		"aiyue",    // 艾悦，温柔女声
+		"aijing",   // 艾婧，严厉女声
+		"kelly",    // Kelly，香港粤语女声
+		"abby",     // Abby，美音女声
+		"andy",     // Andy，美音男声
+	}
+}
+
+func (p *aliyunProvider) Languages() []string {
+	return []string{"zh", "en", "ca"}
+}
+
+func (p *aliyunProvider) SampleRates() []int {
+	return []int{16000, 8000, 24000}
+}
+
+func (p *aliyunProvider) BuildASROption(key *model.RobotKey, robot *model.Robot) *model.ASROption {
+	language := trimModelPrefix(key.ASRLanguage)
+	if language == "" {
+		language = "zh"
+	}
+	return &model.ASROption{
+		Provider:   p.Name(),
+		AppID:      key.ASRAppID,
+		SecretID:   key.ASRSecretID,
+		SecretKey:  key.ASRSecretKey,
+		Language:   language,
+		SampleRate: 16000,
+	}
+}
+
+func (p *aliyunProvider) BuildTTSOption(key *model.RobotKey, robot *model.Robot) *model.TTSOption {
+	return &model.TTSOption{
+		Provider:   p.Name(),
+		Samplerate: ttsSampleRate(p, robot),
+		Speaker:    robot.Speaker,
+		AppID:      key.TTSAppID,
+		SecretID:   key.TTSSecretID,
+		SecretKey:  key.TTSSecretKey,
+		Speed:      robot.Speed,
+		Volume:     int32(robot.Volume),
+		Emotion:    robot.Emotion,
+	}
+}
@@ -0,0 +1,231 @@
+package provider
+
+import (
+	"fmt"
+	"miniRustpbxgo/internal/model"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultProvider 未填写服务商时使用的默认服务商（兼容历史数据）
+const DefaultProvider = "tencent"
+
+// 凭证字段名，与 RobotKey 的 json 字段保持一致
+const (
+	FieldAppID     = "app_id"
+	FieldSecretID  = "secret_id"
+	FieldSecretKey = "secret_key"
+)
+
+// SpeechProvider 语音服务商（ASR/TTS）的能力描述
+type SpeechProvider interface {
+	// Name 服务商名称，与 RobotKey.ASRProvider/TTSProvider 对应
+	Name() string
+	// ASRCredentialFields 语音识别必填的凭证字段
+	ASRCredentialFields() []string
+	// TTSCredentialFields 语音合成必填的凭证字段
+	TTSCredentialFields() []string
+	// Speakers 支持的发音人
+	Speakers() []string
+	// Languages 支持的识别语言
+	Languages() []string
+	// SampleRates 支持的合成采样率，第一个为默认值
+	SampleRates() []int
+	// BuildASROption 根据密钥和机器人配置构建语音识别参数
+	BuildASROption(key *model.RobotKey, robot *model.Robot) *model.ASROption
+	// BuildTTSOption 根据密钥和机器人配置构建语音合成参数
+	BuildTTSOption(key *model.RobotKey, robot *model.Robot) *model.TTSOption
+}
+
+// Info 服务商能力的序列化形式，供前端选择使用
+type Info struct {
+	Name        string   `json:"name"`
+	ASRFields   []string `json:"asr_fields"`
+	TTSFields   []string `json:"tts_fields"`
+	Speakers    []string `json:"speakers"`
+	Languages   []string `json:"languages"`
+	SampleRates []int    `json:"sample_rates"`
+}
+
+var (
+	registryMutex sync.RWMutex
+	registry      = map[string]SpeechProvider{}
+)
+
+func init() {
+	Register(&tencentProvider{})
+	Register(&aliyunProvider{})
+}
+
+// Register 注册服务商，同名覆盖
+func Register(p SpeechProvider) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	registry[p.Name()] = p
+}
+
+// Get 根据名称获取服务商，名称为空或为历史密钥的服务地址时返回默认服务商
+func Get(name string) (SpeechProvider, error) {
+	name, _ = splitProvider(name)
+	if name == "" {
+		name = DefaultProvider
+	}
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	p, ok := registry[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported provider: %s", name)
+	}
+	return p, nil
+}
+
+// List 按名称排序返回所有已注册服务商的能力描述
+func List() []Info {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	infos := make([]Info, 0, len(registry))
+	for _, p := range registry {
+		infos = append(infos, Info{
+			Name:        p.Name(),
+			ASRFields:   p.ASRCredentialFields(),
+			TTSFields:   p.TTSCredentialFields(),
+			Speakers:    p.Speakers(),
+			Languages:   p.Languages(),
+			SampleRates: p.SampleRates(),
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// ValidateRobotKey 校验密钥的服务商、凭证字段和识别语言
+func ValidateRobotKey(key *model.RobotKey) error {
+	asr, err := Get(key.ASRProvider)
+	if err != nil {
+		return fmt.Errorf("asr_provider: %w", err)
+	}
+	asrCredentials := map[string]string{
+		FieldAppID:     key.ASRAppID,
+		FieldSecretID:  key.ASRSecretID,
+		FieldSecretKey: key.ASRSecretKey,
+	}
+	for _, field := range asr.ASRCredentialFields() {
+		if asrCredentials[field] == "" {
+			return fmt.Errorf("asr_%s is required by provider %s", field, asr.Name())
+		}
+	}
+	if key.ASRLanguage != "" && !contains(asr.Languages(), trimModelPrefix(key.ASRLanguage)) {
+		return fmt.Errorf("asr_language %s is not supported by provider %s", key.ASRLanguage, asr.Name())
+	}
+
+	tts, err := Get(key.TTSProvider)
+	if err != nil {
+		return fmt.Errorf("tts_provider: %w", err)
+	}
+	ttsCredentials := map[string]string{
+		FieldAppID:     key.TTSAppID,
+		FieldSecretID:  key.TTSSecretID,
+		FieldSecretKey: key.TTSSecretKey,
+	}
+	for _, field := range tts.TTSCredentialFields() {
+		if ttsCredentials[field] == "" {
+			return fmt.Errorf("tts_%s is required by provider %s", field, tts.Name())
+		}
+	}
+	return nil
+}
+
+// ValidateRobot 校验机器人的发音人和采样率是否被其语音合成服务商支持
+func ValidateRobot(robot *model.Robot) error {
+	tts, err := Get(robot.TTSProvider)
+	if err != nil {
+		return fmt.Errorf("tts_provider: %w", err)
+	}
+	if robot.Speaker != "" && !contains(tts.Speakers(), robot.Speaker) {
+		return fmt.Errorf("speaker %s is not supported by provider %s", robot.Speaker, tts.Name())
+	}
+	if robot.SampleRate != 0 && !containsInt(tts.SampleRates(), robot.SampleRate) {
+		return fmt.Errorf("sample_rate %d is not supported by provider %s", robot.SampleRate, tts.Name())
+	}
+	return nil
+}
+
+// BuildOptions 根据密钥上的服务商构建通话所需的 ASR/TTS 参数。
+// 机器人的发音人和采样率按机器人的服务商校验，与密钥的服务商不一致时返回错误
+func BuildOptions(key *model.RobotKey, robot *model.Robot) (*model.ASROption, *model.TTSOption, error) {
+	asr, err := Get(key.ASRProvider)
+	if err != nil {
+		return nil, nil, err
+	}
+	tts, err := Get(key.TTSProvider)
+	if err != nil {
+		return nil, nil, err
+	}
+	robotTTS, err := Get(robot.TTSProvider)
+	if err != nil {
+		return nil, nil, err
+	}
+	// 未指定服务商且未设置发音人、采样率的机器人可以使用任意服务商的默认音色
+	customized := robot.TTSProvider != "" || robot.Speaker != "" || robot.SampleRate != 0
+	if customized && robotTTS.Name() != tts.Name() {
+		return nil, nil, fmt.Errorf("robot tts_provider %s does not match key tts_provider %s", robotTTS.Name(), tts.Name())
+	}
+	asrOption, ttsOption := asr.BuildASROption(key, robot), tts.BuildTTSOption(key, robot)
+	if _, endpoint := splitProvider(key.ASRProvider); endpoint != "" {
+		asrOption.Endpoint = endpoint
+	}
+	if _, endpoint := splitProvider(key.TTSProvider); endpoint != "" {
+		ttsOption.Endpoint = endpoint
+	}
+	return asrOption, ttsOption, nil
+}
+
+// splitProvider 历史密钥的服务商字段保存的是服务地址，此时使用默认服务商并以该地址作为接入点
+func splitProvider(value string) (name string, endpoint string) {
+	if strings.Contains(value, "://") {
+		return "", value
+	}
+	return value, ""
+}
+
+// trimModelPrefix 去掉历史密钥识别语言中的采样率前缀，如 16k_zh 返回 zh
+func trimModelPrefix(language string) string {
+	prefix, rest, ok := strings.Cut(language, "k_")
+	if !ok || prefix == "" {
+		return language
+	}
+	for _, r := range prefix {
+		if r < '0' || r > '9' {
+			return language
+		}
+	}
+	return rest
+}
+
+// ttsSampleRate 机器人未指定或指定了不支持的采样率时回退到服务商默认值
+func ttsSampleRate(p SpeechProvider, robot *model.Robot) int32 {
+	rates := p.SampleRates()
+	if robot.SampleRate != 0 && containsInt(rates, robot.SampleRate) {
+		return int32(robot.SampleRate)
+	}
+	return int32(rates[0])
+}
+
+func contains(list []string, target string) bool {
+	for _, item := range list {
+		if item == target {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(list []int, target int) bool {
+	for _, item := range list {
+		if item == target {
+			return true
+		}
+	}
+	return false
+}
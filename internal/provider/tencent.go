@@ -0,0 +1,83 @@
+package provider
+
+import (
+	"miniRustpbxgo/internal/model"
+)
+
+// tencentProvider 腾讯云语音识别/语音合成
+type tencentProvider struct{}
+
+func (p *tencentProvider) Name() string {
+	return "tencent"
+}
+
+func (p *tencentProvider) ASRCredentialFields() []string {
+	return []string{FieldAppID, FieldSecretID, FieldSecretKey}
+}
+
+func (p *tencentProvider) TTSCredentialFields() []string {
+	return []string{FieldAppID, FieldSecretID, FieldSecretKey}
+}
+
+// Speakers 腾讯云音色 VoiceType，详见腾讯云语音合成音色列表
+func (p *tencentProvider) Speakers() []string {
+	return []string{
+		"101001", // 智瑜，情感女声
+		"101002", // 智聆，通用女声
+		"101003", // 智美，客服女声
+		"101004", // 智云，通用男声
+		"101005", // 智莉，通用女声
+		"101006", // 智言，助手女声
+		"101007", // 智娜，客服女声
+		"101008", // 智琪，客服女声
+		"101009", // 智芸，知性女声
+		"101010", // 智华，通用男声
+		"101011", // 智燕，新闻女声
+		"101013", // 智辉，新闻男声
+		"101015", // 智萌，男童声
+		"101016", // 智甜，女童声
+		"101019", // 智彤，粤语女声
+		"101050", // WeJack，英文男声
+		"101051", // WeRose，英文女声
+		"301000", // 爱小广，通用男声
+	}
+}
+
+// Languages 对应引擎模型类型中的语言部分，如 16k_zh
+func (p *tencentProvider) Languages() []string {
+	return []string{"zh", "en", "ca", "ja", "ko", "zh_dialect"}
+}
+
+func (p *tencentProvider) SampleRates() []int {
+	return []int{16000, 8000, 24000}
+}
+
+func (p *tencentProvider) BuildASROption(key *model.RobotKey, robot *model.Robot) *model.ASROption {
+	language := trimModelPrefix(key.ASRLanguage)
+	if language == "" {
+		language = "zh"
+	}
+	return &model.ASROption{
+		Provider:   p.Name(),
+		AppID:      key.ASRAppID,
+		SecretID:   key.ASRSecretID,
+		SecretKey:  key.ASRSecretKey,
+		Language:   language,
+		ModelType:  "16k_" + language,
+		SampleRate: 16000,
+	}
+}
+
+func (p *tencentProvider) BuildTTSOption(key *model.RobotKey, robot *model.Robot) *model.TTSOption {
+	return &model.TTSOption{
+		Provider:   p.Name(),
+		Samplerate: ttsSampleRate(p, robot),
+		Speaker:    robot.Speaker,
+		AppID:      key.TTSAppID,
+		SecretID:   key.TTSSecretID,
+		SecretKey:  key.TTSSecretKey,
+		Speed:      robot.Speed,
+		Volume:     int32(robot.Volume),
+		Emotion:    robot.Emotion,
+	}
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"github.com/sirupsen/logrus"
+	"miniRustpbxgo/internal/model"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// answeringMachineHangupReason 检测到答录机直接挂断时的挂断原因
+	answeringMachineHangupReason = "answering_machine"
+	// voicemailHangupReason 给答录机留言后的挂断原因
+	voicemailHangupReason = "voicemail"
+	// voicemailQuietWait 答录机安静多久后认为提示音已结束，开始留言
+	voicemailQuietWait = 1500 * time.Millisecond
+	// voicemailMaxWait 等待提示音结束的最长时间，超时后直接留言
+	voicemailMaxWait = 15 * time.Second
+	// voicemailStopWait 等待rust停止机器人播放的最长时间，未回传trackEnd或interruption时也开始等待提示音
+	voicemailStopWait = 2 * time.Second
+)
+
+// amdState 外呼的答录机检测结果，留言策略下等待答录机提示音结束
+type amdState struct {
+	mutex      sync.Mutex
+	detected   bool        // 本通电话已有检测结果
+	stopping   bool        // 已让rust停止机器人播放，等待trackEnd或interruption
+	waiting    bool        // 正在等待提示音结束后留言
+	stopTimer  *time.Timer // 等待机器人停止播放的最长时间
+	quietTimer *time.Timer // 答录机安静一段时间后留言
+	maxTimer   *time.Timer // 等待提示音的最长时间
+}
+
+// parseAmdResult 解析rust回传的检测结果，返回AnsweredByMachine、AnsweredByHuman，无法判断时返回空
+func parseAmdResult(text string) string {
+	result := strings.ToLower(strings.TrimSpace(text))
+	switch {
+	case strings.Contains(result, "machine"), strings.Contains(result, "voicemail"):
+		return model.AnsweredByMachine
+	case strings.Contains(result, "human"), strings.Contains(result, "person"):
+		return model.AnsweredByHuman
+	}
+	return ""
+}
+
+// SolveAnswerMachine rust回传外呼的答录机检测结果，记录到通话记录，是答录机时按机器人配置挂断、留言或继续对话
+func (backendForWeb *BackendForWeb) SolveAnswerMachine(text string) {
+	record := backendForWeb.callRecordSnapshot()
+	if record == nil || record.Direction != model.CallDirectionOutbound {
+		logrus.Info("answer machine detection only applies to outbound calls, ignore")
+		return
+	}
+	answeredBy := parseAmdResult(text)
+	if answeredBy == "" {
+		logrus.WithField("text", text).Info("answer machine detection is not sure, ignore")
+		return
+	}
+	state := &backendForWeb.amd
+	state.mutex.Lock()
+	if state.detected {
+		state.mutex.Unlock()
+		return
+	}
+	state.detected = true
+	state.mutex.Unlock()
+
+	backendForWeb.updateCallRecord(func(record *model.CallRecord) map[string]interface{} {
+		record.AnsweredBy = answeredBy
+		return map[string]interface{}{"answered_by": answeredBy}
+	})
+	if answeredBy == model.AnsweredByHuman {
+		logrus.WithField("callID", record.CallID).Info("human answered")
+		return
+	}
+	policy := model.AmdPolicyContinue
+	if backendForWeb.Robot != nil && backendForWeb.Robot.AmdPolicy != "" {
+		policy = backendForWeb.Robot.AmdPolicy
+	}
+	logrus.WithFields(logrus.Fields{
+		"callID": record.CallID,
+		"policy": policy,
+		"text":   text,
+	}).Info("answering machine detected")
+
+	switch policy {
+	case model.AmdPolicyHangup:
+		if backendForWeb.beginClosing() {
+			backendForWeb.hangupWithReason(answeringMachineHangupReason)
+		}
+	case model.AmdPolicyVoicemail:
+		if !backendForWeb.beginClosing() {
+			return
+		}
+		// 先停止机器人正在说的话，rust确认停止后再开始等待提示音结束
+		playing := backendForWeb.isPlaying()
+		backendForWeb.stopRobotSpeaking()
+		state.mutex.Lock()
+		defer state.mutex.Unlock()
+		state.waiting = true
+		if !playing {
+			state.armVoicemailLocked(backendForWeb)
+			return
+		}
+		state.stopping = true
+		state.stopTimer = time.AfterFunc(voicemailStopWait, backendForWeb.solveAmdPlaybackStopped)
+	}
+}
+
+// stopRobotSpeaking 取消大模型生成并让rust停止播放，为留言让出线路
+func (backendForWeb *BackendForWeb) stopRobotSpeaking() {
+	backendForWeb.trackPlaybackInterrupted()
+	if backendForWeb.LLMHandler != nil {
+		backendForWeb.LLMHandler.Interrupt()
+	}
+	if err := backendForWeb.sendCommandToRust(model.InterruptCommand{Command: "interrupt"}); err != nil {
+		logrus.Errorf("send interrupt command error:%v", err)
+	}
+}
+
+// solveAmdPlaybackStopped rust回传trackEnd或interruption，机器人已停止播放，开始等待提示音结束
+func (backendForWeb *BackendForWeb) solveAmdPlaybackStopped() {
+	state := &backendForWeb.amd
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	if !state.waiting || !state.stopping {
+		return
+	}
+	state.stopping = false
+	if state.stopTimer != nil {
+		state.stopTimer.Stop()
+		state.stopTimer = nil
+	}
+	state.armVoicemailLocked(backendForWeb)
+}
+
+// armVoicemailLocked 开始等待答录机提示音结束，调用方需持有mutex
+func (state *amdState) armVoicemailLocked(backendForWeb *BackendForWeb) {
+	state.quietTimer = time.AfterFunc(voicemailQuietWait, backendForWeb.leaveVoicemail)
+	state.maxTimer = time.AfterFunc(voicemailMaxWait, backendForWeb.leaveVoicemail)
+}
+
+// solveAmdSpeaking 答录机还在播放提示语或提示音，推迟留言
+func (backendForWeb *BackendForWeb) solveAmdSpeaking() {
+	state := &backendForWeb.amd
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	if state.waiting && !state.stopping && state.quietTimer != nil {
+		state.quietTimer.Stop()
+		state.quietTimer = nil
+	}
+}
+
+// solveAmdSilence 答录机停止发声，安静一段时间后开始留言
+func (backendForWeb *BackendForWeb) solveAmdSilence() {
+	state := &backendForWeb.amd
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	// 机器人还没停止播放时不开始计时
+	if !state.waiting || state.stopping {
+		return
+	}
+	if state.quietTimer != nil {
+		state.quietTimer.Stop()
+	}
+	state.quietTimer = time.AfterFunc(voicemailQuietWait, backendForWeb.leaveVoicemail)
+}
+
+// leaveVoicemail 播放留言音频或合成留言文本，播完后挂断
+func (backendForWeb *BackendForWeb) leaveVoicemail() {
+	state := &backendForWeb.amd
+	state.mutex.Lock()
+	if !state.waiting || state.stopping {
+		state.mutex.Unlock()
+		return
+	}
+	state.stopTimersLocked()
+	state.mutex.Unlock()
+
+	robot := backendForWeb.Robot
+	if robot != nil && robot.VoicemailURL != "" {
+		logrus.Info("leave voicemail audio")
+		// 音频由rust播完后挂断，提前记录挂断原因
+		backendForWeb.recordHangupReason(voicemailHangupReason, robotHangupInitiator)
+		if err := backendForWeb.SendPlayCommandForRustBackend(robot.VoicemailURL, true); err != nil {
+			logrus.Errorf("play voicemail error:%v", err)
+			backendForWeb.hangupWithReason(voicemailHangupReason)
+		}
+		return
+	}
+	if robot == nil || robot.VoicemailText == "" {
+		backendForWeb.hangupWithReason(answeringMachineHangupReason)
+		return
+	}
+	logrus.Info("leave voicemail text")
+	playID := backendForWeb.speakPrompt(robot.VoicemailText, "voicemail")
+	if playID == "" {
+		backendForWeb.hangupWithReason(voicemailHangupReason)
+		return
+	}
+	backendForWeb.scheduleHangup(playID, voicemailHangupReason)
+}
+
+// stopTimersLocked 停止等待留言的计时，调用方需持有mutex
+func (state *amdState) stopTimersLocked() {
+	state.waiting = false
+	state.stopping = false
+	for _, timer := range []*time.Timer{state.stopTimer, state.quietTimer, state.maxTimer} {
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+	state.stopTimer = nil
+	state.quietTimer = nil
+	state.maxTimer = nil
+}
+
+// resetAmd 通话结束或新通话开始时停止等待留言，清理答录机检测结果
+func (backendForWeb *BackendForWeb) resetAmd() {
+	state := &backendForWeb.amd
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	state.stopTimersLocked()
+	state.detected = false
+}
@@ -0,0 +1,32 @@
+package service
+
+import (
+	"testing"
+
+	"miniRustpbxgo/internal/model"
+)
+
+func TestParseAmdResult(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "machine", text: "MACHINE", want: model.AnsweredByMachine},
+		{name: "voicemail", text: " voicemail ", want: model.AnsweredByMachine},
+		{name: "machine with suffix", text: "machine_end_beep", want: model.AnsweredByMachine},
+		{name: "human", text: "Human", want: model.AnsweredByHuman},
+		{name: "person", text: "person", want: model.AnsweredByHuman},
+		{name: "machine wins over human", text: "human_or_machine", want: model.AnsweredByMachine},
+		{name: "not sure", text: "notsure", want: ""},
+		{name: "unknown", text: "unknown", want: ""},
+		{name: "empty", text: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseAmdResult(tt.text); got != tt.want {
+				t.Errorf("parseAmdResult(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -12,6 +12,8 @@ type App struct {
 	Rdb            *redis.Client
 	BackendForRust *BackendForRust
 	FrontendForWeb *BackendForWeb
+
+	sipCalls sipCallRegistry // 进行中的SIP外呼和呼入
 }
 
 const (
@@ -27,6 +29,7 @@ func NewApp() *App {
 	app.BackendForRust = NewBackendForRust(endPoint)
 	app.FrontendForWeb = NewBackendForWebByNoParam(app.DB)
 	go app.BackendForRust.Connect(callType, app.FrontendForWeb)
+	go app.ListenInbound()
 	return app
 }
 
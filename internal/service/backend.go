@@ -13,6 +13,13 @@ type BackendForRust struct {
 
 type Event struct {
 	Event     string `json:"event"`
+	TrackID   string `json:"trackId,omitempty"`
+	PlayID    string `json:"playId,omitempty"`
+	Position  uint64 `json:"position,omitempty"`
+	Complete  bool   `json:"complete,omitempty"` // eou事件中用户是否已说完
+	Duration  uint64 `json:"duration,omitempty"` // trackEnd事件中本段的播放毫秒数
+	State     string `json:"state,omitempty"`    // playback事件中playID的播放状态
+	Digit     string `json:"digit,omitempty"`    // dtmf事件中用户的按键
 	Text      string `json:"text"`
 	Sdp       string `json:"sdp"`
 	Reason    string `json:"reason"`
@@ -31,20 +38,29 @@ func NewBackendForRustByNoParam() *BackendForRust {
 	return &BackendForRust{}
 }
 
+// Dial 按通话类型建立一次go到rust的ws连接
+func (backendForRust *BackendForRust) Dial(callType string) (*websocket.Conn, error) {
+	url := backendForRust.EndPoint
+	url += "/call/" + callType
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		return nil, err
+	}
+	backendForRust.GoToRustConn = conn
+	return conn, nil
+}
+
 // Connect 创建go到rust的ws连接
 func (backendForRust *BackendForRust) Connect(callType string, backendForWeb *BackendForWeb) {
 	for {
 		if backendForRust.GoToRustConn == nil {
-			url := backendForRust.EndPoint
-			url += "/call/" + callType
-
-			conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+			conn, err := backendForRust.Dial(callType)
 			if err != nil {
 				logrus.Error("goBackend connect rustBackend error", err)
 				continue
 			}
 			logrus.Info("goBackend to rustBackend successfully connected")
-			backendForRust.GoToRustConn = conn
 			backendForWeb.GoToRustConn = conn
 			// 监听机制
 			go backendForRust.ListenGoToRustWs(backendForWeb)
@@ -86,7 +102,7 @@ func (backendForRust *BackendForRust) ListenGoToRustWs(backendForWeb *BackendFor
 			logrus.Error("Received non-text message: ", msgType)
 			continue
 		}
-		logrus.Info("Received from rust backend (type %d): %s", msgType, string(msg))
+		logrus.Infof("Received from rust backend (type %d): %s", msgType, string(msg))
 
 		var event Event
 
@@ -98,23 +114,72 @@ func (backendForRust *BackendForRust) ListenGoToRustWs(backendForWeb *BackendFor
 		switch event.Event {
 		case "asrFinal":
 			logrus.Info("Received asrFinal message: ", event)
+			// 只做合并和计时，回复在单独的goroutine中生成，以便继续处理speaking等事件
 			backendForWeb.SolveAsrFinalEvent(&event)
+		case "dtmf":
+			logrus.Info("Received dtmf message: ", event)
+			backendForWeb.SolveDtmf(event.Digit)
+		case "eou":
+			logrus.Info("Received eou message: ", event)
+			backendForWeb.SolveEou(event.Complete)
+		case "answer":
+			logrus.Info("Received answer message")
+			backendForWeb.markCallAnswered()
+			backendForWeb.startCallLimit()
+			backendForWeb.SolveCallAnswered()
+			backendForWeb.SolveTransferAnswered()
+		case "answerMachineDetection":
+			logrus.Info("Received answerMachineDetection message: ", event)
+			backendForWeb.SolveAnswerMachine(event.Text)
 		case "asrDelta":
 			logrus.Info("Received asrDelta message: ", event)
 		case "error":
 			logrus.Error("Received an error message: ", event)
+			backendForWeb.SolveTransferRejected("error")
+			backendForWeb.SolveCallFailed(event.Error)
+		case "reject":
+			logrus.Info("Received reject message: ", event)
+			backendForWeb.SolveTransferRejected("reject")
+			backendForWeb.SolveCallFailed(event.Reason)
 		case "close":
 			logrus.Info("Received close message: ", event)
 		case "hangup":
 			logrus.Info("Received hangup message: ", event)
+			backendForWeb.finishCallRecord(event.Reason, event.Initiator)
+			backendForWeb.endSilence()
+			backendForWeb.stopCallLimit()
+			backendForWeb.resetTransfer()
+			backendForWeb.resetAmd()
+			backendForWeb.endCall()
 		case "speaking":
 			logrus.Info("Received speaking message: ", event)
+			// 噪音也会触发speaking，只停止计时，有识别结果时才重新计算追问次数
+			backendForWeb.stopSilenceTimer()
+			backendForWeb.solveAmdSpeaking()
+			backendForWeb.SolveSpeaking()
 		case "silence":
 			logrus.Info("Received silence message: ", event)
+			backendForWeb.SolveSilence()
+			backendForWeb.solveAmdSilence()
+			// 用户说了话但没有识别结果（如噪音）时，从此刻起重新计算沉默
+			backendForWeb.armSilenceTimer()
+		case "interruption":
+			logrus.Info("Received interruption message: ", event)
+			backendForWeb.SolveInterruption(event.Position)
+			backendForWeb.solveAmdPlaybackStopped()
 		case "trackStart":
 			logrus.Info("Received trackStart message: ", event)
+			backendForWeb.trackPlaybackStarted(event.PlayID)
+			backendForWeb.stopSilenceTimer()
+			backendForWeb.SolveCallAnswered()
 		case "trackEnd":
 			logrus.Info("Received trackStop message: ", event)
+			backendForWeb.solveAmdPlaybackStopped()
+			// 一轮回复的多个分段共用playID，所有分段播完才算机器人说完
+			if backendForWeb.trackPlaybackEnded(event.PlayID, event.Duration) {
+				backendForWeb.SolvePlaybackEnd(event.PlayID)
+				backendForWeb.armSilenceTimer()
+			}
 		}
 
 		backendForWeb.ForwardToWebConn(&event)
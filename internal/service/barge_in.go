@@ -0,0 +1,161 @@
+package service
+
+import (
+	"github.com/sirupsen/logrus"
+	"miniRustpbxgo/internal/model"
+	"miniRustpbxgo/internal/normalize"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 语速为1.0时语音合成每秒大约播报的字符数，用于根据播放位置估算被打断的分段已播报的文本。
+// 英文按每秒两个半单词，连同空格和标点计算
+const (
+	ttsRunesPerSecondZh = 4.5
+	ttsRunesPerSecondEn = 15
+)
+
+// isInterrupted 判断playID是否已被用户打断
+func (backendForWeb *BackendForWeb) isInterrupted(playID string) bool {
+	backendForWeb.bargeInMutex.Lock()
+	defer backendForWeb.bargeInMutex.Unlock()
+	return playID != "" && playID == backendForWeb.interruptedPlayID
+}
+
+// SolveSpeaking 用户开始说话时，若机器人正在说话或生成回复，按机器人的打断策略触发打断
+func (backendForWeb *BackendForWeb) SolveSpeaking() {
+	robot := backendForWeb.Robot
+	// 结束语和留言不允许打断
+	if robot == nil || !robot.BargeIn || backendForWeb.LLMHandler == nil || backendForWeb.isCallClosing() {
+		return
+	}
+	backendForWeb.bargeInMutex.Lock()
+	if !backendForWeb.robotBusyLocked() || backendForWeb.bargeInTimer != nil {
+		backendForWeb.bargeInMutex.Unlock()
+		return
+	}
+	if robot.BargeInMinMs <= 0 {
+		backendForWeb.bargeInMutex.Unlock()
+		backendForWeb.bargeIn()
+		return
+	}
+	// 用户持续说话达到最短时长才打断，避免咳嗽、附和等短暂声音打断机器人
+	backendForWeb.bargeInTimer = time.AfterFunc(time.Duration(robot.BargeInMinMs)*time.Millisecond, func() {
+		backendForWeb.bargeInMutex.Lock()
+		backendForWeb.bargeInTimer = nil
+		// 等待期间机器人可能已经说完
+		busy := backendForWeb.robotBusyLocked()
+		backendForWeb.bargeInMutex.Unlock()
+		if busy {
+			backendForWeb.bargeIn()
+		}
+	})
+	backendForWeb.bargeInMutex.Unlock()
+}
+
+// robotBusyLocked 机器人有语音在排队或播放，或正在生成回复，调用方需持有bargeInMutex
+func (backendForWeb *BackendForWeb) robotBusyLocked() bool {
+	return backendForWeb.isPlaying() || backendForWeb.currentLLM().Generating()
+}
+
+// SolveSilence 用户停止说话时取消未达到最短时长的打断
+func (backendForWeb *BackendForWeb) SolveSilence() {
+	backendForWeb.bargeInMutex.Lock()
+	defer backendForWeb.bargeInMutex.Unlock()
+	if backendForWeb.bargeInTimer != nil {
+		backendForWeb.bargeInTimer.Stop()
+		backendForWeb.bargeInTimer = nil
+	}
+}
+
+// bargeIn 打断机器人：取消大模型生成，丢弃未发送的分段并让rust停止播放
+func (backendForWeb *BackendForWeb) bargeIn() {
+	playID := backendForWeb.activePlayback()
+	backendForWeb.bargeInMutex.Lock()
+	backendForWeb.interruptedPlayID = playID
+	// 只在打断了回复的播放时按位置截断历史，填充语等不在历史中的播放不截断
+	backendForWeb.awaitingPosition = playID != "" && !strings.HasPrefix(playID, fillerPlayIDPrefix)
+	backendForWeb.bargeInMutex.Unlock()
+
+	backendForWeb.trackPlaybackInterrupted()
+	generating := backendForWeb.currentLLM().Interrupt()
+	logrus.WithFields(logrus.Fields{
+		"playID":     playID,
+		"generating": generating,
+	}).Info("caller barged in")
+	if err := backendForWeb.sendCommandToRust(model.InterruptCommand{Command: "interrupt"}); err != nil {
+		logrus.Errorf("send interrupt command error:%v", err)
+	}
+	backendForWeb.ForwardToWebConn(&Event{
+		Event:  "bargeIn",
+		PlayID: playID,
+	})
+}
+
+// SolveInterruption rust停止播放后回传当前分段的播放位置，截断大模型历史中未播报的内容：
+// 已播完的分段按发送的文本保留，被打断的分段按播放位置估算
+func (backendForWeb *BackendForWeb) SolveInterruption(position uint64) {
+	backendForWeb.bargeInMutex.Lock()
+	playID := backendForWeb.interruptedPlayID
+	awaiting := backendForWeb.awaitingPosition
+	backendForWeb.awaitingPosition = false
+	backendForWeb.bargeInMutex.Unlock()
+	// 只处理本服务发起的打断
+	if !awaiting || backendForWeb.LLMHandler == nil {
+		return
+	}
+	playedRunes, current, ok := backendForWeb.playedText(playID)
+	if !ok {
+		// 没有记录发送的文本时无法估算，保留完整的回复
+		logrus.WithField("playID", playID).Warn("no text recorded for interrupted playback")
+		return
+	}
+	spokenRunes := playedRunes + spokenSourceRunes(current, position, backendForWeb.ttsRunesPerSecond())
+	logrus.WithFields(logrus.Fields{
+		"playID":      playID,
+		"position":    position,
+		"playedRunes": playedRunes,
+		"spokenRunes": spokenRunes,
+	}).Info("truncate interrupted assistant message")
+	backendForWeb.LLMHandler.TruncateLastAssistantMessage(spokenRunes)
+}
+
+// ttsRunesPerSecond 按识别语言和机器人语速估算每秒播报的字符数
+func (backendForWeb *BackendForWeb) ttsRunesPerSecond() float64 {
+	rate := float64(ttsRunesPerSecondZh)
+	if key := backendForWeb.RobotKey; key != nil && strings.HasPrefix(strings.ToLower(key.ASRLanguage), normalize.LanguageEn) {
+		rate = ttsRunesPerSecondEn
+	}
+	if backendForWeb.Robot != nil && backendForWeb.Robot.Speed > 0 {
+		rate *= float64(backendForWeb.Robot.Speed)
+	}
+	return rate
+}
+
+// spokenSourceRunes 被打断的分段播放到position毫秒时已播报的原文字数。
+// 先按语速估算合成文本已播报的比例，再按比例换算到原文，规范化展开的数字、网址等不会多算
+func spokenSourceRunes(text playbackText, position uint64, runesPerSecond float64) int {
+	spoken := utf8.RuneCountInString(text.spoken)
+	source := utf8.RuneCountInString(text.sourceText())
+	if spoken == 0 {
+		return 0
+	}
+	ratio := float64(position) / 1000 * runesPerSecond / float64(spoken)
+	if ratio >= 1 {
+		return source
+	}
+	return int(ratio * float64(source))
+}
+
+// resetBargeIn 新通话开始时清理打断状态
+func (backendForWeb *BackendForWeb) resetBargeIn() {
+	backendForWeb.bargeInMutex.Lock()
+	defer backendForWeb.bargeInMutex.Unlock()
+	if backendForWeb.bargeInTimer != nil {
+		backendForWeb.bargeInTimer.Stop()
+		backendForWeb.bargeInTimer = nil
+	}
+	backendForWeb.interruptedPlayID = ""
+	backendForWeb.awaitingPosition = false
+}
@@ -0,0 +1,58 @@
+package service
+
+import "testing"
+
+func TestSpokenSourceRunes(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     playbackText
+		position uint64
+		rate     float64
+		want     int
+	}{
+		{name: "not started", text: playbackText{spoken: "您好，欢迎致电"}, position: 0, rate: ttsRunesPerSecondZh, want: 0},
+		{name: "chinese", text: playbackText{spoken: "您好，欢迎致电客服中心"}, position: 1000, rate: ttsRunesPerSecondZh, want: 4},
+		{name: "english is faster", text: playbackText{spoken: "Hello, thanks for calling us today."}, position: 1000, rate: ttsRunesPerSecondEn, want: 15},
+		{name: "played to the end", text: playbackText{spoken: "好的"}, position: 3000, rate: ttsRunesPerSecondZh, want: 2},
+		{
+			// half of the spoken text maps to half of the source
+			name:     "mapped to the source",
+			text:     playbackText{source: "共1005人", spoken: "共一千零五人"},
+			position: 667,
+			rate:     ttsRunesPerSecondZh,
+			want:     3,
+		},
+		{name: "nothing spoken", text: playbackText{source: "😊"}, position: 1000, rate: ttsRunesPerSecondZh, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := spokenSourceRunes(tt.text, tt.position, tt.rate); got != tt.want {
+				t.Errorf("spokenSourceRunes = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlayedText(t *testing.T) {
+	backendForWeb := NewBackendForWebByNoParam(nil)
+	backendForWeb.trackPlaybackQueued("reply", "第一句。", false)
+	backendForWeb.trackPlaybackQueued("reply", "第二句一千。", false)
+	backendForWeb.trackPlaybackSource("reply", "第二句1000。")
+	backendForWeb.trackPlaybackStarted("reply")
+	backendForWeb.trackPlaybackEnded("reply", 900)
+	backendForWeb.trackPlaybackInterrupted()
+
+	playedRunes, current, ok := backendForWeb.playedText("reply")
+	if !ok {
+		t.Fatal("no text recorded for reply")
+	}
+	if playedRunes != 4 {
+		t.Errorf("playedRunes = %d, want 4", playedRunes)
+	}
+	if want := (playbackText{source: "第二句1000。", spoken: "第二句一千。"}); current != want {
+		t.Errorf("current = %+v, want %+v", current, want)
+	}
+	if _, _, ok := backendForWeb.playedText("unknown"); ok {
+		t.Error("playedText found an unknown playID")
+	}
+}
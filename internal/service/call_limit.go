@@ -0,0 +1,166 @@
+package service
+
+import (
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+const (
+	// defaultDurationWarning 未配置提醒话术时使用
+	defaultDurationWarning = "温馨提示，本次通话即将结束。"
+	// defaultDurationGoodbye 未配置结束语时使用
+	defaultDurationGoodbye = "本次通话已达到最长时长，感谢您的来电，再见。"
+	// maxDurationHangupReason 达到最长通话时长时的挂断原因
+	maxDurationHangupReason = "max_duration"
+)
+
+// maxCallDuration 机器人和密钥上的最长通话时长取较小值，为0表示不限制
+func (backendForWeb *BackendForWeb) maxCallDuration() time.Duration {
+	limit := 0
+	for _, seconds := range []int{backendForWeb.robotMaxCallDurationSec(), backendForWeb.keyMaxCallDurationSec()} {
+		if seconds > 0 && (limit == 0 || seconds < limit) {
+			limit = seconds
+		}
+	}
+	return time.Duration(limit) * time.Second
+}
+
+func (backendForWeb *BackendForWeb) robotMaxCallDurationSec() int {
+	if backendForWeb.Robot == nil {
+		return 0
+	}
+	return backendForWeb.Robot.MaxCallDurationSec
+}
+
+func (backendForWeb *BackendForWeb) keyMaxCallDurationSec() int {
+	if backendForWeb.RobotKey == nil {
+		return 0
+	}
+	return backendForWeb.RobotKey.MaxCallDurationSec
+}
+
+// startCallLimit 通话接通后开始计时，到时前按配置提醒，到时后说结束语并挂断
+func (backendForWeb *BackendForWeb) startCallLimit() {
+	backendForWeb.armCallLimit(0)
+}
+
+// armCallLimit 按当前机器人的配置计时，elapsed为已经通话的时长，切换机器人后按新配置重新计时
+func (backendForWeb *BackendForWeb) armCallLimit(elapsed time.Duration) {
+	limit := backendForWeb.maxCallDuration()
+	if limit <= 0 {
+		return
+	}
+	backendForWeb.callLimitMutex.Lock()
+	defer backendForWeb.callLimitMutex.Unlock()
+	// answer事件每通电话只处理一次
+	if len(backendForWeb.callLimitTimers) > 0 || backendForWeb.callClosing {
+		return
+	}
+	warning := time.Duration(0)
+	if backendForWeb.Robot != nil {
+		warning = time.Duration(backendForWeb.Robot.DurationWarningSec) * time.Second
+	}
+	// 已过提醒时间时不再提醒
+	if warning > 0 && warning < limit && limit-warning > elapsed {
+		backendForWeb.callLimitTimers = append(backendForWeb.callLimitTimers, time.AfterFunc(limit-warning-elapsed, backendForWeb.warnCallLimit))
+	}
+	backendForWeb.callLimitTimers = append(backendForWeb.callLimitTimers, time.AfterFunc(max(limit-elapsed, 0), backendForWeb.solveCallLimit))
+	logrus.WithFields(logrus.Fields{
+		"limit":   limit,
+		"elapsed": elapsed,
+	}).Info("max call duration armed")
+}
+
+// rearmCallLimit 切换机器人后按新的配置和已通话时长重新计时，通话还未接通时等接通后再计时
+func (backendForWeb *BackendForWeb) rearmCallLimit() {
+	record := backendForWeb.callRecordSnapshot()
+	if record == nil || record.AnsweredAt == nil {
+		return
+	}
+	backendForWeb.armCallLimit(time.Since(*record.AnsweredAt))
+}
+
+// warnCallLimit 提醒用户通话即将结束
+func (backendForWeb *BackendForWeb) warnCallLimit() {
+	if backendForWeb.isCallClosing() {
+		return
+	}
+	text := ""
+	if robot := backendForWeb.currentRobot(); robot != nil {
+		text = robot.DurationWarning
+	}
+	if text == "" {
+		text = defaultDurationWarning
+	}
+	logrus.Info("call is about to reach max duration, warn caller")
+	backendForWeb.speakPrompt(text, "duration-warning")
+}
+
+// solveCallLimit 达到最长通话时长，停止正在进行的回复，说结束语后挂断
+func (backendForWeb *BackendForWeb) solveCallLimit() {
+	if !backendForWeb.beginClosing() {
+		return
+	}
+	logrus.Info("call reached max duration, hang up")
+
+	text := ""
+	if robot := backendForWeb.currentRobot(); robot != nil {
+		text = robot.DurationGoodbye
+	}
+	if text == "" {
+		text = defaultDurationGoodbye
+	}
+	playID := backendForWeb.speakPrompt(text, "max-duration")
+	if playID == "" {
+		backendForWeb.hangupWithReason(maxDurationHangupReason)
+		return
+	}
+	backendForWeb.scheduleHangup(playID, maxDurationHangupReason)
+}
+
+// beginClosing 通话进入结束阶段，停止正在进行的回复，不再处理用户发言，也不再追问。
+// 已在结束阶段时返回false
+func (backendForWeb *BackendForWeb) beginClosing() bool {
+	backendForWeb.callLimitMutex.Lock()
+	if backendForWeb.callClosing {
+		backendForWeb.callLimitMutex.Unlock()
+		return false
+	}
+	backendForWeb.callClosing = true
+	backendForWeb.callLimitMutex.Unlock()
+
+	backendForWeb.resetTurns()
+	backendForWeb.endSilence()
+	backendForWeb.stopFiller()
+	if backendForWeb.isPlaying() {
+		backendForWeb.bargeIn()
+	} else {
+		backendForWeb.currentLLM().Interrupt()
+	}
+	return true
+}
+
+// isCallClosing 通话正在结束，如达到最长时长正在说结束语或检测到答录机
+func (backendForWeb *BackendForWeb) isCallClosing() bool {
+	backendForWeb.callLimitMutex.Lock()
+	defer backendForWeb.callLimitMutex.Unlock()
+	return backendForWeb.callClosing
+}
+
+// stopCallLimit 通话结束或新通话开始时停止计时
+func (backendForWeb *BackendForWeb) stopCallLimit() {
+	backendForWeb.callLimitMutex.Lock()
+	defer backendForWeb.callLimitMutex.Unlock()
+	for _, timer := range backendForWeb.callLimitTimers {
+		timer.Stop()
+	}
+	backendForWeb.callLimitTimers = nil
+}
+
+// resetCallLimit 新通话开始时清理计时和结束状态
+func (backendForWeb *BackendForWeb) resetCallLimit() {
+	backendForWeb.stopCallLimit()
+	backendForWeb.callLimitMutex.Lock()
+	defer backendForWeb.callLimitMutex.Unlock()
+	backendForWeb.callClosing = false
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"miniRustpbxgo/internal/dao"
+	"miniRustpbxgo/internal/model"
+	"net/http"
+)
+
+type CallListReq struct {
+	ListReq
+	UserID  uint `form:"user_id" binding:"required"`   // 关联用户ID（必填）
+	RobotID uint `form:"robot_id" binding:"omitempty"` // 机器人ID（可选）
+	// 外呼接听方（可选，human|machine），如筛选答录机接听的号码重新外呼
+	AnsweredBy string `form:"answered_by" binding:"omitempty,oneof=human machine"`
+}
+
+// CallList 分页查询通话记录，name按主被叫号码搜索
+func (app *App) CallList(c *gin.Context) {
+	var req CallListReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		logrus.Error("CallList bind query failed: ", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	callRecordRepo := dao.NewCallRecordRepo(app.DB)
+	result, err := callRecordRepo.ListCallRecords(req.UserID, req.RobotID, req.AnsweredBy, req.toQuery())
+	if err != nil {
+		logrus.Error("ListCallRecords failed: ", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200,
+		"message": "ok",
+		"data": newListRsp(&req.ListReq, result, func(record *model.CallRecord) model.CallRecord {
+			return *record
+		})})
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"miniRustpbxgo/internal/dao"
+	"miniRustpbxgo/internal/model"
+	"time"
+)
+
+// startCallRecord 发起通话时创建通话记录
+func (backendForWeb *BackendForWeb) startCallRecord(direction, caller, callee string) {
+	backendForWeb.recordMutex.Lock()
+	defer backendForWeb.recordMutex.Unlock()
+	backendForWeb.CallRecord = nil
+	if backendForWeb.Robot == nil || backendForWeb.RobotKey == nil {
+		return
+	}
+	record := &model.CallRecord{
+		CallID:     uuid.New().String(),
+		UserID:     backendForWeb.Robot.UserID,
+		RobotID:    backendForWeb.Robot.ID,
+		RobotKeyID: backendForWeb.RobotKey.ID,
+		Direction:  direction,
+		Caller:     caller,
+		Callee:     callee,
+		Status:     model.CallStatusCalling,
+		StartedAt:  time.Now(),
+	}
+	if _, err := dao.NewCallRecordRepo(backendForWeb.DB).CreateCallRecord(record); err != nil {
+		logrus.Errorf("startCallRecord error:%v", err)
+		return
+	}
+	backendForWeb.CallRecord = record
+}
+
+// markCallAnswered 通话接通时更新通话记录
+func (backendForWeb *BackendForWeb) markCallAnswered() {
+	backendForWeb.updateCallRecord(func(record *model.CallRecord) map[string]interface{} {
+		if record.AnsweredAt != nil {
+			return nil
+		}
+		now := time.Now()
+		record.AnsweredAt = &now
+		record.Status = model.CallStatusAnswered
+		return map[string]interface{}{
+			"answered_at": now,
+			"status":      record.Status,
+		}
+	})
+}
+
+// finishCallRecord 通话结束时记录挂断原因和通话时长
+func (backendForWeb *BackendForWeb) finishCallRecord(reason, initiator string) {
+	backendForWeb.updateCallRecord(func(record *model.CallRecord) map[string]interface{} {
+		if record.EndedAt != nil {
+			return nil
+		}
+		now := time.Now()
+		record.EndedAt = &now
+		record.Status = model.CallStatusFailed
+		if record.AnsweredAt != nil {
+			record.Status = model.CallStatusEnded
+			record.Duration = int64(now.Sub(*record.AnsweredAt).Seconds())
+			// 外呼接通后没有检测到答录机，视为真人接听
+			if record.Direction == model.CallDirectionOutbound && record.AnsweredBy == "" {
+				record.AnsweredBy = model.AnsweredByHuman
+			}
+		}
+		// 机器人主动挂断时已记录了原因，rust回传的挂断事件不再覆盖
+		if record.HangupReason == "" {
+			record.HangupReason = reason
+			record.HangupInitiator = initiator
+		}
+		return map[string]interface{}{
+			"ended_at":         now,
+			"status":           record.Status,
+			"duration":         record.Duration,
+			"hangup_reason":    record.HangupReason,
+			"hangup_initiator": record.HangupInitiator,
+			"answered_by":      record.AnsweredBy,
+		}
+	})
+}
+
+// updateCallRecord 修改当前通话记录并写入数据库，change返回要写入的字段，返回空时不写入。
+// 通话记录会被rust事件、轮次、计时器和转接等goroutine修改，修改和写入都在recordMutex内完成，
+// 数据库中的值不会被较早的修改覆盖
+func (backendForWeb *BackendForWeb) updateCallRecord(change func(record *model.CallRecord) map[string]interface{}) {
+	backendForWeb.recordMutex.Lock()
+	defer backendForWeb.recordMutex.Unlock()
+	record := backendForWeb.CallRecord
+	if record == nil {
+		return
+	}
+	updates := change(record)
+	if len(updates) == 0 {
+		return
+	}
+	if err := dao.NewCallRecordRepo(backendForWeb.DB).UpdateCallRecordPartial(record.ID, updates); err != nil {
+		logrus.Errorf("updateCallRecord %s error:%v", record.CallID, err)
+	}
+}
+
+// callRecordSnapshot 返回当前通话记录的副本，没有通话记录时返回nil
+func (backendForWeb *BackendForWeb) callRecordSnapshot() *model.CallRecord {
+	backendForWeb.recordMutex.Lock()
+	defer backendForWeb.recordMutex.Unlock()
+	if backendForWeb.CallRecord == nil {
+		return nil
+	}
+	record := *backendForWeb.CallRecord
+	return &record
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"miniRustpbxgo/internal/model"
+	"net/http"
+	"slices"
+)
+
+// CallTransferReq 坐席管理端发起转人工的请求体
+type CallTransferReq struct {
+	UserID uint   `json:"user_id" binding:"required"` // 通话所属用户ID（必填）
+	CallID string `json:"call_id" binding:"required"` // 通话唯一标识（必填）
+	Target string `json:"target" binding:"omitempty"` // 转接目标（可选，须为机器人配置的转接目标，默认使用转人工配置）
+	Reason string `json:"reason" binding:"omitempty"` // 转接原因（可选）
+}
+
+// TransferCall 将进行中的通话转接到人工坐席
+func (app *App) TransferCall(c *gin.Context) {
+	var req CallTransferReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logrus.Error("TransferCall bind json failed: ", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	backendForWeb, record := app.liveCall(req.CallID)
+	if backendForWeb == nil || record.UserID != req.UserID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "call not found or already ended"})
+		return
+	}
+	// 只能转接到机器人配置的目标，避免通过接口把通话转到任意号码
+	if req.Target != "" && !slices.Contains(backendForWeb.transferTargets(), req.Target) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "target is not a transfer target of this robot"})
+		return
+	}
+	if req.Reason == "" {
+		req.Reason = "supervisor"
+	}
+	handoff, err := backendForWeb.StartTransfer(req.Target, req.Reason)
+	if err != nil {
+		logrus.Error("StartTransfer failed: ", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	backendForWeb.LLMHandler.AddAssistantMessage(handoff)
+	c.JSON(http.StatusOK, gin.H{"code": 200,
+		"message": "ok",
+		"data": gin.H{
+			"call_id": req.CallID,
+			"status":  backendForWeb.callRecordSnapshot().TransferStatus,
+		}})
+}
+
+// liveCall 按通话ID查找进行中的通话及其通话记录快照，包括浏览器通话和SIP外呼、呼入
+func (app *App) liveCall(callID string) (*BackendForWeb, *model.CallRecord) {
+	for _, backendForWeb := range []*BackendForWeb{app.FrontendForWeb, app.sipCalls.get(callID)} {
+		if backendForWeb == nil {
+			continue
+		}
+		record := backendForWeb.callRecordSnapshot()
+		if record != nil && record.CallID == callID && record.EndedAt == nil {
+			return backendForWeb, record
+		}
+	}
+	return nil, nil
+}
+
+// transferTargets 机器人配置的转接目标：转人工配置和按键菜单中的转接目标
+func (backendForWeb *BackendForWeb) transferTargets() []string {
+	robot := backendForWeb.Robot
+	if robot == nil {
+		return nil
+	}
+	var targets []string
+	if robot.Transfer != nil && robot.Transfer.Target != "" {
+		targets = append(targets, robot.Transfer.Target)
+	}
+	if robot.Dtmf != nil {
+		for _, item := range robot.Dtmf.Menu {
+			if item.Action == model.DtmfActionTransfer && item.Target != "" {
+				targets = append(targets, item.Target)
+			}
+		}
+	}
+	return targets
+}
@@ -0,0 +1,265 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"miniRustpbxgo/internal/dao"
+	"miniRustpbxgo/internal/handler"
+	"miniRustpbxgo/internal/model"
+	"strings"
+	"time"
+)
+
+const (
+	// dtmfKeys 电话按键
+	dtmfKeys = "0123456789*#"
+	// defaultDtmfTerminator 收集号码的默认结束键
+	defaultDtmfTerminator = "#"
+	// defaultDtmfTimeout 收集号码时默认等待下一次按键的时间
+	defaultDtmfTimeout = 5 * time.Second
+	// defaultDtmfMaxDigits 收集号码的默认最多位数
+	defaultDtmfMaxDigits = 20
+	// defaultDtmfInvalidText 菜单中没有该按键时的默认话术
+	defaultDtmfInvalidText = "您的按键有误，请重新输入。"
+	// switchRobotTurnWait 切换机器人前等待当前回复退出的最长时间
+	switchRobotTurnWait = 3 * time.Second
+)
+
+// 号码收集的结束方式
+const (
+	digitsStatusTerminator = "terminator" // 按了结束键
+	digitsStatusMaxDigits  = "max_digits" // 达到要求的位数
+	digitsStatusTimeout    = "timeout"    // 等待按键超时
+)
+
+// digitCollector 正在进行的号码收集，按键通过keys传入
+type digitCollector struct {
+	keys chan string
+}
+
+// validateDtmf 校验按键菜单和号码收集配置，转人工菜单未填写目标时机器人需配置转人工
+func validateDtmf(robot *model.Robot) error {
+	dtmf := robot.Dtmf
+	if dtmf == nil {
+		return nil
+	}
+	if dtmf.Terminator != "" && (len(dtmf.Terminator) != 1 || !strings.Contains(dtmfKeys, dtmf.Terminator)) {
+		return fmt.Errorf("dtmf terminator must be one of %s", dtmfKeys)
+	}
+	if dtmf.TimeoutMs < 0 || dtmf.MaxDigits < 0 {
+		return errors.New("dtmf timeout_ms and max_digits must not be negative")
+	}
+	digits := make(map[string]bool, len(dtmf.Menu))
+	for _, item := range dtmf.Menu {
+		if len(item.Digit) != 1 || !strings.Contains(dtmfKeys, item.Digit) {
+			return fmt.Errorf("dtmf menu digit must be one of %s: %q", dtmfKeys, item.Digit)
+		}
+		if digits[item.Digit] {
+			return fmt.Errorf("duplicate dtmf menu digit: %s", item.Digit)
+		}
+		digits[item.Digit] = true
+		switch item.Action {
+		case model.DtmfActionPrompt:
+			if item.Text == "" {
+				return fmt.Errorf("dtmf menu %s: text is required for prompt", item.Digit)
+			}
+		case model.DtmfActionTransfer:
+			if item.Target == "" && (robot.Transfer == nil || robot.Transfer.Target == "") {
+				return fmt.Errorf("dtmf menu %s: target is required for transfer without transfer config", item.Digit)
+			}
+		case model.DtmfActionRobot:
+			if item.RobotID == 0 {
+				return fmt.Errorf("dtmf menu %s: robot_id is required for robot", item.Digit)
+			}
+		default:
+			return fmt.Errorf("dtmf menu %s: unsupported action %s", item.Digit, item.Action)
+		}
+	}
+	return nil
+}
+
+// SolveDtmf 收到用户按键，正在收集号码时交给收集方，否则按按键菜单处理
+func (backendForWeb *BackendForWeb) SolveDtmf(digit string) {
+	if digit == "" {
+		return
+	}
+	backendForWeb.dtmfMutex.Lock()
+	collector := backendForWeb.collector
+	backendForWeb.dtmfMutex.Unlock()
+	if collector != nil {
+		select {
+		case collector.keys <- digit:
+		default:
+			logrus.WithField("digit", digit).Warn("dtmf collector is full, drop digit")
+		}
+		return
+	}
+	if backendForWeb.isTransferActive() || backendForWeb.isCallClosing() {
+		return
+	}
+	var menu []model.RobotDtmfMenuItem
+	invalidText := defaultDtmfInvalidText
+	if robot := backendForWeb.Robot; robot != nil && robot.Dtmf != nil {
+		menu = robot.Dtmf.Menu
+		if robot.Dtmf.InvalidText != "" {
+			invalidText = robot.Dtmf.InvalidText
+		}
+	}
+	if len(menu) == 0 {
+		logrus.WithField("digit", digit).Info("no dtmf menu, ignore digit")
+		return
+	}
+	// 按键和说话一样打断机器人
+	backendForWeb.SolveCallerActivity()
+	if backendForWeb.isPlaying() {
+		backendForWeb.bargeIn()
+	} else if backendForWeb.LLMHandler != nil {
+		backendForWeb.LLMHandler.Interrupt()
+	}
+	for _, item := range menu {
+		if item.Digit == digit {
+			backendForWeb.runDtmfMenu(item)
+			return
+		}
+	}
+	logrus.WithField("digit", digit).Info("dtmf digit not in menu")
+	backendForWeb.speakPrompt(invalidText, "dtmf")
+}
+
+// runDtmfMenu 执行按键菜单项
+func (backendForWeb *BackendForWeb) runDtmfMenu(item model.RobotDtmfMenuItem) {
+	logrus.WithFields(logrus.Fields{
+		"digit":  item.Digit,
+		"action": item.Action,
+	}).Info("run dtmf menu")
+	switch item.Action {
+	case model.DtmfActionPrompt:
+		backendForWeb.speakPrompt(item.Text, "dtmf")
+	case model.DtmfActionTransfer:
+		if item.Text != "" {
+			backendForWeb.speakPrompt(item.Text, "dtmf")
+		}
+		handoff, err := backendForWeb.StartTransfer(item.Target, "dtmf "+item.Digit)
+		if err != nil {
+			logrus.Errorf("dtmf transfer error:%v", err)
+			return
+		}
+		backendForWeb.LLMHandler.AddAssistantMessage(handoff)
+	case model.DtmfActionRobot:
+		if item.Text != "" {
+			backendForWeb.speakPrompt(item.Text, "dtmf")
+		}
+		if err := backendForWeb.switchRobot(item.RobotID); err != nil {
+			logrus.Errorf("dtmf switch robot %d error:%v", item.RobotID, err)
+		}
+	}
+}
+
+// switchRobot 通话中切换到另一个机器人，重新构建大模型并播报其开场白。
+// 识别和合成参数已在invite时确定，切换后沿用
+func (backendForWeb *BackendForWeb) switchRobot(robotID uint) error {
+	current := backendForWeb.Robot
+	robot, err := dao.NewRobotRepo(backendForWeb.DB).GetRobotByID(robotID)
+	if err != nil {
+		return err
+	}
+	if robot == nil || current == nil || robot.UserID != current.UserID {
+		return errors.New("robot not found")
+	}
+	// 当前回复仍在使用旧的大模型，等它退出后再替换
+	if !backendForWeb.stopActiveTurn(switchRobotTurnWait) {
+		return errors.New("current turn did not stop in time")
+	}
+	// 计时器按旧机器人的配置设置，替换前停止（SolveSilence取消等待中的打断），切换后按新机器人的配置重新开始
+	backendForWeb.stopFiller()
+	backendForWeb.stopSilenceTimer()
+	backendForWeb.SolveSilence()
+	backendForWeb.stopCallLimit()
+	asrOption, ttsOption := backendForWeb.AsrOption, backendForWeb.TtsOption
+	if err := backendForWeb.setupRobot(backendForWeb.RobotKey, robot, backendForWeb.callVariables); err != nil {
+		// 切换失败时沿用旧机器人，恢复计时
+		backendForWeb.rearmCallLimit()
+		backendForWeb.armSilenceTimer()
+		return err
+	}
+	backendForWeb.robotMutex.Lock()
+	backendForWeb.AsrOption, backendForWeb.TtsOption = asrOption, ttsOption
+	backendForWeb.robotMutex.Unlock()
+	// 沉默计时在新机器人的开场白播完后开始
+	backendForWeb.rearmCallLimit()
+	backendForWeb.resetTurns()
+	backendForWeb.greetingMutex.Lock()
+	backendForWeb.greeted = false
+	backendForWeb.greetingMutex.Unlock()
+	backendForWeb.SolveCallAnswered()
+	logrus.WithFields(logrus.Fields{
+		"from": current.ID,
+		"to":   robot.ID,
+	}).Info("switched robot by dtmf")
+	return nil
+}
+
+// collectDigits 等待用户按键输入号码，按结束键、达到位数或超时后返回已输入的号码
+func (backendForWeb *BackendForWeb) collectDigits(ctx context.Context, maxDigits int) (handler.DigitsResult, error) {
+	terminator, timeout := defaultDtmfTerminator, defaultDtmfTimeout
+	limit := defaultDtmfMaxDigits
+	if robot := backendForWeb.Robot; robot != nil && robot.Dtmf != nil {
+		if robot.Dtmf.Terminator != "" {
+			terminator = robot.Dtmf.Terminator
+		}
+		if robot.Dtmf.TimeoutMs > 0 {
+			timeout = time.Duration(robot.Dtmf.TimeoutMs) * time.Millisecond
+		}
+		if robot.Dtmf.MaxDigits > 0 {
+			limit = robot.Dtmf.MaxDigits
+		}
+	}
+	if maxDigits <= 0 || maxDigits > limit {
+		maxDigits = limit
+	}
+
+	collector := &digitCollector{keys: make(chan string, defaultDtmfMaxDigits)}
+	backendForWeb.dtmfMutex.Lock()
+	if backendForWeb.collector != nil {
+		backendForWeb.dtmfMutex.Unlock()
+		return handler.DigitsResult{}, errors.New("digits are already being collected")
+	}
+	backendForWeb.collector = collector
+	backendForWeb.dtmfMutex.Unlock()
+	defer func() {
+		backendForWeb.dtmfMutex.Lock()
+		backendForWeb.collector = nil
+		backendForWeb.dtmfMutex.Unlock()
+	}()
+
+	var digits strings.Builder
+	// 用户需要时间听完提示再按键，第一次按键多等待一倍时间
+	timer := time.NewTimer(2 * timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return handler.DigitsResult{Digits: digits.String(), Status: digitsStatusTimeout}, ctx.Err()
+		case <-timer.C:
+			return handler.DigitsResult{Digits: digits.String(), Status: digitsStatusTimeout}, nil
+		case key := <-collector.keys:
+			if key == terminator {
+				return handler.DigitsResult{Digits: digits.String(), Status: digitsStatusTerminator}, nil
+			}
+			digits.WriteString(key)
+			if digits.Len() >= maxDigits {
+				return handler.DigitsResult{Digits: digits.String(), Status: digitsStatusMaxDigits}, nil
+			}
+			timer.Reset(timeout)
+		}
+	}
+}
+
+// resetDtmf 新通话开始时清理号码收集状态
+func (backendForWeb *BackendForWeb) resetDtmf() {
+	backendForWeb.dtmfMutex.Lock()
+	defer backendForWeb.dtmfMutex.Unlock()
+	backendForWeb.collector = nil
+}
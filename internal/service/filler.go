@@ -0,0 +1,114 @@
+package service
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"math/rand"
+	"miniRustpbxgo/internal/handler"
+	"miniRustpbxgo/internal/model"
+	"time"
+)
+
+// defaultFillerDelay 未配置时等待大模型回复多久后播报填充语
+const defaultFillerDelay = 1000 * time.Millisecond
+
+// fillerPlayIDPrefix 填充语的playID前缀，打断填充语时不截断对话历史
+const fillerPlayIDPrefix = "filler-"
+
+// validateFillers 校验每条填充语都有文本或音频
+func validateFillers(fillers []model.RobotFiller) error {
+	for i, filler := range fillers {
+		if filler.Text == "" && filler.AudioURL == "" {
+			return fmt.Errorf("filler %d: text or audio_url is required", i)
+		}
+	}
+	return nil
+}
+
+// armFiller 开始等待大模型回复或工具执行，超时仍没有回复时播报一条填充语
+func (backendForWeb *BackendForWeb) armFiller() {
+	robot := backendForWeb.Robot
+	if robot == nil || len(robot.Fillers) == 0 {
+		return
+	}
+	delay := defaultFillerDelay
+	if robot.FillerDelayMs > 0 {
+		delay = time.Duration(robot.FillerDelayMs) * time.Millisecond
+	}
+	backendForWeb.fillerMutex.Lock()
+	defer backendForWeb.fillerMutex.Unlock()
+	if backendForWeb.fillerTimer != nil {
+		backendForWeb.fillerTimer.Stop()
+	}
+	backendForWeb.fillerTimer = time.AfterFunc(delay, func() {
+		backendForWeb.fillerMutex.Lock()
+		backendForWeb.fillerTimer = nil
+		backendForWeb.fillerMutex.Unlock()
+		backendForWeb.playFiller()
+	})
+}
+
+// stopFiller 回复已开始播报或本轮结束，不再播报填充语
+func (backendForWeb *BackendForWeb) stopFiller() {
+	backendForWeb.fillerMutex.Lock()
+	defer backendForWeb.fillerMutex.Unlock()
+	if backendForWeb.fillerTimer != nil {
+		backendForWeb.fillerTimer.Stop()
+		backendForWeb.fillerTimer = nil
+	}
+}
+
+// SolveToolCall 大模型开始执行工具，工具耗时较长时播报填充语，挂断和等待用户按键时不需要
+func (backendForWeb *BackendForWeb) SolveToolCall(name string) {
+	if name == handler.HangupToolName || name == handler.CollectDigitsToolName {
+		return
+	}
+	backendForWeb.armFiller()
+}
+
+// playFiller 随机播报一条填充语。填充语不属于大模型回复，不写入对话历史，
+// 被打断时也不按播放位置截断历史，用户说话时随大模型生成一起被打断
+func (backendForWeb *BackendForWeb) playFiller() {
+	robot := backendForWeb.currentRobot()
+	if robot == nil || len(robot.Fillers) == 0 {
+		return
+	}
+	fillers := robot.Fillers
+	backendForWeb.fillerMutex.Lock()
+	index := rand.Intn(len(fillers))
+	if len(fillers) > 1 && index == backendForWeb.lastFiller {
+		index = (index + 1) % len(fillers)
+	}
+	backendForWeb.lastFiller = index
+	backendForWeb.fillerMutex.Unlock()
+
+	filler := fillers[index]
+	var err error
+	if filler.AudioURL != "" {
+		err = backendForWeb.SendPlayCommandForRustBackend(filler.AudioURL, false)
+	} else {
+		text := filler.Text
+		if normalizer := backendForWeb.currentNormalizer(); normalizer != nil {
+			text = normalizer.Normalize(text)
+		}
+		playID := fillerPlayIDPrefix + uuid.New().String()
+		err = backendForWeb.sendCommandToRust(&TtsCommand{
+			Command:     "tts",
+			Text:        text,
+			PlayID:      playID,
+			EndOfStream: true,
+		})
+		if err == nil {
+			backendForWeb.trackPlaybackQueued(playID, text, false)
+		}
+	}
+	if err != nil {
+		logrus.Errorf("play filler error:%v", err)
+		return
+	}
+	logrus.WithFields(logrus.Fields{
+		"text":  filler.Text,
+		"audio": filler.AudioURL,
+	}).Info("LLM is slow, played filler")
+}
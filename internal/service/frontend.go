@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 	"github.com/sirupsen/logrus"
@@ -11,7 +12,13 @@ import (
 	"miniRustpbxgo/internal/dao"
 	"miniRustpbxgo/internal/handler"
 	"miniRustpbxgo/internal/model"
+	"miniRustpbxgo/internal/normalize"
+	"miniRustpbxgo/internal/prompt"
+	"miniRustpbxgo/internal/provider"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 )
 
 type BackendForWeb struct {
@@ -23,6 +30,63 @@ type BackendForWeb struct {
 	LLMHandler   *handler.LLMHandler
 	DB           *gorm.DB
 	Model        string
+	Robot        *model.Robot
+	RobotKey     *model.RobotKey
+	CallRecord   *model.CallRecord     // 当前通话的通话记录，修改需通过updateCallRecord
+	Normalizer   *normalize.Normalizer // 大模型回复送去合成前转换为朗读文本
+
+	callVariables map[string]string // 调用方传入的模板变量，切换机器人时重新渲染提示词
+	callerID      string            // SIP呼入的主叫号码，模板中用{{.caller_id}}引用
+	onCallEnded   func()            // SIP通话结束后断开与rust的连接，浏览器通话为空
+
+	recordMutex sync.Mutex // 保护CallRecord的修改及其写入数据库
+
+	// robotMutex 保护按键切换机器人时替换的Robot、RobotKey、LLMHandler、Normalizer和Model，
+	// 计时器等rust事件以外的goroutine通过currentRobot等方法读取
+	robotMutex sync.RWMutex
+
+	rustWriteMutex sync.Mutex // websocket不支持并发写，所有发往rust的消息需加锁
+	greetingMutex  sync.Mutex
+	greeted        bool   // 本通电话是否已播报开场白
+	greetingText   string // 渲染变量后的开场白文本
+	hangupMutex    sync.Mutex
+	hangupReason   string // 大模型调用hangup工具给出的原因
+	lastTTSPlayID  string // 最近一次发送语音合成的playID
+
+	playbackWaitMutex sync.Mutex
+	playbackWaits     []*playbackWait // 等待播放结束后执行的动作，如挂断
+
+	transferMutex sync.Mutex
+	transfer      *transferState // 当前通话的转人工状态
+
+	bargeInMutex      sync.Mutex
+	bargeInTimer      *time.Timer // 用户说话达到最短时长后触发打断
+	interruptedPlayID string      // 被打断的playID，其后续分段不再发送
+	awaitingPosition  bool        // 已发送interrupt命令，等待rust回传播放位置
+
+	turns turnAssembler // 合并连续的识别结果为一轮用户发言
+
+	fillerMutex sync.Mutex
+	fillerTimer *time.Timer // 等待大模型回复超时后播报填充语
+	lastFiller  int         // 上一次播报的填充语下标，避免连续重复
+
+	silenceMutex   sync.Mutex
+	silenceTimer   *time.Timer // 机器人说完后用户沉默超时追问
+	reprompts      int         // 用户说话后已连续追问的次数
+	silenceStopped bool        // 已说告别语或通话已结束，不再计时
+
+	callLimitMutex  sync.Mutex
+	callLimitTimers []*time.Timer // 最长通话时长的提醒和挂断计时
+	callClosing     bool          // 通话正在结束（达到最长时长或检测到答录机），不再处理用户发言
+
+	playbacks playbackTracker // 按playID跟踪语音的排队、播放和结束
+
+	dtmfMutex sync.Mutex
+	collector *digitCollector // 正在收集号码时接收按键
+
+	amd amdState // 外呼的答录机检测结果和留言状态
+
+	webWriteMutex sync.Mutex // 发往前端的消息可能来自多个goroutine，SIP通话订阅事件时也用于保护WebToGoConn
 }
 
 type TtsCommand struct {
@@ -40,6 +104,8 @@ type WebRTCSetUpReq struct {
 	ApiKey    string `json:"api_key" binding:"required"`
 	ApiSecret string `json:"api_secret" binding:"required"`
 	RobotId   int64  `json:"robot_id" binding:"required"`
+	// 调用方自定义变量，用于渲染系统提示词和开场白模板
+	Variables map[string]string `json:"variables" binding:"omitempty"`
 }
 
 func NewBackendForWeb(asrOption *model.ASROption, ttsOption *model.TTSOption, llmHandler *handler.LLMHandler, model string) *BackendForWeb {
@@ -119,6 +185,7 @@ func (backendForWeb *BackendForWeb) GoSendMessageToRust(done chan bool) {
 			Candidate json.RawMessage `json:"candidate"`
 			Reason    string          `json:"reason"`
 			Initiator string          `json:"initiator"`
+			Digit     string          `json:"digit"`
 		}
 		for {
 			_, msg, err := webToConn.ReadMessage()
@@ -138,6 +205,9 @@ func (backendForWeb *BackendForWeb) GoSendMessageToRust(done chan bool) {
 				backendForWeb.SolveOffer(frontendToGoEvent.Sdp)
 			} else if frontendToGoEvent.Event == "hangup" {
 				backendForWeb.SolveHangup(frontendToGoEvent.Reason)
+			} else if frontendToGoEvent.Event == "dtmf" {
+				// 网页拨号盘的按键
+				backendForWeb.SolveDtmf(frontendToGoEvent.Digit)
 			}
 		}
 	}()
@@ -148,9 +218,7 @@ func (backendForWeb *BackendForWeb) SolveCandidate(rawMessage json.RawMessage) {
 		logrus.Error("Backend to goBackend not ready")
 		return
 	}
-	logrus.Info("Received ICE candidate: %s", string(rawMessage))
-
-	goToRustConn := backendForWeb.GoToRustConn
+	logrus.Infof("Received ICE candidate: %s", string(rawMessage))
 
 	var candidate struct {
 		Candidate     string `json:"candidate"`
@@ -173,7 +241,7 @@ func (backendForWeb *BackendForWeb) SolveCandidate(rawMessage json.RawMessage) {
 		logrus.Info("marshal candidate command failed:", err)
 		return
 	}
-	if err := goToRustConn.WriteMessage(websocket.TextMessage, cmdBytes); err != nil {
+	if err := backendForWeb.writeToRust(cmdBytes); err != nil {
 		logrus.Info("forward candidate command to rust backend err:", err)
 	}
 }
@@ -184,7 +252,7 @@ func (backendForWeb *BackendForWeb) SolveHangup(reason string) {
 		Reason:  reason,
 	}
 	logrus.Println("hangup command:", hangupCommand)
-	if err := backendForWeb.GoToRustConn.WriteJSON(hangupCommand); err != nil {
+	if err := backendForWeb.sendCommandToRust(hangupCommand); err != nil {
 		log.Println("forward hangup command to rust backend err:", err)
 		return
 	}
@@ -196,7 +264,8 @@ func (backendForWeb *BackendForWeb) SolveOffer(sdp string) {
 		return
 	}
 	log.Printf("Received ICE offer: %s", sdp)
-	goToRustConn := backendForWeb.GoToRustConn
+	backendForWeb.resetCall()
+	backendForWeb.startCallRecord(model.CallDirectionWebRTC, "frontend", "rust")
 	inviteCmd := model.InviteCommand{
 		Command: "invite",
 		Option: model.CallOption{
@@ -205,6 +274,7 @@ func (backendForWeb *BackendForWeb) SolveOffer(sdp string) {
 			Callee: "rust",
 			ASR:    backendForWeb.AsrOption,
 			TTS:    backendForWeb.TtsOption,
+			Eou:    buildEouOption(backendForWeb.RobotKey, backendForWeb.Robot),
 		},
 	}
 	cmdBytes, err := json.Marshal(inviteCmd)
@@ -212,15 +282,37 @@ func (backendForWeb *BackendForWeb) SolveOffer(sdp string) {
 		log.Println("marshal invite command failed:", err)
 		return
 	}
-	if err := goToRustConn.WriteMessage(websocket.TextMessage, cmdBytes); err != nil {
+	if err := backendForWeb.writeToRust(cmdBytes); err != nil {
 		log.Println("forward invite command to rust backend err:", err)
 	}
 }
 
+// resetCall 新的通话开始前清理上一通电话的状态，重新播报开场白
+func (backendForWeb *BackendForWeb) resetCall() {
+	backendForWeb.greetingMutex.Lock()
+	backendForWeb.greeted = false
+	backendForWeb.greetingMutex.Unlock()
+	backendForWeb.resetHangup()
+	backendForWeb.resetPlaybackWaits()
+	backendForWeb.resetTransfer()
+	backendForWeb.resetBargeIn()
+	backendForWeb.resetTurns()
+	backendForWeb.resetSilence()
+	backendForWeb.resetCallLimit()
+	backendForWeb.resetPlaybacks()
+	backendForWeb.resetDtmf()
+	backendForWeb.resetAmd()
+}
+
 func (backendForWeb *BackendForWeb) ForwardToWebConn(event *Event) {
+	backendForWeb.webWriteMutex.Lock()
+	defer backendForWeb.webWriteMutex.Unlock()
 	conn := backendForWeb.WebToGoConn
 	if conn == nil {
-		logrus.Error("goBackend to rustBackend not connected")
+		// SIP通话没有订阅事件时不需要转发
+		if backendForWeb.onCallEnded == nil {
+			logrus.Error("goBackend to rustBackend not connected")
+		}
 		return
 	}
 	marshal, err := json.Marshal(event)
@@ -234,36 +326,62 @@ func (backendForWeb *BackendForWeb) ForwardToWebConn(event *Event) {
 	}
 }
 
-func (backendForWeb *BackendForWeb) SolveAsrFinalEvent(event *Event) {
-	if event.Text == "" {
-		return
+// sendTTSSegment 大模型流式输出的分段回调，将分段发送给rust合成
+// autoHangup时不交给rust自动挂断，而是在播放结束后发送带原因的挂断命令
+// 机器人开启流式合成时，一轮回复的所有分段属于同一个流式播放，endOfStream时结束该播放
+func (backendForWeb *BackendForWeb) sendTTSSegment(segment string, playID string, autoHangup, endOfStream bool) error {
+	// 浏览器和对话历史保留原文，只有送去合成的文本转换为读法，只剩空白时不再合成。
+	// 流式合成的分段由分段器在词边界切出，未闭合的链接和像行首标记的文本会留到下一段，逐段转换与整段转换读法一致
+	source := segment
+	if backendForWeb.Normalizer != nil {
+		segment = backendForWeb.Normalizer.Normalize(segment)
+		if strings.TrimSpace(segment) == "" {
+			segment = ""
+		}
 	}
-	var rep Event
-	response, err := backendForWeb.LLMHandler.QueryStream(backendForWeb.Model, event.Text, func(segment string, playID string, autoHangup bool) error {
-		if len(segment) == 0 {
+	if autoHangup {
+		reason := backendForWeb.takeHangupReason()
+		if len(segment) == 0 && backendForWeb.lastSentPlayID() != playID {
+			// 本轮没有任何语音，直接挂断
+			backendForWeb.hangupWithReason(reason)
 			return nil
 		}
-		logrus.WithFields(logrus.Fields{
-			"segment":    segment,
-			"playID":     playID,
-			"autoHangup": autoHangup,
-		}).Info("Sending TTS segment")
-		return backendForWeb.SendTTSCommandForRustBackend(segment, playID, autoHangup, nil)
-	})
-	if err != nil {
-		logrus.Error("SolveAsrFinalEvent response error:", err)
-		return
+		// 本轮语音播完后挂断
+		defer backendForWeb.scheduleHangup(playID, reason)
 	}
-	rep.Text = response
-	rep.Event = "LLMResult"
-	repStr, err := json.Marshal(rep)
-	if err != nil {
-		logrus.Println("SolveAsrFinalEvent json.Marshal error:", err)
-		return
+	streaming := backendForWeb.Robot != nil && backendForWeb.Robot.StreamingTTS
+	if len(segment) == 0 {
+		// 流式播放需要一个空的结束分段，本轮没有发出过语音时不需要
+		if !streaming || !endOfStream || backendForWeb.lastSentPlayID() != playID {
+			return nil
+		}
 	}
-	if err := backendForWeb.WebToGoConn.WriteMessage(websocket.TextMessage, repStr); err != nil {
-		logrus.Println("SolveAsrFinalEvent response the LLS Message error: ", err)
+	if backendForWeb.isInterrupted(playID) {
+		logrus.WithField("playID", playID).Info("drop TTS segment after barge-in")
+		return nil
+	}
+	logrus.WithFields(logrus.Fields{
+		"segment":     segment,
+		"playID":      playID,
+		"autoHangup":  autoHangup,
+		"streaming":   streaming,
+		"endOfStream": endOfStream,
+	}).Info("Sending TTS segment")
+	backendForWeb.hangupMutex.Lock()
+	backendForWeb.lastTTSPlayID = playID
+	backendForWeb.hangupMutex.Unlock()
+	var err error
+	if streaming {
+		err = backendForWeb.SendStreamingTTSCommandForRustBackend(segment, playID, endOfStream)
+	} else {
+		err = backendForWeb.SendTTSCommandForRustBackend(segment, playID, false, nil)
 	}
+	if err != nil {
+		return err
+	}
+	// 打断时按原文截断大模型历史
+	backendForWeb.trackPlaybackSource(playID, source)
+	return nil
 }
 
 func (backendForWeb *BackendForWeb) SendTTSCommandForRustBackend(text string, playId string, autoHangup bool, option *model.TTSOption) error {
@@ -278,7 +396,59 @@ func (backendForWeb *BackendForWeb) SendTTSCommandForRustBackend(text string, pl
 		Option:      option,
 	}
 	logrus.Println("send ttsCommand to rust backend", ttsCommand)
-	return backendForWeb.GoToRustConn.WriteJSON(ttsCommand)
+	if err := backendForWeb.sendCommandToRust(ttsCommand); err != nil {
+		return err
+	}
+	backendForWeb.trackPlaybackQueued(playId, text, false)
+	return nil
+}
+
+// SendStreamingTTSCommandForRustBackend 向rust的流式合成追加文本，同一playID的文本合成为一段连续语音，
+// endOfStream表示该playID的文本已发送完毕
+func (backendForWeb *BackendForWeb) SendStreamingTTSCommandForRustBackend(text string, playId string, endOfStream bool) error {
+	ttsCommand := &TtsCommand{
+		Command:     "tts",
+		Text:        text,
+		PlayID:      playId,
+		Streaming:   true,
+		EndOfStream: endOfStream,
+	}
+	if err := backendForWeb.sendCommandToRust(ttsCommand); err != nil {
+		return err
+	}
+	backendForWeb.trackPlaybackQueued(playId, text, true)
+	return nil
+}
+
+// SendPlayCommandForRustBackend 让rust播放指定地址的音频
+func (backendForWeb *BackendForWeb) SendPlayCommandForRustBackend(url string, autoHangup bool) error {
+	playCommand := &model.PlayCommand{
+		Command:    "play",
+		URL:        url,
+		AutoHangup: autoHangup,
+	}
+	logrus.Println("send playCommand to rust backend", playCommand)
+	return backendForWeb.sendCommandToRust(playCommand)
+}
+
+// sendCommandToRust 序列化命令并发送给rust
+func (backendForWeb *BackendForWeb) sendCommandToRust(cmd any) error {
+	cmdBytes, err := json.Marshal(cmd)
+	if err != nil {
+		return err
+	}
+	return backendForWeb.writeToRust(cmdBytes)
+}
+
+// writeToRust 串行写入go到rust的ws连接
+func (backendForWeb *BackendForWeb) writeToRust(msg []byte) error {
+	backendForWeb.rustWriteMutex.Lock()
+	defer backendForWeb.rustWriteMutex.Unlock()
+	conn := backendForWeb.GoToRustConn
+	if conn == nil {
+		return errors.New("goBackend to rustBackend not connected")
+	}
+	return conn.WriteMessage(websocket.TextMessage, msg)
 }
 
 func (backendForWeb *BackendForWeb) FrontendInit(ctx *gin.Context) {
@@ -312,33 +482,86 @@ func (backendForWeb *BackendForWeb) FrontendInit(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "robot not found"})
 		return
 	}
-	asrOption := &model.ASROption{
-		Provider:  "tencent",
-		AppID:     key.ASRAppID,
-		SecretID:  key.ASRSecretID,
-		SecretKey: key.ASRSecretKey,
-		ModelType: key.ASRLanguage,
-		Endpoint:  key.ASRProvider,
-	}
-	ttsOption := &model.TTSOption{
-		Provider:  "tencent",
-		Speaker:   robot.Speaker,
-		AppID:     key.TTSAppID,
-		SecretID:  key.TTSSecretID,
-		SecretKey: key.TTSSecretKey,
-		Endpoint:  key.TTSProvider,
-		Speed:     robot.Speed,
-		Volume:    int32(robot.Volume),
-		Emotion:   robot.Emotion,
+	if err := backendForWeb.setupRobot(key, robot, webRTCSetUpReq.Variables); err != nil {
+		logrus.Errorf("FrontendInit setupRobot error:%v", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200,
+		"message": "初始化成功",
+	})
+}
+
+// setupRobot 按密钥和机器人配置构建本通电话的识别、合成参数和大模型，variables用于渲染提示词模板
+func (backendForWeb *BackendForWeb) setupRobot(key *model.RobotKey, robot *model.Robot, variables map[string]string) error {
+	// 按密钥上配置的服务商构建ASR/TTS参数，机器人的发音人不属于该服务商时无法通话
+	asrOption, ttsOption, err := provider.BuildOptions(key, robot)
+	if err != nil {
+		return err
+	}
+	// 渲染系统提示词和开场白中的变量
+	systemPrompt, greeting, err := prompt.RenderRobot(robot, prompt.CallInfo{
+		Variables: variables,
+		CallerID:  backendForWeb.callerID,
+		Now:       time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	tools, err := backendForWeb.buildToolRegistry(robot)
+	if err != nil {
+		return err
 	}
+	// 密钥上配置的大模型为主节点，失败时切换到机器人的备用节点
 	logger := logrus.New()
+	llmProvider, err := buildLLMProvider(key, robot, logger)
+	if err != nil {
+		return err
+	}
 	c := context.Background()
-	llmHandler := handler.NewLLMHandler(c, key.LLMApiKey, key.LLMApiUrl, robot.SystemPrompt, logger)
+	llmHandler := handler.NewLLMHandlerWithProvider(c, llmProvider, systemPrompt, logger)
+	llmHandler.SetTools(tools)
+	llmHandler.SetStreamingTTS(robot.StreamingTTS)
+	llmHandler.SetToolCallListener(backendForWeb.SolveToolCall)
+	llmHandler.SetSegmenterConfig(handler.SegmenterConfig{
+		MinRunes: robot.SegmentMinRunes,
+		MaxRunes: robot.SegmentMaxRunes,
+	})
+	llmHandler.SetContextPolicy(handler.ContextPolicy{
+		MaxTokens: robot.ContextMaxTokens,
+		Strategy:  robot.ContextStrategy,
+	})
+	backendForWeb.robotMutex.Lock()
 	backendForWeb.LLMHandler = llmHandler
 	backendForWeb.AsrOption = asrOption
 	backendForWeb.TtsOption = ttsOption
-	backendForWeb.Model = "qwen-turbo"
-	ctx.JSON(http.StatusOK, gin.H{"code": 200,
-		"message": "初始化成功",
-	})
+	backendForWeb.Robot = robot
+	backendForWeb.RobotKey = key
+	backendForWeb.Normalizer = normalize.New(key.ASRLanguage, robot.Lexicon)
+	backendForWeb.greetingText = greeting
+	backendForWeb.Model = llmProvider.DefaultModel()
+	backendForWeb.callVariables = variables
+	backendForWeb.robotMutex.Unlock()
+	return nil
+}
+
+// currentRobot 当前的机器人，供计时器等可能与切换机器人并发的goroutine读取
+func (backendForWeb *BackendForWeb) currentRobot() *model.Robot {
+	backendForWeb.robotMutex.RLock()
+	defer backendForWeb.robotMutex.RUnlock()
+	return backendForWeb.Robot
+}
+
+// currentLLM 当前机器人的大模型，供计时器等可能与切换机器人并发的goroutine读取
+func (backendForWeb *BackendForWeb) currentLLM() *handler.LLMHandler {
+	backendForWeb.robotMutex.RLock()
+	defer backendForWeb.robotMutex.RUnlock()
+	return backendForWeb.LLMHandler
+}
+
+// currentNormalizer 当前机器人的朗读文本转换器，供计时器等可能与切换机器人并发的goroutine读取
+func (backendForWeb *BackendForWeb) currentNormalizer() *normalize.Normalizer {
+	backendForWeb.robotMutex.RLock()
+	defer backendForWeb.robotMutex.RUnlock()
+	return backendForWeb.Normalizer
 }
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"miniRustpbxgo/internal/model"
+)
+
+// validateGreeting 校验开场白配置是否完整
+func validateGreeting(robot *model.Robot) error {
+	switch robot.GreetingType {
+	case "":
+		return nil
+	case model.GreetingTypeText, model.GreetingTypeLLM:
+		if robot.Greeting == "" {
+			return fmt.Errorf("greeting is required when greeting_type is %s", robot.GreetingType)
+		}
+	case model.GreetingTypeAudio:
+		if robot.GreetingURL == "" {
+			return errors.New("greeting_url is required when greeting_type is audio")
+		}
+	default:
+		return fmt.Errorf("unsupported greeting_type: %s", robot.GreetingType)
+	}
+	return nil
+}
+
+// SolveCallAnswered rust端应答或开始放音时触发，每通电话只播报一次开场白
+func (backendForWeb *BackendForWeb) SolveCallAnswered() {
+	backendForWeb.greetingMutex.Lock()
+	if backendForWeb.greeted {
+		backendForWeb.greetingMutex.Unlock()
+		return
+	}
+	backendForWeb.greeted = true
+	backendForWeb.greetingMutex.Unlock()
+
+	robot := backendForWeb.Robot
+	if robot == nil || robot.GreetingType == "" {
+		// 没有开场白时接通后即等待用户说话
+		backendForWeb.armSilenceTimer()
+		return
+	}
+	// 大模型生成开场白耗时较长，不能阻塞rust事件监听
+	go backendForWeb.sendGreeting(robot, backendForWeb.greetingText)
+}
+
+// sendGreeting 播报开场白，并作为助手的第一轮发言写入大模型历史
+func (backendForWeb *BackendForWeb) sendGreeting(robot *model.Robot, text string) {
+	var (
+		greeting string
+		err      error
+	)
+	switch robot.GreetingType {
+	case model.GreetingTypeText:
+		greeting = text
+		playID := fmt.Sprintf("greeting-%s", uuid.New().String())
+		err = backendForWeb.SendTTSCommandForRustBackend(greeting, playID, false, nil)
+	case model.GreetingTypeAudio:
+		// 音频开场白的文字内容可选，用于让大模型知道已经说过什么
+		greeting = text
+		err = backendForWeb.SendPlayCommandForRustBackend(robot.GreetingURL, false)
+	case model.GreetingTypeLLM:
+		greeting, err = backendForWeb.LLMHandler.GenerateOpening(backendForWeb.Model, text, backendForWeb.sendTTSSegment)
+	}
+	if err != nil {
+		logrus.Errorf("sendGreeting robot %d type %s error:%v", robot.ID, robot.GreetingType, err)
+		return
+	}
+	if greeting == "" {
+		return
+	}
+	// llm类型在生成时已写入历史
+	if robot.GreetingType != model.GreetingTypeLLM {
+		backendForWeb.LLMHandler.AddAssistantMessage(greeting)
+	}
+	backendForWeb.ForwardToWebConn(&Event{
+		Event: "greeting",
+		Text:  greeting,
+	})
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"github.com/sirupsen/logrus"
+	"miniRustpbxgo/internal/model"
+	"time"
+)
+
+const (
+	// defaultLLMHangupReason 大模型未给出挂断原因时使用
+	defaultLLMHangupReason = "llm_hangup"
+	// hangupPlaybackTimeout 等待告别语播放结束的最长时间，超时后直接挂断
+	hangupPlaybackTimeout = 30 * time.Second
+	// robotHangupInitiator 机器人主动挂断（大模型、静音、时长上限、留言机等）
+	robotHangupInitiator = "robot"
+	// apiHangupInitiator 业务方通过CallEvents接口挂断
+	apiHangupInitiator = "api"
+)
+
+// requestHangup 大模型调用hangup工具时记录挂断原因，在本轮最后一段语音发出后挂断
+func (backendForWeb *BackendForWeb) requestHangup(reason string) {
+	if reason == "" {
+		reason = defaultLLMHangupReason
+	}
+	logrus.WithField("reason", reason).Info("LLM requested hangup")
+	backendForWeb.hangupMutex.Lock()
+	defer backendForWeb.hangupMutex.Unlock()
+	backendForWeb.hangupReason = reason
+}
+
+// takeHangupReason 取出大模型给出的挂断原因
+func (backendForWeb *BackendForWeb) takeHangupReason() string {
+	backendForWeb.hangupMutex.Lock()
+	defer backendForWeb.hangupMutex.Unlock()
+	reason := backendForWeb.hangupReason
+	backendForWeb.hangupReason = ""
+	if reason == "" {
+		reason = defaultLLMHangupReason
+	}
+	return reason
+}
+
+// lastSentPlayID 最近一次发送语音合成的playID
+func (backendForWeb *BackendForWeb) lastSentPlayID() string {
+	backendForWeb.hangupMutex.Lock()
+	defer backendForWeb.hangupMutex.Unlock()
+	return backendForWeb.lastTTSPlayID
+}
+
+// scheduleHangup 在playID对应的语音播放结束后发送挂断命令
+func (backendForWeb *BackendForWeb) scheduleHangup(playID, reason string) {
+	backendForWeb.runAfterPlayback(playID, hangupPlaybackTimeout, func() {
+		backendForWeb.hangupWithReason(reason)
+	})
+}
+
+// hangupWithReason 机器人主动挂断，原因写入挂断命令和通话记录
+func (backendForWeb *BackendForWeb) hangupWithReason(reason string) {
+	backendForWeb.hangupBy(reason, robotHangupInitiator)
+}
+
+// hangupBy 以指定的发起方挂断，原因和发起方写入挂断命令和通话记录
+func (backendForWeb *BackendForWeb) hangupBy(reason, initiator string) {
+	backendForWeb.recordHangupReason(reason, initiator)
+	backendForWeb.SolveHangup(reason)
+}
+
+// recordHangupReason 记录主动挂断的原因和发起方，rust回传的挂断事件不再覆盖
+func (backendForWeb *BackendForWeb) recordHangupReason(reason, initiator string) {
+	backendForWeb.updateCallRecord(func(record *model.CallRecord) map[string]interface{} {
+		if record.HangupReason != "" {
+			return nil
+		}
+		record.HangupReason = reason
+		record.HangupInitiator = initiator
+		return map[string]interface{}{
+			"hangup_reason":    record.HangupReason,
+			"hangup_initiator": record.HangupInitiator,
+		}
+	})
+}
+
+// resetHangup 新通话开始时清理上一通电话遗留的挂断状态
+func (backendForWeb *BackendForWeb) resetHangup() {
+	backendForWeb.hangupMutex.Lock()
+	defer backendForWeb.hangupMutex.Unlock()
+	backendForWeb.hangupReason = ""
+	backendForWeb.lastTTSPlayID = ""
+}
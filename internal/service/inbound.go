@@ -0,0 +1,236 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+	"miniRustpbxgo/internal/dao"
+	"miniRustpbxgo/internal/model"
+	"strings"
+	"time"
+)
+
+// inboundRetryInterval 等待呼入的rust连接失败后的重试间隔
+const inboundRetryInterval = 3 * time.Second
+
+// 拒绝呼入时返回的SIP状态码
+const (
+	rejectCodeForbidden   = 403 // 主叫被黑名单拒绝或不在白名单中
+	rejectCodeNotFound    = 404 // 被叫号码未登记
+	rejectCodeUnavailable = 480 // 号码已停用
+	rejectCodeServerError = 500 // 号码配置的机器人或密钥不可用
+)
+
+// inboundRejection 拒绝呼入的原因和状态码
+type inboundRejection struct {
+	reason string
+	code   uint32
+}
+
+func (rejection *inboundRejection) Error() string {
+	return rejection.reason
+}
+
+// ListenInbound 保持一条等待呼入的rust连接，收到incoming事件后该连接交给这通电话，再建立新的等待连接
+func (app *App) ListenInbound() {
+	for {
+		backendForRust := NewBackendForRust(endPoint)
+		conn, err := backendForRust.Dial(sipCallType)
+		if err != nil {
+			logrus.Error("inbound listener connect rustBackend error: ", err)
+			time.Sleep(inboundRetryInterval)
+			continue
+		}
+		incoming, err := waitIncoming(conn)
+		if err != nil {
+			logrus.Error("inbound listener read error: ", err)
+			_ = conn.Close()
+			time.Sleep(inboundRetryInterval)
+			continue
+		}
+		go app.SolveIncoming(backendForRust, incoming)
+	}
+}
+
+// waitIncoming 读取rust连接上的事件直到收到呼入
+func waitIncoming(conn *websocket.Conn) (*model.IncomingEvent, error) {
+	for {
+		msgType, msg, err := conn.ReadMessage()
+		if err != nil {
+			return nil, err
+		}
+		if msgType != websocket.TextMessage {
+			continue
+		}
+		var event struct {
+			Event string `json:"event"`
+			model.IncomingEvent
+		}
+		if err := json.Unmarshal(msg, &event); err != nil {
+			logrus.Error("waitIncoming json.Unmarshal error: ", err)
+			continue
+		}
+		if event.Event == "incoming" {
+			return &event.IncomingEvent, nil
+		}
+		logrus.Infof("inbound listener ignore event %s", event.Event)
+	}
+}
+
+// SolveIncoming 按被叫号码查找接听的机器人，校验主叫的黑白名单后接听或拒绝
+func (app *App) SolveIncoming(backendForRust *BackendForRust, incoming *model.IncomingEvent) {
+	conn := backendForRust.GoToRustConn
+	fields := logrus.Fields{
+		"caller": incoming.Caller,
+		"callee": incoming.Callee,
+	}
+	phoneNumber, key, robot, err := app.routeInbound(incoming)
+	if err != nil {
+		rejection := &inboundRejection{reason: "service unavailable", code: rejectCodeServerError}
+		if !errors.As(err, &rejection) {
+			logrus.WithFields(fields).Errorf("route incoming call error:%v", err)
+		}
+		logrus.WithFields(fields).Warnf("reject incoming call: %s", rejection.reason)
+		rejectCmd := model.RejectCommand{
+			Command: "reject",
+			Reason:  rejection.reason,
+			Code:    rejection.code,
+		}
+		if err := sendRejectToRust(conn, rejectCmd); err != nil {
+			logrus.Error("send reject command error: ", err)
+		}
+		_ = conn.Close()
+		return
+	}
+
+	backendForWeb := NewBackendForWebByNoParam(app.DB)
+	backendForWeb.callerID = incoming.Caller
+	if err := backendForWeb.setupRobot(key, robot, nil); err != nil {
+		logrus.WithFields(fields).Errorf("incoming call setupRobot error:%v", err)
+		_ = sendRejectToRust(conn, model.RejectCommand{Command: "reject", Reason: "robot unavailable", Code: rejectCodeServerError})
+		_ = conn.Close()
+		return
+	}
+	backendForWeb.resetCall()
+	backendForWeb.startCallRecord(model.CallDirectionInbound, incoming.Caller, incoming.Callee)
+	record := backendForWeb.callRecordSnapshot()
+	if record == nil {
+		_ = sendRejectToRust(conn, model.RejectCommand{Command: "reject", Reason: "create call record failed", Code: rejectCodeServerError})
+		_ = conn.Close()
+		return
+	}
+	app.runSipCall(backendForRust, backendForWeb)
+
+	acceptCmd := model.AcceptCommand{
+		Command: "accept",
+		Option: model.CallOption{
+			Caller: incoming.Caller,
+			Callee: incoming.Callee,
+			ASR:    backendForWeb.AsrOption,
+			TTS:    backendForWeb.TtsOption,
+			Eou:    buildEouOption(key, robot),
+		},
+	}
+	if err := backendForWeb.sendCommandToRust(acceptCmd); err != nil {
+		logrus.WithFields(fields).Errorf("send accept command error:%v", err)
+		backendForWeb.SolveCallFailed("accept error")
+		return
+	}
+	fields["callID"] = record.CallID
+	fields["number"] = phoneNumber.Number
+	fields["robot"] = robot.ID
+	logrus.WithFields(fields).Info("incoming call accepted")
+}
+
+// routeInbound 查找被叫号码对应的机器人和密钥，号码未登记、已停用或主叫被拒绝时返回inboundRejection
+func (app *App) routeInbound(incoming *model.IncomingEvent) (*model.PhoneNumber, *model.RobotKey, *model.Robot, error) {
+	phoneNumber, err := dao.NewPhoneNumberRepo(app.DB).FindPhoneNumber(numberCandidates(incoming.Callee))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if phoneNumber == nil {
+		return nil, nil, nil, &inboundRejection{reason: "number not found", code: rejectCodeNotFound}
+	}
+	if !phoneNumber.Enabled {
+		return nil, nil, nil, &inboundRejection{reason: "number disabled", code: rejectCodeUnavailable}
+	}
+	callers := numberCandidates(incoming.Caller)
+	if matchCaller(phoneNumber.BlockList, callers) {
+		return nil, nil, nil, &inboundRejection{reason: "caller blocked", code: rejectCodeForbidden}
+	}
+	if len(phoneNumber.AllowList) > 0 && !matchCaller(phoneNumber.AllowList, callers) {
+		return nil, nil, nil, &inboundRejection{reason: "caller not allowed", code: rejectCodeForbidden}
+	}
+	key, err := dao.NewRobotKeyRepo(app.DB).GetRobotKeyByID(phoneNumber.RobotKeyID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	robot, err := dao.NewRobotRepo(app.DB).GetRobotByID(phoneNumber.RobotID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if key.UserID != phoneNumber.UserID || robot.UserID != phoneNumber.UserID {
+		return nil, nil, nil, errors.New("number misconfigured")
+	}
+	return phoneNumber, key, robot, nil
+}
+
+// numberCandidates 号码或SIP URI可用于匹配的写法，依次为原值、去掉参数的URI、用户部分、去掉+的号码
+func numberCandidates(value string) []string {
+	value = strings.TrimSpace(value)
+	candidates := []string{value}
+	add := func(candidate string) {
+		if candidate == "" {
+			return
+		}
+		for _, existing := range candidates {
+			if existing == candidate {
+				return
+			}
+		}
+		candidates = append(candidates, candidate)
+	}
+	uri := value
+	if i := strings.IndexAny(uri, ";?>"); i >= 0 {
+		uri = uri[:i]
+	}
+	uri = strings.TrimPrefix(uri, "<")
+	add(uri)
+	user := uri
+	for _, scheme := range []string{"sips:", "sip:", "tel:"} {
+		user = strings.TrimPrefix(user, scheme)
+	}
+	if i := strings.Index(user, "@"); i >= 0 {
+		user = user[:i]
+	}
+	add(user)
+	add(strings.TrimPrefix(user, "+"))
+	return candidates
+}
+
+// matchCaller 主叫的任一写法命中名单即匹配，名单项以*结尾时按前缀匹配
+func matchCaller(patterns []string, callers []string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		prefix, isPrefix := strings.CutSuffix(pattern, "*")
+		for _, caller := range callers {
+			if caller == "" {
+				continue
+			}
+			if caller == pattern || (isPrefix && strings.HasPrefix(caller, prefix)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sendRejectToRust 拒绝呼入，此时还没有会话，直接写入该通电话的rust连接
+func sendRejectToRust(conn *websocket.Conn, cmd model.RejectCommand) error {
+	cmdBytes, err := json.Marshal(cmd)
+	if err != nil {
+		return err
+	}
+	return conn.WriteMessage(websocket.TextMessage, cmdBytes)
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNumberCandidates(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  []string
+	}{
+		{name: "plain number", value: "13800138000", want: []string{"13800138000"}},
+		{name: "plus number", value: " +8613800138000 ", want: []string{"+8613800138000", "8613800138000"}},
+		{name: "tel uri", value: "tel:+8613800138000", want: []string{"tel:+8613800138000", "+8613800138000", "8613800138000"}},
+		{
+			name:  "sip uri with params",
+			value: "sip:+8613800138000@10.0.0.1:5060;transport=udp",
+			want:  []string{"sip:+8613800138000@10.0.0.1:5060;transport=udp", "sip:+8613800138000@10.0.0.1:5060", "+8613800138000", "8613800138000"},
+		},
+		{
+			name:  "bracketed sips uri",
+			value: "<sips:1001@pbx.example.com>;tag=abc",
+			want:  []string{"<sips:1001@pbx.example.com>;tag=abc", "sips:1001@pbx.example.com", "1001"},
+		},
+		{
+			name:  "sip uri with headers",
+			value: "sip:1001@pbx.example.com?X-Campaign=42",
+			want:  []string{"sip:1001@pbx.example.com?X-Campaign=42", "sip:1001@pbx.example.com", "1001"},
+		},
+		{name: "empty", value: "", want: []string{""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := numberCandidates(tt.value); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("numberCandidates(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchCaller(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		caller   string
+		want     bool
+	}{
+		{name: "exact number", patterns: []string{"13800138000"}, caller: "13800138000", want: true},
+		{name: "plus form matches bare pattern", patterns: []string{"8613800138000"}, caller: "+8613800138000", want: true},
+		{name: "plus pattern matches tel uri", patterns: []string{"+8613800138000"}, caller: "tel:+8613800138000", want: true},
+		{name: "sip uri user part", patterns: []string{"1001"}, caller: "<sip:1001@pbx.example.com>;tag=abc", want: true},
+		{name: "prefix pattern", patterns: []string{"86138*"}, caller: "sip:+8613800138000@10.0.0.1;transport=udp", want: true},
+		{name: "prefix pattern with spaces", patterns: []string{" 400* "}, caller: "4008001234", want: true},
+		{name: "prefix does not match", patterns: []string{"86139*"}, caller: "+8613800138000", want: false},
+		{name: "star matches any caller", patterns: []string{"*"}, caller: "1001", want: true},
+		{name: "no partial match without star", patterns: []string{"1380013"}, caller: "13800138000", want: false},
+		{name: "empty caller", patterns: []string{"", "*"}, caller: "", want: false},
+		{name: "empty patterns", caller: "13800138000", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchCaller(tt.patterns, numberCandidates(tt.caller)); got != tt.want {
+				t.Errorf("matchCaller(%q, %q) = %v, want %v", tt.patterns, tt.caller, got, tt.want)
+			}
+		})
+	}
+}
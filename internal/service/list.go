@@ -0,0 +1,59 @@
+package service
+
+import (
+	"miniRustpbxgo/internal/dao"
+)
+
+// ListReq 列表接口通用的查询参数，通过query传递
+type ListReq struct {
+	Page   int    `form:"page" binding:"omitempty,min=1"`                          // 页码（可选，默认1）
+	Size   int    `form:"size" binding:"omitempty,min=1,max=100"`                  // 每页条数（可选，默认10，最大100）
+	Cursor string `form:"cursor" binding:"omitempty"`                              // 游标（可选，传入后忽略page）
+	Name   string `form:"name" binding:"omitempty,max=100"`                        // 搜索关键字（可选）
+	SortBy string `form:"sort_by" binding:"omitempty,oneof=created_at updated_at"` // 排序字段（可选，默认created_at）
+	Order  string `form:"order" binding:"omitempty,oneof=asc desc"`                // 排序方向（可选，默认desc）
+}
+
+// ListRsp 列表接口统一的返回结构
+type ListRsp[T any] struct {
+	List       []T    `json:"list"`
+	Total      int64  `json:"total"`
+	Page       int    `json:"page,omitempty"`
+	Size       int    `json:"size"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+func (req *ListReq) toQuery() dao.ListQuery {
+	return dao.ListQuery{
+		Page:   req.Page,
+		Size:   req.Size,
+		Cursor: req.Cursor,
+		Name:   req.Name,
+		SortBy: req.SortBy,
+		Desc:   req.Order != "asc",
+	}
+}
+
+// newListRsp 将查询结果转换为统一的列表返回结构
+func newListRsp[M any, T any](req *ListReq, result *dao.ListResult[M], convert func(item *M) T) *ListRsp[T] {
+	rsp := &ListRsp[T]{
+		List:       make([]T, 0, len(result.Items)),
+		Total:      result.Total,
+		Size:       req.Size,
+		NextCursor: result.NextCursor,
+	}
+	if rsp.Size == 0 {
+		rsp.Size = dao.DefaultPageSize
+	}
+	// 游标分页时页码没有意义
+	if req.Cursor == "" {
+		rsp.Page = req.Page
+		if rsp.Page == 0 {
+			rsp.Page = 1
+		}
+	}
+	for i := range result.Items {
+		rsp.List = append(rsp.List, convert(&result.Items[i]))
+	}
+	return rsp
+}
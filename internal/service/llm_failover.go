@@ -0,0 +1,77 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"miniRustpbxgo/internal/handler"
+	"miniRustpbxgo/internal/model"
+)
+
+// defaultLLMFallbackText 未配置兜底话术时，所有大模型节点都失败后播报
+const defaultLLMFallbackText = "抱歉，我这边暂时无法回答，请您稍后再说一遍。"
+
+// validateLLMEndpoints 校验备用大模型节点能够创建对应的提供商
+func validateLLMEndpoints(endpoints []model.RobotLLMEndpoint) error {
+	for i, endpoint := range endpoints {
+		if endpoint.Provider == "" {
+			return fmt.Errorf("llm endpoint %d: provider is required", i)
+		}
+		if _, err := handler.NewLLMProvider(endpoint.Provider, endpoint.APIKey, endpoint.APIURL); err != nil {
+			return fmt.Errorf("llm endpoint %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// buildLLMProvider 按密钥上的大模型配置作为主节点，机器人的备用节点依次排在其后，
+// 组合成带重试、切换和熔断的提供商
+func buildLLMProvider(key *model.RobotKey, robot *model.Robot, logger *logrus.Logger) (handler.LLMProvider, error) {
+	primary, err := handler.NewLLMProvider(key.LLMProvider, key.LLMApiKey, key.LLMApiUrl)
+	if err != nil {
+		return nil, err
+	}
+	endpoints := []handler.FailoverEndpoint{{
+		Provider:   primary,
+		BreakerKey: llmBreakerKey(key.LLMProvider, key.LLMApiUrl, key.LLMApiKey),
+	}}
+	for i, endpoint := range robot.LLMEndpoints {
+		llmProvider, err := handler.NewLLMProvider(endpoint.Provider, endpoint.APIKey, endpoint.APIURL)
+		if err != nil {
+			return nil, fmt.Errorf("llm endpoint %d: %w", i, err)
+		}
+		endpoints = append(endpoints, handler.FailoverEndpoint{
+			Provider:   llmProvider,
+			Model:      endpoint.Model,
+			BreakerKey: llmBreakerKey(endpoint.Provider, endpoint.APIURL, endpoint.APIKey),
+		})
+	}
+	return handler.NewFailoverProvider(endpoints, logger), nil
+}
+
+// llmBreakerKey 熔断按提供商、地址和密钥区分，某个用户密钥错误不影响其他用户使用同一地址；
+// 键中只保存密钥的摘要
+func llmBreakerKey(provider, apiURL, apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return provider + "|" + apiURL + "|" + hex.EncodeToString(sum[:8])
+}
+
+// speakLLMFallback 大模型无法应答时播报兜底话术，避免来电者一直等不到回应
+func (backendForWeb *BackendForWeb) speakLLMFallback() {
+	fallbackText := defaultLLMFallbackText
+	if backendForWeb.Robot != nil && backendForWeb.Robot.LLMFallbackText != "" {
+		fallbackText = backendForWeb.Robot.LLMFallbackText
+	}
+	playID := fmt.Sprintf("llm-fallback-%s", uuid.New().String())
+	if err := backendForWeb.SendTTSCommandForRustBackend(fallbackText, playID, false, nil); err != nil {
+		logrus.Errorf("send llm fallback error:%v", err)
+		return
+	}
+	backendForWeb.LLMHandler.AddAssistantMessage(fallbackText)
+	backendForWeb.ForwardToWebConn(&Event{
+		Event: "LLMResult",
+		Text:  fallbackText,
+	})
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"miniRustpbxgo/internal/model"
+)
+
+func TestValidateLLMEndpoints(t *testing.T) {
+	tests := []struct {
+		name      string
+		endpoints []model.RobotLLMEndpoint
+		// wantErr is part of the error, empty means valid
+		wantErr string
+	}{
+		{name: "no endpoints"},
+		{
+			name: "known and compatible providers",
+			endpoints: []model.RobotLLMEndpoint{
+				{Provider: "dashscope", APIKey: "sk-1"},
+				{Provider: "ollama", APIURL: "http://127.0.0.1:11434", Model: "qwen2.5"},
+				{Provider: "deepseek", APIKey: "sk-2", APIURL: "https://api.deepseek.com/v1"},
+			},
+		},
+		{
+			name:      "missing provider",
+			endpoints: []model.RobotLLMEndpoint{{Provider: "openai", APIKey: "sk-1"}, {APIKey: "sk-2"}},
+			wantErr:   "llm endpoint 1: provider is required",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateLLMEndpoints(tt.endpoints)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBuildLLMProvider(t *testing.T) {
+	tests := []struct {
+		name      string
+		key       *model.RobotKey
+		endpoints []model.RobotLLMEndpoint
+		// wantName and wantModel come from the key's provider, it is always tried first
+		wantName  string
+		wantModel string
+	}{
+		{
+			name:      "key provider only",
+			key:       &model.RobotKey{LLMProvider: "ollama"},
+			wantName:  "ollama",
+			wantModel: "qwen2.5",
+		},
+		{
+			name:      "key provider before the robot's endpoints",
+			key:       &model.RobotKey{LLMProvider: "dashscope", LLMApiKey: "sk-1"},
+			endpoints: []model.RobotLLMEndpoint{{Provider: "ollama", Model: "llama3"}},
+			wantName:  "dashscope",
+			wantModel: "qwen-turbo",
+		},
+		{
+			// the scripted provider is for tests only, a file url must not be read as a script
+			name:      "scripted is an OpenAI-compatible name",
+			key:       &model.RobotKey{LLMProvider: "scripted", LLMApiKey: "sk-1", LLMApiUrl: "file:///etc/passwd"},
+			wantName:  "scripted",
+			wantModel: "qwen-turbo",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := buildLLMProvider(tt.key, &model.Robot{LLMEndpoints: tt.endpoints}, logrus.New())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if provider.Name() != tt.wantName || provider.DefaultModel() != tt.wantModel {
+				t.Errorf("provider = %s/%s, want %s/%s", provider.Name(), provider.DefaultModel(), tt.wantName, tt.wantModel)
+			}
+		})
+	}
+}
+
+func TestLLMBreakerKey(t *testing.T) {
+	base := llmBreakerKey("dashscope", "", "sk-1")
+	if strings.Contains(base, "sk-1") {
+		t.Errorf("breaker key %q contains the api key", base)
+	}
+	if other := llmBreakerKey("dashscope", "", "sk-2"); other == base {
+		t.Errorf("keys sk-1 and sk-2 share the breaker %q", base)
+	}
+	if same := llmBreakerKey("dashscope", "", "sk-1"); same != base {
+		t.Errorf("breaker key = %q, want %q for the same key", same, base)
+	}
+}
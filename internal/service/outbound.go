@@ -0,0 +1,201 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"miniRustpbxgo/internal/dao"
+	"miniRustpbxgo/internal/model"
+	"net/http"
+)
+
+// sipCallType rust通过SIP外呼的ws路径
+const sipCallType = "sip"
+
+// OutboundCallReq 发起外呼的请求体
+type OutboundCallReq struct {
+	ApiKey    string `json:"api_key" binding:"required"`    // 机器人密钥（必填）
+	ApiSecret string `json:"api_secret" binding:"required"` // 机器人密钥Secret（必填）
+	RobotID   uint   `json:"robot_id" binding:"required"`   // 接听后对话的机器人ID（必填）
+	Callee    string `json:"callee" binding:"required"`     // 被叫号码或SIP URI（必填）
+	Caller    string `json:"caller" binding:"omitempty"`    // 主叫号码（可选，默认使用线路配置）
+	// 调用方自定义变量，用于渲染系统提示词和开场白模板
+	Variables map[string]string `json:"variables" binding:"omitempty"`
+	// SIP线路的认证信息和自定义头（可选）
+	Sip *model.SipOption `json:"sip" binding:"omitempty"`
+}
+
+// CallStatusReq 查询或订阅通话状态的请求参数
+type CallStatusReq struct {
+	CallID string `form:"call_id" binding:"required"` // 通话唯一标识（必填）
+	UserID uint   `form:"user_id" binding:"required"` // 通话所属用户ID（必填）
+}
+
+// OutboundCall 通过rust的SIP通道呼叫被叫，接通后由机器人对话，返回用于查询状态的通话ID
+func (app *App) OutboundCall(c *gin.Context) {
+	var req OutboundCallReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logrus.Error("OutboundCall bind json failed: ", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	key, err := dao.NewRobotKeyRepo(app.DB).GetRobotKeyByAPIKey(req.ApiKey)
+	if err != nil {
+		logrus.Errorf("OutboundCall GetRobotKeyByAPIKey error:%v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if key == nil || key.APISecret != req.ApiSecret {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "key not found"})
+		return
+	}
+	robot, err := dao.NewRobotRepo(app.DB).GetRobotByID(req.RobotID)
+	if err != nil {
+		logrus.Errorf("OutboundCall GetRobotByID error:%v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if robot == nil || robot.UserID != key.UserID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "robot not found"})
+		return
+	}
+
+	backendForWeb := NewBackendForWebByNoParam(app.DB)
+	if err := backendForWeb.setupRobot(key, robot, req.Variables); err != nil {
+		logrus.Errorf("OutboundCall setupRobot error:%v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	backendForWeb.resetCall()
+	backendForWeb.startCallRecord(model.CallDirectionOutbound, req.Caller, req.Callee)
+	record := backendForWeb.callRecordSnapshot()
+	if record == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "create call record failed"})
+		return
+	}
+
+	backendForRust := NewBackendForRust(endPoint)
+	if _, err := backendForRust.Dial(sipCallType); err != nil {
+		logrus.Errorf("OutboundCall connect rustBackend error:%v", err)
+		backendForWeb.finishCallRecord("connect error", "system")
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	app.runSipCall(backendForRust, backendForWeb)
+
+	inviteCmd := model.InviteCommand{
+		Command: "invite",
+		Option: model.CallOption{
+			Callee: req.Callee,
+			Caller: req.Caller,
+			Sip:    req.Sip,
+			ASR:    backendForWeb.AsrOption,
+			TTS:    backendForWeb.TtsOption,
+			Eou:    buildEouOption(key, robot),
+		},
+	}
+	if err := backendForWeb.sendCommandToRust(inviteCmd); err != nil {
+		logrus.Errorf("OutboundCall send invite error:%v", err)
+		backendForWeb.SolveCallFailed("invite error")
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	logrus.WithFields(logrus.Fields{
+		"callID": record.CallID,
+		"callee": req.Callee,
+		"robot":  robot.ID,
+	}).Info("outbound call invited")
+	c.JSON(http.StatusOK, gin.H{"code": 200,
+		"message": "ok",
+		"data": gin.H{
+			"call_id": record.CallID,
+			"status":  record.Status,
+		}})
+}
+
+// CallStatus 查询通话状态，进行中和已结束的通话均从通话记录读取
+func (app *App) CallStatus(c *gin.Context) {
+	var req CallStatusReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		logrus.Error("CallStatus bind query failed: ", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	record, err := dao.NewCallRecordRepo(app.DB).GetCallRecordByCallID(req.CallID)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && record.UserID != req.UserID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "call not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200,
+		"message": "ok",
+		"data":    record})
+}
+
+// CallEvents 以websocket推送进行中SIP通话的事件，与浏览器通话收到的事件相同，客户端可发送hangup挂断
+func (app *App) CallEvents(c *gin.Context) {
+	var req CallStatusReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		logrus.Error("CallEvents bind query failed: ", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	backendForWeb := app.sipCalls.get(req.CallID)
+	if backendForWeb == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "call not found or already ended"})
+		return
+	}
+	if record := backendForWeb.callRecordSnapshot(); record == nil || record.UserID != req.UserID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "call not found or already ended"})
+		return
+	}
+	if backendForWeb.hasWebConn() {
+		c.JSON(http.StatusConflict, gin.H{"error": "call events already subscribed"})
+		return
+	}
+	conn, err := backendForWeb.Upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		logrus.Error("websocket upgrade error: ", err)
+		return
+	}
+	// 同一通电话只允许一个订阅，升级期间被抢先订阅时关闭本连接
+	if !backendForWeb.attachWebConn(conn) {
+		_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "call events already subscribed"))
+		_ = conn.Close()
+		return
+	}
+	defer func() {
+		backendForWeb.detachWebConn(conn)
+		_ = conn.Close()
+	}()
+	var clientEvent struct {
+		Event  string `json:"event"`
+		Reason string `json:"reason"`
+	}
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				logrus.Error("CallEvents read error: ", err)
+			}
+			return
+		}
+		if err := json.Unmarshal(msg, &clientEvent); err != nil {
+			logrus.Error("CallEvents unmarshal error: ", err)
+			continue
+		}
+		if clientEvent.Event == "hangup" {
+			reason := clientEvent.Reason
+			if reason == "" {
+				reason = "api"
+			}
+			backendForWeb.hangupBy(reason, apiHangupInitiator)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"miniRustpbxgo/internal/dao"
+	"miniRustpbxgo/internal/model"
+	"net/http"
+	"strings"
+)
+
+type PhoneNumberCreateReq struct {
+	UserID     uint     `json:"user_id" binding:"required"`                  // 关联用户ID（必填）
+	Number     string   `json:"number" binding:"required,max=255"`           // 被叫号码或SIP URI（必填）
+	RobotID    uint     `json:"robot_id" binding:"required"`                 // 接听的机器人ID（必填）
+	RobotKeyID uint     `json:"robot_key_id" binding:"required"`             // 使用的机器人密钥ID（必填）
+	Enabled    *bool    `json:"enabled" binding:"omitempty"`                 // 是否接听呼入（可选，默认true）
+	AllowList  []string `json:"allow_list" binding:"omitempty,dive,max=255"` // 允许呼入的主叫号码（可选，为空不限制，以*结尾按前缀匹配）
+	BlockList  []string `json:"block_list" binding:"omitempty,dive,max=255"` // 拒绝呼入的主叫号码（可选，以*结尾按前缀匹配）
+}
+
+type PhoneNumberUpdateReq struct {
+	Id         uint     `json:"id" binding:"required"`
+	UserID     uint     `json:"user_id" binding:"required"`                  // 关联用户ID（必填）
+	RobotID    uint     `json:"robot_id" binding:"omitempty"`                // 接听的机器人ID（可选）
+	RobotKeyID uint     `json:"robot_key_id" binding:"omitempty"`            // 使用的机器人密钥ID（可选）
+	Enabled    *bool    `json:"enabled" binding:"omitempty"`                 // 是否接听呼入（可选）
+	AllowList  []string `json:"allow_list" binding:"omitempty,dive,max=255"` // 允许呼入的主叫号码（可选，传空数组清空）
+	BlockList  []string `json:"block_list" binding:"omitempty,dive,max=255"` // 拒绝呼入的主叫号码（可选，传空数组清空）
+}
+
+type PhoneNumberListReq struct {
+	ListReq
+	UserID uint `form:"user_id" binding:"required"` // 关联用户ID（必填）
+}
+
+// CreatePhoneNumber 登记呼入号码，呼入该号码的电话由指定机器人接听
+func (app *App) CreatePhoneNumber(ctx *gin.Context) {
+	var req PhoneNumberCreateReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		logrus.Errorf("PhoneNumberCreateReq error:%v", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	phoneNumber := &model.PhoneNumber{
+		UserID:     req.UserID,
+		Number:     strings.TrimSpace(req.Number),
+		RobotID:    req.RobotID,
+		RobotKeyID: req.RobotKeyID,
+		Enabled:    req.Enabled == nil || *req.Enabled,
+		AllowList:  req.AllowList,
+		BlockList:  req.BlockList,
+	}
+	if err := app.validatePhoneNumber(phoneNumber); err != nil {
+		logrus.Errorf("validatePhoneNumber error:%v", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	phoneNumberRepo := dao.NewPhoneNumberRepo(app.DB)
+	if existing, err := phoneNumberRepo.FindPhoneNumber([]string{phoneNumber.Number}); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	} else if existing != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "number already exists"})
+		return
+	}
+	if _, err := phoneNumberRepo.CreatePhoneNumber(phoneNumber); err != nil {
+		logrus.Errorf("CreatePhoneNumber error:%v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200,
+		"message": "ok",
+		"data":    phoneNumber})
+}
+
+// UpdatePhoneNumber 修改呼入号码的路由和黑白名单，号码本身不可修改
+func (app *App) UpdatePhoneNumber(ctx *gin.Context) {
+	var req PhoneNumberUpdateReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		logrus.Errorf("PhoneNumberUpdateReq error:%v", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	phoneNumberRepo := dao.NewPhoneNumberRepo(app.DB)
+	phoneNumber, err := phoneNumberRepo.GetPhoneNumberByID(req.Id)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && phoneNumber.UserID != req.UserID) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "phone number not found"})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if req.RobotID != 0 {
+		phoneNumber.RobotID = req.RobotID
+	}
+	if req.RobotKeyID != 0 {
+		phoneNumber.RobotKeyID = req.RobotKeyID
+	}
+	if req.Enabled != nil {
+		phoneNumber.Enabled = *req.Enabled
+	}
+	if req.AllowList != nil {
+		phoneNumber.AllowList = req.AllowList
+	}
+	if req.BlockList != nil {
+		phoneNumber.BlockList = req.BlockList
+	}
+	if err := app.validatePhoneNumber(phoneNumber); err != nil {
+		logrus.Errorf("validatePhoneNumber error:%v", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := phoneNumberRepo.UpdatePhoneNumber(phoneNumber); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200,
+		"message": "ok",
+		"data":    phoneNumber})
+}
+
+// PhoneNumberList 分页查询呼入号码，name按号码搜索
+func (app *App) PhoneNumberList(c *gin.Context) {
+	var req PhoneNumberListReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		logrus.Error("PhoneNumberList bind query failed: ", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	result, err := dao.NewPhoneNumberRepo(app.DB).ListPhoneNumbers(req.UserID, req.toQuery())
+	if err != nil {
+		logrus.Error("ListPhoneNumbers failed: ", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200,
+		"message": "ok",
+		"data": newListRsp(&req.ListReq, result, func(phoneNumber *model.PhoneNumber) model.PhoneNumber {
+			return *phoneNumber
+		})})
+}
+
+// validatePhoneNumber 校验号码格式，以及机器人和密钥属于同一用户
+func (app *App) validatePhoneNumber(phoneNumber *model.PhoneNumber) error {
+	if phoneNumber.Number == "" {
+		return errors.New("number is required")
+	}
+	for _, pattern := range append(append([]string{}, phoneNumber.AllowList...), phoneNumber.BlockList...) {
+		if strings.TrimSpace(pattern) == "" {
+			return fmt.Errorf("invalid caller pattern: %q", pattern)
+		}
+	}
+	robot, err := dao.NewRobotRepo(app.DB).GetRobotByID(phoneNumber.RobotID)
+	if err != nil || robot.UserID != phoneNumber.UserID {
+		return errors.New("robot not found")
+	}
+	key, err := dao.NewRobotKeyRepo(app.DB).GetRobotKeyByID(phoneNumber.RobotKeyID)
+	if err != nil || key.UserID != phoneNumber.UserID {
+		return errors.New("robot key not found")
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+package service
+
+import (
+	"github.com/sirupsen/logrus"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// playID的播放状态
+const (
+	playbackQueued      = "queued"      // 已发送给rust，等待播放
+	playbackPlaying     = "playing"     // 正在播放
+	playbackFinished    = "finished"    // 所有分段播放完毕
+	playbackInterrupted = "interrupted" // 被打断，剩余分段不再播放
+)
+
+// maxTrackedPlaybacks 每通电话保留的播放记录数，超出后丢弃最早已结束的记录
+const maxTrackedPlaybacks = 64
+
+// playback 一个playID的播放情况，一轮回复的多个分段共用一个playID
+type playback struct {
+	playID    string
+	state     string
+	pending   int             // 已发送但未播放结束的分段数
+	segments  int             // 已发送的分段数
+	queuedAt  time.Time       // 首段发送时间
+	startedAt time.Time       // 首段开始播放时间
+	durations []time.Duration // 每段的播放时长
+	texts     []playbackText  // 每段发送的文本，流式合成的文本都在同一段中
+}
+
+// playbackText 一段语音的文本，spoken是发送给rust合成的文本，source是大模型历史中对应的原文，
+// 未经规范化直接发送的文本source为空
+type playbackText struct {
+	source string
+	spoken string
+}
+
+// sourceText 大模型历史中对应的原文
+func (text playbackText) sourceText() string {
+	if text.source == "" {
+		return text.spoken
+	}
+	return text.source
+}
+
+// playbackTracker 按playID关联发送的语音和rust回传的trackStart、trackEnd、interruption事件
+type playbackTracker struct {
+	mutex sync.Mutex
+	plays []*playback // 按发送顺序排列，用于匹配不带playId的事件
+}
+
+// find 查找playID，为空时按顺序取第一个处于states之一的播放，调用方需持有mutex
+func (tracker *playbackTracker) find(playID string, states ...string) *playback {
+	for _, play := range tracker.plays {
+		if playID != "" {
+			if play.playID == playID {
+				return play
+			}
+			continue
+		}
+		for _, state := range states {
+			if play.state == state {
+				return play
+			}
+		}
+	}
+	return nil
+}
+
+// trackPlaybackQueued 发送一段语音给rust，流式合成的文本追加到同一段语音中，只在首次发送时计为一段
+func (backendForWeb *BackendForWeb) trackPlaybackQueued(playID, text string, streaming bool) {
+	if playID == "" {
+		return
+	}
+	tracker := &backendForWeb.playbacks
+	tracker.mutex.Lock()
+	play := tracker.find(playID)
+	if play == nil {
+		play = &playback{playID: playID, queuedAt: time.Now()}
+		tracker.plays = append(tracker.plays, play)
+		tracker.evictLocked()
+	}
+	if !streaming || play.pending == 0 {
+		play.segments++
+		play.pending++
+		play.texts = append(play.texts, playbackText{})
+	}
+	play.texts[len(play.texts)-1].spoken += text
+	changed := play.state != playbackQueued && play.state != playbackPlaying
+	if changed {
+		play.state = playbackQueued
+	}
+	tracker.mutex.Unlock()
+
+	if changed {
+		backendForWeb.forwardPlayback(playID, playbackQueued, 0)
+	}
+}
+
+// trackPlaybackSource 记录最近发送的一段语音在大模型历史中的原文，规范化前后的文本不同时由调用方在发送后记录
+func (backendForWeb *BackendForWeb) trackPlaybackSource(playID, source string) {
+	tracker := &backendForWeb.playbacks
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	if play := tracker.find(playID); play != nil && playID != "" && len(play.texts) > 0 {
+		play.texts[len(play.texts)-1].source += source
+	}
+}
+
+// trackPlaybackStarted rust开始播放一段语音
+func (backendForWeb *BackendForWeb) trackPlaybackStarted(playID string) {
+	tracker := &backendForWeb.playbacks
+	tracker.mutex.Lock()
+	play := tracker.find(playID, playbackQueued)
+	if play == nil && playID != "" {
+		// 未经tts发送的语音（如play命令播放的音频）在开始播放时才开始跟踪
+		play = &playback{playID: playID, queuedAt: time.Now(), segments: 1, pending: 1}
+		tracker.plays = append(tracker.plays, play)
+		tracker.evictLocked()
+	}
+	if play == nil || play.state == playbackInterrupted || play.state == playbackFinished {
+		tracker.mutex.Unlock()
+		return
+	}
+	changed := play.state != playbackPlaying
+	if changed {
+		play.state = playbackPlaying
+		if play.startedAt.IsZero() {
+			play.startedAt = time.Now()
+		}
+	}
+	playID = play.playID
+	tracker.mutex.Unlock()
+
+	if changed {
+		backendForWeb.forwardPlayback(playID, playbackPlaying, 0)
+	}
+}
+
+// trackPlaybackEnded rust播放完一段语音，返回该playID的所有分段是否都已播放完毕。
+// 不认识的playID和旧版rust不回传playId时返回true，由调用方按原逻辑处理
+func (backendForWeb *BackendForWeb) trackPlaybackEnded(playID string, durationMs uint64) bool {
+	tracker := &backendForWeb.playbacks
+	tracker.mutex.Lock()
+	play := tracker.find(playID, playbackPlaying, playbackQueued)
+	if play == nil || play.state == playbackInterrupted || play.state == playbackFinished {
+		tracker.mutex.Unlock()
+		return true
+	}
+	play.durations = append(play.durations, time.Duration(durationMs)*time.Millisecond)
+	if play.pending > 0 {
+		play.pending--
+	}
+	finished := play.pending == 0 || playID == ""
+	if finished {
+		play.state = playbackFinished
+	}
+	playID = play.playID
+	fields := play.fields()
+	tracker.mutex.Unlock()
+
+	if !finished {
+		return false
+	}
+	logrus.WithFields(fields).Info("playback finished")
+	backendForWeb.forwardPlayback(playID, playbackFinished, durationMs)
+	return true
+}
+
+// trackPlaybackInterrupted 机器人被打断，rust丢弃了所有未播放的语音
+func (backendForWeb *BackendForWeb) trackPlaybackInterrupted() {
+	tracker := &backendForWeb.playbacks
+	tracker.mutex.Lock()
+	var interrupted []*playback
+	var fields []logrus.Fields
+	for _, play := range tracker.plays {
+		if play.state == playbackQueued || play.state == playbackPlaying {
+			play.state = playbackInterrupted
+			play.pending = 0
+			interrupted = append(interrupted, play)
+			fields = append(fields, play.fields())
+		}
+	}
+	tracker.mutex.Unlock()
+
+	for i, play := range interrupted {
+		logrus.WithFields(fields[i]).Info("playback interrupted")
+		backendForWeb.forwardPlayback(play.playID, playbackInterrupted, 0)
+	}
+}
+
+// isPlaying 机器人是否有已发送给rust但还没播放结束的语音，包括排队中和正在播放的
+func (backendForWeb *BackendForWeb) isPlaying() bool {
+	return backendForWeb.activePlayback() != ""
+}
+
+// activePlayback 最近发送且还没播放结束的playID，没有时返回空
+func (backendForWeb *BackendForWeb) activePlayback() string {
+	tracker := &backendForWeb.playbacks
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	for i := len(tracker.plays) - 1; i >= 0; i-- {
+		play := tracker.plays[i]
+		if play.state == playbackQueued || play.state == playbackPlaying {
+			return play.playID
+		}
+	}
+	return ""
+}
+
+// playedText playID中已播完分段的原文字数及正在播放的分段的文本，没有记录文本时ok为false
+func (backendForWeb *BackendForWeb) playedText(playID string) (playedRunes int, current playbackText, ok bool) {
+	tracker := &backendForWeb.playbacks
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	play := tracker.find(playID)
+	if play == nil || playID == "" || len(play.texts) == 0 {
+		return 0, playbackText{}, false
+	}
+	finished := min(len(play.durations), len(play.texts))
+	for _, text := range play.texts[:finished] {
+		playedRunes += utf8.RuneCountInString(text.sourceText())
+	}
+	if finished < len(play.texts) {
+		current = play.texts[finished]
+	}
+	return playedRunes, current, true
+}
+
+// fields 播放情况的日志字段，调用方需持有mutex
+func (play *playback) fields() logrus.Fields {
+	var played time.Duration
+	for _, duration := range play.durations {
+		played += duration
+	}
+	fields := logrus.Fields{
+		"playID":   play.playID,
+		"state":    play.state,
+		"segments": play.segments,
+		"played":   len(play.durations),
+		"playedMs": played.Milliseconds(),
+	}
+	if !play.startedAt.IsZero() {
+		fields["waitMs"] = play.startedAt.Sub(play.queuedAt).Milliseconds()
+	}
+	return fields
+}
+
+// evictLocked 丢弃最早已结束的播放记录，调用方需持有mutex
+func (tracker *playbackTracker) evictLocked() {
+	for i := 0; len(tracker.plays) > maxTrackedPlaybacks && i < len(tracker.plays); {
+		state := tracker.plays[i].state
+		if state == playbackFinished || state == playbackInterrupted {
+			tracker.plays = append(tracker.plays[:i], tracker.plays[i+1:]...)
+			continue
+		}
+		i++
+	}
+}
+
+// forwardPlayback 将playID的播放状态发给浏览器
+func (backendForWeb *BackendForWeb) forwardPlayback(playID, state string, durationMs uint64) {
+	backendForWeb.ForwardToWebConn(&Event{
+		Event:    "playback",
+		PlayID:   playID,
+		State:    state,
+		Duration: durationMs,
+	})
+}
+
+// resetPlaybacks 新通话开始时清空播放记录
+func (backendForWeb *BackendForWeb) resetPlaybacks() {
+	tracker := &backendForWeb.playbacks
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	tracker.plays = nil
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// playbackStep is one event of the playback state machine, ended is the
+// expected result of a trackPlaybackEnded step
+type playbackStep struct {
+	event  string
+	playID string
+	ended  bool
+}
+
+func TestPlaybackTracker(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []playbackStep
+		// states are the playback states after all steps, active the playID still playing
+		states map[string]string
+		active string
+	}{
+		{
+			name: "segments finish in order",
+			steps: []playbackStep{
+				{event: "queued", playID: "a"}, {event: "queued", playID: "a"},
+				{event: "started", playID: "a"}, {event: "ended", playID: "a", ended: false},
+				{event: "started", playID: "a"}, {event: "ended", playID: "a", ended: true},
+			},
+			states: map[string]string{"a": playbackFinished},
+		},
+		{
+			name: "streaming text is one segment",
+			steps: []playbackStep{
+				{event: "streaming", playID: "a"}, {event: "streaming", playID: "a"},
+				{event: "started", playID: "a"}, {event: "ended", playID: "a", ended: true},
+			},
+			states: map[string]string{"a": playbackFinished},
+		},
+		{
+			name: "events without playId follow the send order",
+			steps: []playbackStep{
+				{event: "queued", playID: "a"}, {event: "queued", playID: "a"}, {event: "queued", playID: "b"},
+				{event: "started"}, {event: "ended", ended: true},
+				{event: "started"},
+			},
+			states: map[string]string{"a": playbackFinished, "b": playbackPlaying},
+			active: "b",
+		},
+		{
+			name: "interruption drops the pending segments",
+			steps: []playbackStep{
+				{event: "queued", playID: "a"}, {event: "queued", playID: "a"}, {event: "queued", playID: "b"},
+				{event: "started", playID: "a"}, {event: "interrupted"},
+				{event: "started", playID: "a"}, {event: "ended", playID: "a", ended: true},
+			},
+			states: map[string]string{"a": playbackInterrupted, "b": playbackInterrupted},
+		},
+		{
+			name: "audio without tts is tracked when it starts",
+			steps: []playbackStep{
+				{event: "ended", playID: "welcome", ended: true},
+				{event: "started", playID: "welcome"}, {event: "ended", playID: "welcome", ended: true},
+			},
+			states: map[string]string{"welcome": playbackFinished},
+		},
+		{
+			name: "a finished playID is queued again",
+			steps: []playbackStep{
+				{event: "queued", playID: "a"}, {event: "ended", playID: "a", ended: true},
+				{event: "queued", playID: "a"},
+			},
+			states: map[string]string{"a": playbackQueued},
+			active: "a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backendForWeb := NewBackendForWebByNoParam(nil)
+			for i, step := range tt.steps {
+				switch step.event {
+				case "queued":
+					backendForWeb.trackPlaybackQueued(step.playID, "好的。", false)
+				case "streaming":
+					backendForWeb.trackPlaybackQueued(step.playID, "好的", true)
+				case "started":
+					backendForWeb.trackPlaybackStarted(step.playID)
+				case "ended":
+					if got := backendForWeb.trackPlaybackEnded(step.playID, 500); got != step.ended {
+						t.Fatalf("step %d: trackPlaybackEnded(%q) = %v, want %v", i, step.playID, got, step.ended)
+					}
+				case "interrupted":
+					backendForWeb.trackPlaybackInterrupted()
+				}
+			}
+			states := make(map[string]string)
+			for _, play := range backendForWeb.playbacks.plays {
+				states[play.playID] = play.state
+			}
+			if !reflect.DeepEqual(states, tt.states) {
+				t.Errorf("states = %v, want %v", states, tt.states)
+			}
+			if got := backendForWeb.activePlayback(); got != tt.active {
+				t.Errorf("activePlayback = %q, want %q", got, tt.active)
+			}
+		})
+	}
+}
+
+func TestPlaybackTrackerEviction(t *testing.T) {
+	backendForWeb := NewBackendForWebByNoParam(nil)
+	backendForWeb.trackPlaybackQueued("playing", "好的。", false)
+	for i := 0; i < maxTrackedPlaybacks+10; i++ {
+		playID := fmt.Sprintf("play-%d", i)
+		backendForWeb.trackPlaybackQueued(playID, "好的。", false)
+		backendForWeb.trackPlaybackEnded(playID, 500)
+	}
+	plays := backendForWeb.playbacks.plays
+	if len(plays) != maxTrackedPlaybacks {
+		t.Fatalf("tracked %d playbacks, want %d", len(plays), maxTrackedPlaybacks)
+	}
+	if plays[0].playID != "playing" {
+		t.Errorf("first playback = %q, want the unfinished one kept", plays[0].playID)
+	}
+	if !backendForWeb.isPlaying() {
+		t.Error("isPlaying = false, want true")
+	}
+}
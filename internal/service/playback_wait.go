@@ -0,0 +1,74 @@
+package service
+
+import (
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+// playbackWait 等待某个playID播放结束后执行的动作
+type playbackWait struct {
+	playID string
+	action func()
+	timer  *time.Timer
+}
+
+// runAfterPlayback 在playID对应的语音播放结束后执行action，超过timeout仍未结束时直接执行
+func (backendForWeb *BackendForWeb) runAfterPlayback(playID string, timeout time.Duration, action func()) {
+	wait := &playbackWait{playID: playID, action: action}
+	backendForWeb.playbackWaitMutex.Lock()
+	backendForWeb.playbackWaits = append(backendForWeb.playbackWaits, wait)
+	backendForWeb.playbackWaitMutex.Unlock()
+	// rust未回传播放结束事件时兜底执行
+	wait.timer = time.AfterFunc(timeout, func() {
+		logrus.WithField("playID", playID).Warn("wait playback end timeout")
+		backendForWeb.firePlaybackWait(wait)
+	})
+}
+
+// SolvePlaybackEnd 播放结束时执行等待该playID的动作
+func (backendForWeb *BackendForWeb) SolvePlaybackEnd(playID string) {
+	backendForWeb.playbackWaitMutex.Lock()
+	var matched []*playbackWait
+	for _, wait := range backendForWeb.playbackWaits {
+		// 旧版rust不回传playId时以任意播放结束为准
+		if playID == "" || wait.playID == playID {
+			matched = append(matched, wait)
+		}
+	}
+	backendForWeb.playbackWaitMutex.Unlock()
+	for _, wait := range matched {
+		backendForWeb.firePlaybackWait(wait)
+	}
+}
+
+func (backendForWeb *BackendForWeb) firePlaybackWait(wait *playbackWait) {
+	backendForWeb.playbackWaitMutex.Lock()
+	found := false
+	for i, item := range backendForWeb.playbackWaits {
+		if item == wait {
+			backendForWeb.playbackWaits = append(backendForWeb.playbackWaits[:i], backendForWeb.playbackWaits[i+1:]...)
+			found = true
+			break
+		}
+	}
+	backendForWeb.playbackWaitMutex.Unlock()
+	if !found {
+		return
+	}
+	if wait.timer != nil {
+		wait.timer.Stop()
+	}
+	wait.action()
+}
+
+// resetPlaybackWaits 新通话开始时丢弃上一通电话未执行的动作
+func (backendForWeb *BackendForWeb) resetPlaybackWaits() {
+	backendForWeb.playbackWaitMutex.Lock()
+	defer backendForWeb.playbackWaitMutex.Unlock()
+	for _, wait := range backendForWeb.playbackWaits {
+		if wait.timer != nil {
+			wait.timer.Stop()
+		}
+	}
+	backendForWeb.playbackWaits = nil
+}
@@ -0,0 +1,15 @@
+package service
+
+import (
+	"github.com/gin-gonic/gin"
+	"miniRustpbxgo/internal/provider"
+	"net/http"
+)
+
+// ProviderList 返回所有语音服务商支持的凭证字段、发音人、语言和采样率
+func (app *App) ProviderList(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"code": 200,
+		"message": "ok",
+		"data":    provider.List(),
+	})
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"miniRustpbxgo/internal/dao"
+	"net/http"
+	"time"
+)
+
+type RobotCloneReq struct {
+	RobotId uint   `json:"robot_id" binding:"required"`      // 被复制的机器人ID（必填）
+	UserID  uint   `json:"user_id" binding:"required"`       // 关联用户ID（必填）
+	Name    string `json:"name" binding:"omitempty,max=100"` // 新机器人名称（可选，默认自动重命名）
+}
+
+// CloneRobot 一键复制机器人配置
+func (app *App) CloneRobot(ctx *gin.Context) {
+	var req RobotCloneReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		logrus.Errorf("RobotCloneReq error:%v", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	robotRepo := dao.NewRobotRepo(app.DB)
+	source, err := robotRepo.GetRobotByID(req.RobotId)
+	if err != nil {
+		logrus.Errorf("CloneRobot GetRobotByID error:%v", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if source.UserID != req.UserID {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "robot does not belong to user"})
+		return
+	}
+	// 直接复制源机器人，导出格式会清空备用大模型节点的密钥和工具请求头
+	robot := *source
+	robot.ID = 0
+	robot.CreatedAt, robot.UpdatedAt = time.Time{}, time.Time{}
+	robot.DeletedAt = gorm.DeletedAt{}
+	if req.Name != "" {
+		existing, err := robotRepo.GetRobotByUserIDAndName(req.UserID, req.Name)
+		if err != nil {
+			logrus.Errorf("CloneRobot GetRobotByUserIDAndName error:%v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if existing != nil {
+			ctx.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("robot %s already exists", req.Name)})
+			return
+		}
+		robot.Name = req.Name
+	} else {
+		name, err := uniqueRobotName(robotRepo, req.UserID, source.Name)
+		if err != nil {
+			logrus.Errorf("CloneRobot uniqueRobotName error:%v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		robot.Name = name
+	}
+	if err := validateRobot(&robot); err != nil {
+		logrus.Errorf("validateRobot error:%v", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := robotRepo.CreateRobot(&robot); err != nil {
+		logrus.Errorf("CloneRobot CreateRobot error:%v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200,
+		"message": "ok",
+		"data": &RobotCreateRsp{
+			Id:   robot.ID,
+			Name: robot.Name,
+		}})
+}
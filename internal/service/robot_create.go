@@ -1,22 +1,65 @@
 package service
 
 import (
+	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 	"miniRustpbxgo/internal/dao"
+	"miniRustpbxgo/internal/handler"
 	"miniRustpbxgo/internal/model"
+	"miniRustpbxgo/internal/prompt"
+	"miniRustpbxgo/internal/provider"
 	"net/http"
+	"slices"
+	"strings"
 )
 
 // RobotCreateReq 接收前端创建Robot的请求体
 type RobotCreateReq struct {
-	UserID       uint    `json:"user_id" binding:"required"` // 关联用户ID（必传）
-	Name         string  `json:"name" binding:"required"`
-	Speed        float32 `json:"speed" binding:"omitempty,min=0.5,max=2.0,required"` // 语音语速（可选，范围0.5-2.0）
-	Volume       int     `json:"volume" binding:"omitempty,min=0,max=10,required"`   // 语音音量（可选，范围0-10）
-	Speaker      string  `json:"speaker" binding:"omitempty,max=50,required"`        // 发音人（可选，最长50字符）
-	Emotion      string  `json:"emotion" binding:"omitempty"`                        // 语音情感（可选，仅支持指定值）
-	SystemPrompt string  `json:"system_prompt" binding:"omitempty,required"`         // 系统提示词（可选，无长度限制）
+	UserID              uint                     `json:"user_id" binding:"required"` // 关联用户ID（必传）
+	Name                string                   `json:"name" binding:"required"`
+	Speed               float32                  `json:"speed" binding:"omitempty,min=0.5,max=2.0,required"`             // 语音语速（可选，范围0.5-2.0）
+	Volume              int                      `json:"volume" binding:"omitempty,min=0,max=10,required"`               // 语音音量（可选，范围0-10）
+	Speaker             string                   `json:"speaker" binding:"omitempty,max=50,required"`                    // 发音人（可选，最长50字符）
+	TTSProvider         string                   `json:"tts_provider" binding:"omitempty,max=100"`                       // 发音人所属服务商（可选，默认tencent）
+	SampleRate          int                      `json:"sample_rate" binding:"omitempty"`                                // 语音合成采样率（可选，需服务商支持）
+	Emotion             string                   `json:"emotion" binding:"omitempty"`                                    // 语音情感（可选，仅支持指定值）
+	SystemPrompt        string                   `json:"system_prompt" binding:"omitempty,required"`                     // 系统提示词（可选，无长度限制）
+	GreetingType        string                   `json:"greeting_type" binding:"omitempty,oneof=text audio llm"`         // 开场白类型（可选，text|audio|llm）
+	Greeting            string                   `json:"greeting" binding:"omitempty"`                                   // 开场白文本或生成指令（可选）
+	GreetingURL         string                   `json:"greeting_url" binding:"omitempty,url,max=255"`                   // 开场白音频地址（audio类型必填）
+	Timezone            string                   `json:"timezone" binding:"omitempty,max=64"`                            // 时区（可选，如Asia/Shanghai）
+	TemplateStrict      bool                     `json:"template_strict"`                                                // 模板缺失变量时是否报错（可选）
+	Tools               []model.RobotTool        `json:"tools" binding:"omitempty"`                                      // 启用的大模型工具（可选，为空时仅启用hangup）
+	Transfer            *model.RobotTransfer     `json:"transfer" binding:"omitempty"`                                   // 转人工配置（可选，启用transfer工具时必填）
+	BargeIn             bool                     `json:"barge_in"`                                                       // 是否允许用户打断机器人说话（可选）
+	BargeInMinMs        int                      `json:"barge_in_min_ms" binding:"omitempty,min=0,max=5000"`             // 触发打断的最短说话时长毫秒数（可选）
+	EouWindowMs         int                      `json:"eou_window_ms" binding:"omitempty,min=0,max=5000"`               // 最后一段识别结果后的等待毫秒数（可选，默认500）
+	EouType             string                   `json:"eou_type" binding:"omitempty,max=50"`                            // rust端断句检测的服务类型（可选）
+	TurnPolicy          string                   `json:"turn_policy" binding:"omitempty,oneof=queue replace ignore"`     // 机器人回答期间用户又说话时的处理方式（可选，queue|replace|ignore，默认queue）
+	Fillers             []model.RobotFiller      `json:"fillers" binding:"omitempty"`                                    // 等待大模型回复时的填充语（可选，如[{"text":"嗯，好的"}]）
+	FillerDelayMs       int                      `json:"filler_delay_ms" binding:"omitempty,min=0,max=10000"`            // 等待多少毫秒后播报填充语（可选，默认1000）
+	SilenceTimeoutSec   int                      `json:"silence_timeout_sec" binding:"omitempty,min=0,max=300"`          // 用户沉默多少秒后追问（可选，为0不追问）
+	SilenceReprompt     string                   `json:"silence_reprompt" binding:"omitempty"`                           // 追问话术（可选）
+	SilenceMaxReprompts int                      `json:"silence_max_reprompts" binding:"omitempty,min=0,max=10"`         // 最多追问次数（可选，默认2）
+	IdleGoodbye         string                   `json:"idle_goodbye" binding:"omitempty"`                               // 沉默挂断前的告别语（可选）
+	MaxCallDurationSec  int                      `json:"max_call_duration_sec" binding:"omitempty,min=0"`                // 通话最长秒数（可选，为0不限制）
+	DurationWarningSec  int                      `json:"duration_warning_sec" binding:"omitempty,min=0"`                 // 达到最长时长前多少秒提醒（可选，为0不提醒）
+	DurationWarning     string                   `json:"duration_warning" binding:"omitempty"`                           // 通话即将结束的提醒话术（可选）
+	DurationGoodbye     string                   `json:"duration_goodbye" binding:"omitempty"`                           // 达到最长时长挂断前的结束语（可选）
+	Dtmf                *model.RobotDtmf         `json:"dtmf" binding:"omitempty"`                                       // 按键菜单和号码收集配置（可选）
+	AmdPolicy           string                   `json:"amd_policy" binding:"omitempty,oneof=hangup voicemail continue"` // 外呼检测到答录机时的处理方式（可选，hangup|voicemail|continue，默认continue）
+	VoicemailText       string                   `json:"voicemail_text" binding:"omitempty"`                             // 答录机留言文本（voicemail策略，与音频二选一）
+	VoicemailURL        string                   `json:"voicemail_url" binding:"omitempty,url,max=255"`                  // 答录机留言音频地址（voicemail策略，优先于文本）
+	StreamingTTS        bool                     `json:"streaming_tts"`                                                  // 是否使用流式语音合成（可选，需服务商支持）
+	SegmentMinRunes     int                      `json:"segment_min_runes" binding:"omitempty,min=0,max=200"`            // 回复分段的最少字数（可选，默认6）
+	SegmentMaxRunes     int                      `json:"segment_max_runes" binding:"omitempty,min=0,max=500"`            // 回复分段的最多字数（可选，默认80）
+	Lexicon             map[string]string        `json:"lexicon" binding:"omitempty"`                                    // 发音词典（可选，如{"AI":"人工智能"}）
+	ContextMaxTokens    int                      `json:"context_max_tokens" binding:"omitempty,min=0"`                   // 对话历史的token预算（可选，默认8000）
+	ContextStrategy     string                   `json:"context_strategy" binding:"omitempty,oneof=truncate summarize"`  // 超出预算时的处理方式（可选，truncate|summarize）
+	LLMEndpoints        []model.RobotLLMEndpoint `json:"llm_endpoints" binding:"omitempty"`                              // 备用大模型节点（可选，主节点失败后按顺序切换）
+	LLMFallbackText     string                   `json:"llm_fallback_text"`                                              // 所有大模型节点都失败时播报的话术（可选）
 }
 
 type RobotCreateRsp struct {
@@ -33,16 +76,58 @@ func (app *App) CreateRobot(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	robot := &model.Robot{
+		UserID:              req.UserID,
+		Name:                req.Name,
+		Speed:               req.Speed,
+		Volume:              req.Volume,
+		Speaker:             req.Speaker,
+		TTSProvider:         req.TTSProvider,
+		SampleRate:          req.SampleRate,
+		Emotion:             req.Emotion,
+		SystemPrompt:        req.SystemPrompt,
+		GreetingType:        req.GreetingType,
+		Greeting:            req.Greeting,
+		GreetingURL:         req.GreetingURL,
+		Timezone:            req.Timezone,
+		TemplateStrict:      req.TemplateStrict,
+		Tools:               req.Tools,
+		Transfer:            req.Transfer,
+		BargeIn:             req.BargeIn,
+		BargeInMinMs:        req.BargeInMinMs,
+		EouWindowMs:         req.EouWindowMs,
+		EouType:             req.EouType,
+		TurnPolicy:          req.TurnPolicy,
+		Fillers:             req.Fillers,
+		FillerDelayMs:       req.FillerDelayMs,
+		SilenceTimeoutSec:   req.SilenceTimeoutSec,
+		SilenceReprompt:     req.SilenceReprompt,
+		SilenceMaxReprompts: req.SilenceMaxReprompts,
+		IdleGoodbye:         req.IdleGoodbye,
+		MaxCallDurationSec:  req.MaxCallDurationSec,
+		DurationWarningSec:  req.DurationWarningSec,
+		DurationWarning:     req.DurationWarning,
+		DurationGoodbye:     req.DurationGoodbye,
+		Dtmf:                req.Dtmf,
+		AmdPolicy:           req.AmdPolicy,
+		VoicemailText:       req.VoicemailText,
+		VoicemailURL:        req.VoicemailURL,
+		StreamingTTS:        req.StreamingTTS,
+		SegmentMinRunes:     req.SegmentMinRunes,
+		SegmentMaxRunes:     req.SegmentMaxRunes,
+		Lexicon:             req.Lexicon,
+		ContextMaxTokens:    req.ContextMaxTokens,
+		ContextStrategy:     req.ContextStrategy,
+		LLMEndpoints:        req.LLMEndpoints,
+		LLMFallbackText:     req.LLMFallbackText,
+	}
+	if err := validateRobot(robot); err != nil {
+		logrus.Errorf("validateRobot error:%v", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	robotRepo := dao.NewRobotRepo(app.DB)
-	if _, err := robotRepo.CreateRobot(&model.Robot{
-		UserID:       req.UserID,
-		Name:         req.Name,
-		Speed:        req.Speed,
-		Volume:       req.Volume,
-		Speaker:      req.Speaker,
-		Emotion:      req.Emotion,
-		SystemPrompt: req.SystemPrompt,
-	}); err != nil {
+	if _, err := robotRepo.CreateRobot(robot); err != nil {
 		logrus.Errorf("CreateRobot error:%v", err)
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -54,3 +139,107 @@ func (app *App) CreateRobot(ctx *gin.Context) {
 		}})
 
 }
+
+// validateRobot 校验机器人的模板、开场白、工具以及发音人和采样率是否被所选服务商支持
+func validateRobot(robot *model.Robot) error {
+	if err := prompt.Validate(robot); err != nil {
+		return err
+	}
+	if err := validateRobotLimits(robot); err != nil {
+		return err
+	}
+	if err := validateGreeting(robot); err != nil {
+		return err
+	}
+	if err := validateTools(robot.Tools); err != nil {
+		return err
+	}
+	if err := validateTransfer(robot); err != nil {
+		return err
+	}
+	if err := validateLLMEndpoints(robot.LLMEndpoints); err != nil {
+		return err
+	}
+	if err := validateFillers(robot.Fillers); err != nil {
+		return err
+	}
+	if err := validateDtmf(robot); err != nil {
+		return err
+	}
+	if robot.AmdPolicy == model.AmdPolicyVoicemail && robot.VoicemailText == "" && robot.VoicemailURL == "" {
+		return errors.New("voicemail_text or voicemail_url is required when amd_policy is voicemail")
+	}
+	if robot.MaxCallDurationSec > 0 && robot.DurationWarningSec >= robot.MaxCallDurationSec {
+		return fmt.Errorf("duration_warning_sec %d must be less than max_call_duration_sec %d", robot.DurationWarningSec, robot.MaxCallDurationSec)
+	}
+	if robot.SegmentMaxRunes > 0 && robot.SegmentMaxRunes < robot.SegmentMinRunes {
+		return fmt.Errorf("segment_max_runes %d is less than segment_min_runes %d", robot.SegmentMaxRunes, robot.SegmentMinRunes)
+	}
+	return provider.ValidateRobot(robot)
+}
+
+// validateRobotLimits 校验枚举字段和数值范围，与创建、修改请求的binding规则一致，导入的配置同样受限
+func validateRobotLimits(robot *model.Robot) error {
+	if robot.Speed != 0 && (robot.Speed < 0.5 || robot.Speed > 2.0) {
+		return fmt.Errorf("speed %v out of range [0.5, 2.0]", robot.Speed)
+	}
+	enums := []struct {
+		name    string
+		value   string
+		allowed []string
+	}{
+		{"turn_policy", robot.TurnPolicy, []string{model.TurnPolicyQueue, model.TurnPolicyReplace, model.TurnPolicyIgnore}},
+		{"context_strategy", robot.ContextStrategy, []string{handler.ContextStrategyTruncate, handler.ContextStrategySummarize}},
+		{"amd_policy", robot.AmdPolicy, []string{model.AmdPolicyHangup, model.AmdPolicyVoicemail, model.AmdPolicyContinue}},
+		{"greeting_type", robot.GreetingType, []string{model.GreetingTypeText, model.GreetingTypeAudio, model.GreetingTypeLLM}},
+	}
+	for _, enum := range enums {
+		if enum.value != "" && !slices.Contains(enum.allowed, enum.value) {
+			return fmt.Errorf("%s must be one of %s", enum.name, strings.Join(enum.allowed, "|"))
+		}
+	}
+	lengths := []struct {
+		name  string
+		value string
+		max   int
+	}{
+		{"speaker", robot.Speaker, 50},
+		{"tts_provider", robot.TTSProvider, 100},
+		{"greeting_url", robot.GreetingURL, 255},
+		{"timezone", robot.Timezone, 64},
+		{"eou_type", robot.EouType, 50},
+		{"voicemail_url", robot.VoicemailURL, 255},
+	}
+	for _, length := range lengths {
+		if len(length.value) > length.max {
+			return fmt.Errorf("%s exceeds %d characters", length.name, length.max)
+		}
+	}
+	// max为0表示只限制下限
+	ranges := []struct {
+		name  string
+		value int
+		max   int
+	}{
+		{"volume", robot.Volume, 10},
+		{"barge_in_min_ms", robot.BargeInMinMs, 5000},
+		{"eou_window_ms", robot.EouWindowMs, 5000},
+		{"filler_delay_ms", robot.FillerDelayMs, 10000},
+		{"silence_timeout_sec", robot.SilenceTimeoutSec, 300},
+		{"silence_max_reprompts", robot.SilenceMaxReprompts, 10},
+		{"segment_min_runes", robot.SegmentMinRunes, 200},
+		{"segment_max_runes", robot.SegmentMaxRunes, 500},
+		{"max_call_duration_sec", robot.MaxCallDurationSec, 0},
+		{"duration_warning_sec", robot.DurationWarningSec, 0},
+		{"context_max_tokens", robot.ContextMaxTokens, 0},
+	}
+	for _, limit := range ranges {
+		if limit.value < 0 || (limit.max > 0 && limit.value > limit.max) {
+			if limit.max == 0 {
+				return fmt.Errorf("%s must not be negative", limit.name)
+			}
+			return fmt.Errorf("%s %d out of range [0, %d]", limit.name, limit.value, limit.max)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+	"miniRustpbxgo/internal/dao"
+	"miniRustpbxgo/internal/model"
+	"net/http"
+	"time"
+)
+
+const (
+	bundleFormatJSON = "json"
+	bundleFormatYAML = "yaml"
+)
+
+type RobotExportReq struct {
+	RobotId uint   `form:"robot_id" binding:"required"`                // 机器人ID（必填）
+	UserID  uint   `form:"user_id" binding:"required"`                 // 机器人所属用户ID（必填）
+	Format  string `form:"format" binding:"omitempty,oneof=json yaml"` // 导出格式（可选，默认json）
+}
+
+// ExportRobot 将机器人配置导出为带版本号的JSON/YAML文档，不包含任何密钥
+func (app *App) ExportRobot(ctx *gin.Context) {
+	var req RobotExportReq
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		logrus.Errorf("RobotExportReq error:%v", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	robotRepo := dao.NewRobotRepo(app.DB)
+	robot, err := robotRepo.GetRobotByID(req.RobotId)
+	if err != nil {
+		logrus.Errorf("ExportRobot GetRobotByID error:%v", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if robot.UserID != req.UserID {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "robot does not belong to user"})
+		return
+	}
+	bundle := &model.RobotBundle{
+		Version:    model.RobotBundleVersion,
+		ExportedAt: time.Now(),
+		Robot:      model.NewRobotSpec(robot),
+	}
+	format := req.Format
+	if format == "" {
+		format = bundleFormatJSON
+	}
+	data, err := encodeRobotBundle(bundle, format)
+	if err != nil {
+		logrus.Errorf("ExportRobot encodeRobotBundle error:%v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	contentType := "application/json"
+	if format == bundleFormatYAML {
+		contentType = "application/yaml"
+	}
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="robot-%d.%s"`, robot.ID, format))
+	ctx.Data(http.StatusOK, contentType, data)
+}
+
+func encodeRobotBundle(bundle *model.RobotBundle, format string) ([]byte, error) {
+	if format == bundleFormatYAML {
+		return yaml.Marshal(bundle)
+	}
+	return json.MarshalIndent(bundle, "", "  ")
+}
+
+func decodeRobotBundle(data []byte, format string) (*model.RobotBundle, error) {
+	var bundle model.RobotBundle
+	var err error
+	if format == bundleFormatYAML {
+		err = yaml.Unmarshal(data, &bundle)
+	} else {
+		err = json.Unmarshal(data, &bundle)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decode robot bundle error: %w", err)
+	}
+	if bundle.Version < 1 || bundle.Version > model.RobotBundleVersion {
+		return nil, fmt.Errorf("unsupported robot bundle version: %d", bundle.Version)
+	}
+	if bundle.Robot.Name == "" {
+		return nil, fmt.Errorf("robot name is required")
+	}
+	return &bundle, nil
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"maps"
+	"miniRustpbxgo/internal/dao"
+	"miniRustpbxgo/internal/handler"
+	"miniRustpbxgo/internal/model"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// 导入时同名机器人的冲突处理方式
+const (
+	ConflictNew       = "new"       // 仅新建，存在同名机器人时报错
+	ConflictOverwrite = "overwrite" // 覆盖同名机器人的配置
+	ConflictRename    = "rename"    // 自动重命名后新建
+)
+
+type RobotImportReq struct {
+	UserID   uint   `form:"user_id" binding:"required"`                              // 导入到的用户ID（必填）
+	Conflict string `form:"conflict" binding:"omitempty,oneof=new overwrite rename"` // 冲突处理方式（可选，默认new）
+	Format   string `form:"format" binding:"omitempty,oneof=json yaml"`              // 文档格式（可选，默认按Content-Type判断）
+}
+
+// ImportRobot 从导出的JSON/YAML文档导入机器人配置
+func (app *App) ImportRobot(ctx *gin.Context) {
+	var req RobotImportReq
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		logrus.Errorf("RobotImportReq error:%v", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	data, err := ctx.GetRawData()
+	if err != nil {
+		logrus.Errorf("ImportRobot GetRawData error:%v", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	format := req.Format
+	if format == "" {
+		format = bundleFormatJSON
+		if strings.Contains(ctx.ContentType(), "yaml") {
+			format = bundleFormatYAML
+		}
+	}
+	bundle, err := decodeRobotBundle(data, format)
+	if err != nil {
+		logrus.Errorf("ImportRobot decodeRobotBundle error:%v", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	robotRepo := dao.NewRobotRepo(app.DB)
+	existing, err := robotRepo.GetRobotByUserIDAndName(req.UserID, bundle.Robot.Name)
+	if err != nil {
+		logrus.Errorf("ImportRobot GetRobotByUserIDAndName error:%v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	robot := &model.Robot{UserID: req.UserID}
+	var previous model.Robot
+	if existing != nil {
+		switch req.Conflict {
+		case ConflictOverwrite:
+			previous = *existing
+			robot = existing
+		case ConflictRename:
+			name, err := uniqueRobotName(robotRepo, req.UserID, bundle.Robot.Name)
+			if err != nil {
+				logrus.Errorf("ImportRobot uniqueRobotName error:%v", err)
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			bundle.Robot.Name = name
+		default:
+			ctx.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("robot %s already exists", bundle.Robot.Name)})
+			return
+		}
+	}
+	bundle.Robot.ApplyTo(robot)
+	// 新建和重命名时没有可沿用的配置，被清空的密钥必须在文件中填写
+	if err := keepImportSecrets(robot, &previous); err != nil {
+		logrus.Errorf("keepImportSecrets error:%v", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateRobot(robot); err != nil {
+		logrus.Errorf("validateRobot error:%v", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if robot.ID != 0 {
+		err = robotRepo.UpdateRobot(robot)
+	} else {
+		_, err = robotRepo.CreateRobot(robot)
+	}
+	if err != nil {
+		logrus.Errorf("ImportRobot save robot error:%v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200,
+		"message": "ok",
+		"data": &RobotCreateRsp{
+			Id:   robot.ID,
+			Name: robot.Name,
+		}})
+}
+
+// keepImportSecrets 覆盖导入时，导出文件中被清空的API密钥和webhook请求头沿用被覆盖机器人的配置，
+// 备用节点按提供商和地址匹配、工具按名称匹配；previous为空（新建或重命名）或匹配不到时，
+// 需要密钥的节点和空的请求头要求在文件中填写
+func keepImportSecrets(robot *model.Robot, previous *model.Robot) error {
+	for i := range robot.LLMEndpoints {
+		endpoint := &robot.LLMEndpoints[i]
+		if endpoint.APIKey != "" {
+			continue
+		}
+		for _, old := range previous.LLMEndpoints {
+			if strings.EqualFold(old.Provider, endpoint.Provider) && old.APIURL == endpoint.APIURL && old.APIKey != "" {
+				endpoint.APIKey = old.APIKey
+				break
+			}
+		}
+		if endpoint.APIKey == "" && !keylessLLMProvider(endpoint.Provider) {
+			return fmt.Errorf("llm endpoint %d: api_key is required", i)
+		}
+	}
+	for i := range robot.Tools {
+		tool := &robot.Tools[i]
+		for _, old := range previous.Tools {
+			if old.Name != tool.Name {
+				continue
+			}
+			for name, value := range tool.Headers {
+				if value == "" && old.Headers[name] != "" {
+					tool.Headers[name] = old.Headers[name]
+				}
+			}
+			break
+		}
+		for _, name := range slices.Sorted(maps.Keys(tool.Headers)) {
+			if tool.Headers[name] == "" {
+				return fmt.Errorf("tool %s: header %s is required", tool.Name, name)
+			}
+		}
+	}
+	return nil
+}
+
+// keylessLLMProvider 不需要API密钥的大模型提供商
+func keylessLLMProvider(provider string) bool {
+	switch strings.ToLower(provider) {
+	case handler.LLMProviderOllama:
+		return true
+	}
+	return false
+}
+
+// uniqueRobotName 为同名机器人生成不冲突的名称，如 name (2)
+func uniqueRobotName(robotRepo *dao.RobotRepo, userID uint, name string) (string, error) {
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s (%d)", name, i)
+		existing, err := robotRepo.GetRobotByUserIDAndName(userID, candidate)
+		if err != nil {
+			return "", err
+		}
+		if existing == nil {
+			return candidate, nil
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"miniRustpbxgo/internal/model"
+)
+
+func TestKeepImportSecrets(t *testing.T) {
+	previous := model.Robot{
+		LLMEndpoints: []model.RobotLLMEndpoint{{Provider: "openai", APIURL: "https://api.example.com/v1", APIKey: "sk-old"}},
+		Tools: []model.RobotTool{
+			{Name: "lookup_order", Type: model.ToolTypeWebhook, Headers: map[string]string{"Authorization": "Bearer old"}},
+		},
+	}
+	tests := []struct {
+		name     string
+		robot    model.Robot
+		previous model.Robot
+		// want is the robot after the secrets were kept, wantErr is part of the error
+		want    model.Robot
+		wantErr string
+	}{
+		{
+			name:     "overwrite keeps the secrets",
+			robot:    model.Robot{LLMEndpoints: []model.RobotLLMEndpoint{{Provider: "OpenAI", APIURL: "https://api.example.com/v1"}}, Tools: []model.RobotTool{{Name: "lookup_order", Headers: map[string]string{"Authorization": ""}}}},
+			previous: previous,
+			want:     model.Robot{LLMEndpoints: []model.RobotLLMEndpoint{{Provider: "OpenAI", APIURL: "https://api.example.com/v1", APIKey: "sk-old"}}, Tools: []model.RobotTool{{Name: "lookup_order", Headers: map[string]string{"Authorization": "Bearer old"}}}},
+		},
+		{
+			name:     "overwrite with another endpoint needs its key",
+			robot:    model.Robot{LLMEndpoints: []model.RobotLLMEndpoint{{Provider: "openai", APIURL: "https://other.example.com/v1"}}},
+			previous: previous,
+			wantErr:  "llm endpoint 0: api_key is required",
+		},
+		{
+			name:    "new robot needs its keys",
+			robot:   model.Robot{LLMEndpoints: []model.RobotLLMEndpoint{{Provider: "openai", APIURL: "https://api.example.com/v1"}}},
+			wantErr: "llm endpoint 0: api_key is required",
+		},
+		{
+			name:    "new robot needs its headers",
+			robot:   model.Robot{Tools: []model.RobotTool{{Name: "lookup_order", Headers: map[string]string{"X-Token": "", "Accept": "application/json"}}}},
+			wantErr: "tool lookup_order: header X-Token is required",
+		},
+		{
+			name:  "keyless provider and filled secrets",
+			robot: model.Robot{LLMEndpoints: []model.RobotLLMEndpoint{{Provider: "ollama"}, {Provider: "openai", APIKey: "sk-new"}}, Tools: []model.RobotTool{{Name: "lookup_order", Headers: map[string]string{"X-Token": "t"}}}},
+			want:  model.Robot{LLMEndpoints: []model.RobotLLMEndpoint{{Provider: "ollama"}, {Provider: "openai", APIKey: "sk-new"}}, Tools: []model.RobotTool{{Name: "lookup_order", Headers: map[string]string{"X-Token": "t"}}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			robot := tt.robot
+			err := keepImportSecrets(&robot, &tt.previous)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(robot, tt.want) {
+				t.Errorf("robot = %+v, want %+v", robot, tt.want)
+			}
+		})
+	}
+}
@@ -4,26 +4,29 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 	"miniRustpbxgo/internal/dao"
+	"miniRustpbxgo/internal/handler"
 	"miniRustpbxgo/internal/model"
+	"miniRustpbxgo/internal/provider"
 	"miniRustpbxgo/internal/utils"
 	"net/http"
 )
 
 type RobotKeyCreateReq struct {
-	UserID       uint   `json:"user_id" binding:"required"`                          // 关联用户ID（必填）
-	Name         string `json:"name" binding:"omitempty,max=100"`                    // 密钥名称（可选，最长100字符）
-	LLMProvider  string `json:"llm_provider" binding:"omitempty,max=100,required"`   // 大模型提供商（可选，最长100字符）
-	LLMApiKey    string `json:"llm_api_key" binding:"omitempty,max=255,required"`    // 大模型API密钥（可选，最长255字符）
-	LLMApiUrl    string `json:"llm_api_url" binding:"omitempty,max=255,required"`    // 大模型API地址（可选，最长255字符）
-	ASRProvider  string `json:"asr_provider" binding:"omitempty,max=100,required"`   // 语音识别提供商（可选，最长100字符）
-	ASRAppID     string `json:"asr_app_id" binding:"omitempty,max=100,required"`     // 语音识别AppID（可选，最长100字符）
-	ASRSecretID  string `json:"asr_secret_id" binding:"omitempty,max=255,required"`  // 语音识别SecretID（可选，最长255字符）
-	ASRSecretKey string `json:"asr_secret_key" binding:"omitempty,max=255,required"` // 语音识别SecretKey（可选，最长255字符）
-	ASRLanguage  string `json:"asr_language" binding:"omitempty,required"`           // 语音识别语言（可选，仅支持指定值）
-	TTProvider   string `json:"tts_provider" binding:"omitempty,max=100,required"`   // 语音合成提供商（可选，最长100字符）
-	TTSAppID     string `json:"tts_app_id" binding:"omitempty,max=100,required"`     // 语音合成AppID（可选，最长100字符）
-	TTSSecretID  string `json:"tts_secret_id" binding:"omitempty,max=255,required"`  // 语音合成SecretID（可选，最长255字符）
-	TTSSecretKey string `json:"tts_secret_key" binding:"omitempty,max=255,required"` // 语音合成SecretKey（可选，最长255字符）
+	UserID             uint   `json:"user_id" binding:"required"`                          // 关联用户ID（必填）
+	Name               string `json:"name" binding:"omitempty,max=100"`                    // 密钥名称（可选，最长100字符）
+	LLMProvider        string `json:"llm_provider" binding:"omitempty,max=100,required"`   // 大模型提供商（openai|dashscope|ollama，其他值按OpenAI兼容接口处理）
+	LLMApiKey          string `json:"llm_api_key" binding:"omitempty,max=255,required"`    // 大模型API密钥（可选，最长255字符）
+	LLMApiUrl          string `json:"llm_api_url" binding:"omitempty,max=255,required"`    // 大模型API地址（可选，最长255字符）
+	ASRProvider        string `json:"asr_provider" binding:"omitempty,max=100,required"`   // 语音识别提供商（可选，最长100字符）
+	ASRAppID           string `json:"asr_app_id" binding:"omitempty,max=100,required"`     // 语音识别AppID（可选，最长100字符）
+	ASRSecretID        string `json:"asr_secret_id" binding:"omitempty,max=255,required"`  // 语音识别SecretID（可选，最长255字符）
+	ASRSecretKey       string `json:"asr_secret_key" binding:"omitempty,max=255,required"` // 语音识别SecretKey（可选，最长255字符）
+	ASRLanguage        string `json:"asr_language" binding:"omitempty,required"`           // 语音识别语言（可选，仅支持指定值）
+	TTProvider         string `json:"tts_provider" binding:"omitempty,max=100,required"`   // 语音合成提供商（可选，最长100字符）
+	TTSAppID           string `json:"tts_app_id" binding:"omitempty,max=100,required"`     // 语音合成AppID（可选，最长100字符）
+	TTSSecretID        string `json:"tts_secret_id" binding:"omitempty,max=255,required"`  // 语音合成SecretID（可选，最长255字符）
+	TTSSecretKey       string `json:"tts_secret_key" binding:"omitempty,max=255,required"` // 语音合成SecretKey（可选，最长255字符）
+	MaxCallDurationSec int    `json:"max_call_duration_sec" binding:"omitempty,min=0"`     // 通话最长秒数（可选，为0不限制）
 }
 
 type RobotKeyCreateRsp struct {
@@ -44,6 +47,34 @@ func (app *App) CreateRobotKey(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	robotKey := &model.RobotKey{
+		UserID:             req.UserID,
+		Name:               req.Name,
+		LLMProvider:        req.LLMProvider,
+		LLMApiKey:          req.LLMApiKey,
+		LLMApiUrl:          req.LLMApiUrl,
+		ASRProvider:        req.ASRProvider,
+		ASRAppID:           req.ASRAppID,
+		ASRSecretID:        req.ASRSecretID,
+		ASRSecretKey:       req.ASRSecretKey,
+		ASRLanguage:        req.ASRLanguage,
+		TTSProvider:        req.TTProvider,
+		TTSAppID:           req.TTSAppID,
+		TTSSecretID:        req.TTSSecretID,
+		TTSSecretKey:       req.TTSSecretKey,
+		MaxCallDurationSec: req.MaxCallDurationSec,
+	}
+	// 按所选服务商校验凭证字段和识别语言
+	if err := provider.ValidateRobotKey(robotKey); err != nil {
+		logrus.Errorf("ValidateRobotKey error:%v", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := handler.NewLLMProvider(robotKey.LLMProvider, robotKey.LLMApiKey, robotKey.LLMApiUrl); err != nil {
+		logrus.Errorf("NewLLMProvider error:%v", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "llm_provider: " + err.Error()})
+		return
+	}
 	robotKeyRepo := dao.NewRobotKeyRepo(app.DB)
 	robotApiKey, err := utils.GenerateSecureRandomString(25)
 	if err != nil {
@@ -57,24 +88,9 @@ func (app *App) CreateRobotKey(ctx *gin.Context) {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	if _, err := robotKeyRepo.CreateRobotKey(&model.RobotKey{
-		UserID:       req.UserID,
-		Name:         req.Name,
-		LLMProvider:  req.LLMProvider,
-		LLMApiKey:    req.LLMApiKey,
-		LLMApiUrl:    req.LLMApiUrl,
-		ASRProvider:  req.ASRProvider,
-		ASRAppID:     req.ASRAppID,
-		ASRSecretID:  req.ASRSecretID,
-		ASRSecretKey: req.ASRSecretKey,
-		ASRLanguage:  req.ASRLanguage,
-		TTSProvider:  req.TTProvider,
-		TTSAppID:     req.TTSAppID,
-		TTSSecretID:  req.TTSSecretID,
-		TTSSecretKey: req.TTSSecretKey,
-		APIKey:       robotApiKey,
-		APISecret:    robotApiSecret,
-	}); err != nil {
+	robotKey.APIKey = robotApiKey
+	robotKey.APISecret = robotApiSecret
+	if _, err := robotKeyRepo.CreateRobotKey(robotKey); err != nil {
 		logrus.Errorf("CreateRobotKey error:%v", err)
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
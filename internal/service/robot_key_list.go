@@ -6,47 +6,55 @@ import (
 	"miniRustpbxgo/internal/dao"
 	"miniRustpbxgo/internal/model"
 	"net/http"
+	"time"
 )
 
 type RobotKeyListReq struct {
-	UserID uint `json:"user_id" binding:"required"` // 关联用户ID（必填）
+	ListReq
+	UserID uint `form:"user_id" binding:"required"` // 关联用户ID（必填）
 }
 
-type RobotKeyListRsp struct {
-	RobotKeyList []RobotKeyCreateRsp `json:"robot_key_list"`
-	Count        int64               `json:"count"`
+type RobotKeyListItem struct {
+	Id                 uint      `json:"id"`
+	Name               string    `json:"name"`
+	APIKey             string    `json:"api_key"`
+	APISecret          string    `json:"api_secret"`
+	LLMProvider        string    `json:"llm_provider"`
+	ASRProvider        string    `json:"asr_provider"`
+	TTSProvider        string    `json:"tts_provider"`
+	MaxCallDurationSec int       `json:"max_call_duration_sec"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
 }
 
 func (app *App) RobotKeyList(c *gin.Context) {
-	var (
-		req                RobotKeyListReq
-		robotKeyCreateList []RobotKeyCreateRsp
-		robotKeyList       []model.RobotKey
-		count              int64
-	)
-	if err := c.BindJSON(&req); err != nil {
-		logrus.Error("RobotKeyList bind json failed: ", err)
+	var req RobotKeyListReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		logrus.Error("RobotKeyList bind query failed: ", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 	robotKeyRepo := dao.NewRobotKeyRepo(app.DB)
-	robotKeyList, count, err := robotKeyRepo.ListRobotKeysByUserID(req.UserID, 1, 10)
+	result, err := robotKeyRepo.ListRobotKeys(req.UserID, req.toQuery())
 	if err != nil {
-		logrus.Error("ListRobotKeysByUserID failed: ", err)
+		logrus.Error("ListRobotKeys failed: ", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	for i := range robotKeyList {
-		robotKeyCreateList = append(robotKeyCreateList, RobotKeyCreateRsp{
-			Name:      robotKeyList[i].Name,
-			APIKey:    robotKeyList[i].APIKey,
-			APISecret: robotKeyList[i].APISecret,
-		})
-	}
 	c.JSON(http.StatusOK, gin.H{"code": 200,
 		"message": "ok",
-		"data": &RobotKeyListRsp{
-			Count:        count,
-			RobotKeyList: robotKeyCreateList,
-		}})
+		"data": newListRsp(&req.ListReq, result, func(robotKey *model.RobotKey) RobotKeyListItem {
+			return RobotKeyListItem{
+				Id:                 robotKey.ID,
+				Name:               robotKey.Name,
+				APIKey:             robotKey.APIKey,
+				APISecret:          robotKey.APISecret,
+				LLMProvider:        robotKey.LLMProvider,
+				ASRProvider:        robotKey.ASRProvider,
+				TTSProvider:        robotKey.TTSProvider,
+				MaxCallDurationSec: robotKey.MaxCallDurationSec,
+				CreatedAt:          robotKey.CreatedAt,
+				UpdatedAt:          robotKey.UpdatedAt,
+			}
+		})})
 }
@@ -6,46 +6,45 @@ import (
 	"miniRustpbxgo/internal/dao"
 	"miniRustpbxgo/internal/model"
 	"net/http"
+	"time"
 )
 
 type RobotListReq struct {
-	UserID uint `json:"user_id" binding:"required"` // 关联用户ID（必填）
+	ListReq
+	UserID uint `form:"user_id" binding:"required"` // 关联用户ID（必填）
 }
 
-type RobotListRsp struct {
-	RobotList []RobotCreateRsp `json:"robot_key_list"`
-	Count     int64            `json:"count"`
+type RobotListItem struct {
+	Id        uint      `json:"id"`
+	Name      string    `json:"name"`
+	Speaker   string    `json:"speaker"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 func (app *App) RobotList(c *gin.Context) {
-	var (
-		req             RobotListReq
-		robotCreateList []RobotCreateRsp
-		robotList       []model.Robot
-		count           int64
-	)
-	if err := c.BindJSON(&req); err != nil {
-		logrus.Error("RobotList bind json failed: ", err)
+	var req RobotListReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		logrus.Error("RobotList bind query failed: ", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 	robotRepo := dao.NewRobotRepo(app.DB)
-	robotList, count, err := robotRepo.ListRobotsByUserID(req.UserID, 1, 10)
+	result, err := robotRepo.ListRobots(req.UserID, req.toQuery())
 	if err != nil {
-		logrus.Error("ListRobotsByUserID failed: ", err)
+		logrus.Error("ListRobots failed: ", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	for i := range robotList {
-		robotCreateList = append(robotCreateList, RobotCreateRsp{
-			Name: robotList[i].Name,
-			Id:   robotList[i].ID,
-		})
-	}
 	c.JSON(http.StatusOK, gin.H{"code": 200,
 		"message": "ok",
-		"data": &RobotListRsp{
-			Count:     count,
-			RobotList: robotCreateList,
-		}})
+		"data": newListRsp(&req.ListReq, result, func(robot *model.Robot) RobotListItem {
+			return RobotListItem{
+				Id:        robot.ID,
+				Name:      robot.Name,
+				Speaker:   robot.Speaker,
+				CreatedAt: robot.CreatedAt,
+				UpdatedAt: robot.UpdatedAt,
+			}
+		})})
 }
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"miniRustpbxgo/internal/dao"
+	"miniRustpbxgo/internal/prompt"
+	"net/http"
+	"time"
+)
+
+type RobotPromptPreviewReq struct {
+	RobotId   uint              `json:"robot_id" binding:"required"`   // 机器人ID（必填）
+	UserID    uint              `json:"user_id" binding:"required"`    // 机器人所属用户ID（必填）
+	Variables map[string]string `json:"variables" binding:"omitempty"` // 自定义变量（可选）
+	CallerID  string            `json:"caller_id" binding:"omitempty"` // 模拟主叫号码（可选）
+}
+
+type RobotPromptPreviewRsp struct {
+	SystemPrompt string `json:"system_prompt"`
+	Greeting     string `json:"greeting"`
+}
+
+// PreviewRobotPrompt 按给定变量渲染机器人的系统提示词和开场白
+func (app *App) PreviewRobotPrompt(ctx *gin.Context) {
+	var req RobotPromptPreviewReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		logrus.Errorf("RobotPromptPreviewReq error:%v", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	robotRepo := dao.NewRobotRepo(app.DB)
+	robot, err := robotRepo.GetRobotByID(req.RobotId)
+	if err != nil {
+		logrus.Errorf("PreviewRobotPrompt GetRobotByID error:%v", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if robot.UserID != req.UserID {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "robot does not belong to user"})
+		return
+	}
+	systemPrompt, greeting, err := prompt.RenderRobot(robot, prompt.CallInfo{
+		Variables: req.Variables,
+		CallerID:  req.CallerID,
+		Now:       time.Now(),
+	})
+	if err != nil {
+		logrus.Errorf("PreviewRobotPrompt RenderRobot error:%v", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200,
+		"message": "ok",
+		"data": &RobotPromptPreviewRsp{
+			SystemPrompt: systemPrompt,
+			Greeting:     greeting,
+		}})
+}
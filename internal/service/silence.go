@@ -0,0 +1,149 @@
+package service
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+const (
+	// defaultSilenceReprompt 未配置追问话术时使用
+	defaultSilenceReprompt = "您好，请问您还在吗？"
+	// defaultIdleGoodbye 未配置告别语时使用
+	defaultIdleGoodbye = "您好像不方便说话，那我先挂断了，再见。"
+	// defaultSilenceMaxReprompts 未配置时最多追问的次数
+	defaultSilenceMaxReprompts = 2
+	// idleHangupReason 用户一直沉默时的挂断原因
+	idleHangupReason = "idle"
+)
+
+// armSilenceTimer 机器人说完话后开始计算用户的沉默时间，机器人正在说话或生成回复时不计时
+func (backendForWeb *BackendForWeb) armSilenceTimer() {
+	robot := backendForWeb.Robot
+	if robot == nil || robot.SilenceTimeoutSec <= 0 {
+		return
+	}
+	if backendForWeb.isPlaying() || backendForWeb.turnPending() || backendForWeb.isTransferActive() {
+		return
+	}
+	backendForWeb.silenceMutex.Lock()
+	defer backendForWeb.silenceMutex.Unlock()
+	if backendForWeb.silenceStopped {
+		return
+	}
+	if backendForWeb.silenceTimer != nil {
+		backendForWeb.silenceTimer.Stop()
+	}
+	backendForWeb.silenceTimer = time.AfterFunc(time.Duration(robot.SilenceTimeoutSec)*time.Second, backendForWeb.solveSilenceTimeout)
+}
+
+// stopSilenceTimer 机器人开始说话或生成回复时停止计时
+func (backendForWeb *BackendForWeb) stopSilenceTimer() {
+	backendForWeb.silenceMutex.Lock()
+	defer backendForWeb.silenceMutex.Unlock()
+	if backendForWeb.silenceTimer != nil {
+		backendForWeb.silenceTimer.Stop()
+		backendForWeb.silenceTimer = nil
+	}
+}
+
+// SolveCallerActivity 收到用户的识别结果时停止计时，并重新计算追问次数
+func (backendForWeb *BackendForWeb) SolveCallerActivity() {
+	backendForWeb.silenceMutex.Lock()
+	defer backendForWeb.silenceMutex.Unlock()
+	if backendForWeb.silenceTimer != nil {
+		backendForWeb.silenceTimer.Stop()
+		backendForWeb.silenceTimer = nil
+	}
+	backendForWeb.reprompts = 0
+}
+
+// solveSilenceTimeout 用户沉默超时，追问次数未用完时追问，否则说告别语并挂断
+func (backendForWeb *BackendForWeb) solveSilenceTimeout() {
+	robot := backendForWeb.currentRobot()
+	if robot == nil {
+		return
+	}
+	maxReprompts := defaultSilenceMaxReprompts
+	if robot.SilenceMaxReprompts > 0 {
+		maxReprompts = robot.SilenceMaxReprompts
+	}
+	backendForWeb.silenceMutex.Lock()
+	backendForWeb.silenceTimer = nil
+	if backendForWeb.silenceStopped {
+		backendForWeb.silenceMutex.Unlock()
+		return
+	}
+	reprompt := backendForWeb.reprompts < maxReprompts
+	if reprompt {
+		backendForWeb.reprompts++
+	} else {
+		backendForWeb.silenceStopped = true
+	}
+	count := backendForWeb.reprompts
+	backendForWeb.silenceMutex.Unlock()
+
+	if reprompt {
+		text := robot.SilenceReprompt
+		if text == "" {
+			text = defaultSilenceReprompt
+		}
+		logrus.WithField("reprompts", count).Info("caller is silent, reprompt")
+		backendForWeb.speakPrompt(text, "reprompt")
+		return
+	}
+	text := robot.IdleGoodbye
+	if text == "" {
+		text = defaultIdleGoodbye
+	}
+	logrus.WithField("reprompts", count).Info("caller stays silent, hang up")
+	playID := backendForWeb.speakPrompt(text, "idle")
+	if playID == "" {
+		backendForWeb.hangupWithReason(idleHangupReason)
+		return
+	}
+	backendForWeb.scheduleHangup(playID, idleHangupReason)
+}
+
+// speakPrompt 播报机器人的固定话术并写入大模型历史，返回播报的playID，发送失败时返回空
+func (backendForWeb *BackendForWeb) speakPrompt(text, kind string) string {
+	playID := fmt.Sprintf("%s-%s", kind, uuid.New().String())
+	speech := text
+	if normalizer := backendForWeb.currentNormalizer(); normalizer != nil {
+		speech = normalizer.Normalize(speech)
+	}
+	if err := backendForWeb.SendTTSCommandForRustBackend(speech, playID, false, nil); err != nil {
+		logrus.Errorf("speak %s prompt error:%v", kind, err)
+		return ""
+	}
+	backendForWeb.currentLLM().AddAssistantMessage(text)
+	backendForWeb.ForwardToWebConn(&Event{
+		Event: "LLMResult",
+		Text:  text,
+	})
+	return playID
+}
+
+// endSilence 通话结束后不再计时
+func (backendForWeb *BackendForWeb) endSilence() {
+	backendForWeb.silenceMutex.Lock()
+	defer backendForWeb.silenceMutex.Unlock()
+	if backendForWeb.silenceTimer != nil {
+		backendForWeb.silenceTimer.Stop()
+		backendForWeb.silenceTimer = nil
+	}
+	backendForWeb.silenceStopped = true
+}
+
+// resetSilence 新通话开始时清理沉默计时和追问次数
+func (backendForWeb *BackendForWeb) resetSilence() {
+	backendForWeb.silenceMutex.Lock()
+	defer backendForWeb.silenceMutex.Unlock()
+	if backendForWeb.silenceTimer != nil {
+		backendForWeb.silenceTimer.Stop()
+		backendForWeb.silenceTimer = nil
+	}
+	backendForWeb.reprompts = 0
+	backendForWeb.silenceStopped = false
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+	"sync"
+)
+
+// sipCallRegistry 进行中的SIP外呼和呼入，每通电话使用单独的会话和rust连接
+type sipCallRegistry struct {
+	mutex sync.Mutex
+	calls map[string]*BackendForWeb
+}
+
+func (registry *sipCallRegistry) add(callID string, backendForWeb *BackendForWeb) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	if registry.calls == nil {
+		registry.calls = make(map[string]*BackendForWeb)
+	}
+	registry.calls[callID] = backendForWeb
+}
+
+func (registry *sipCallRegistry) get(callID string) *BackendForWeb {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	return registry.calls[callID]
+}
+
+func (registry *sipCallRegistry) remove(callID string) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	delete(registry.calls, callID)
+}
+
+// runSipCall 将rust的SIP通话连接交给会话处理，连接断开后结束通话记录并移出进行中的通话
+func (app *App) runSipCall(backendForRust *BackendForRust, backendForWeb *BackendForWeb) {
+	conn := backendForRust.GoToRustConn
+	callID := backendForWeb.callRecordSnapshot().CallID
+	backendForWeb.GoToRustConn = conn
+	backendForWeb.onCallEnded = func() {
+		backendForWeb.rustWriteMutex.Lock()
+		defer backendForWeb.rustWriteMutex.Unlock()
+		if err := conn.Close(); err != nil {
+			logrus.Error("sip call close rust connection error: ", err)
+		}
+	}
+	app.sipCalls.add(callID, backendForWeb)
+	go func() {
+		backendForRust.ListenGoToRustWs(backendForWeb)
+		// rust连接意外断开时通话也随之结束
+		backendForWeb.finishCallRecord("connection closed", "system")
+		backendForWeb.endSilence()
+		backendForWeb.stopCallLimit()
+		app.sipCalls.remove(callID)
+		if webConn := backendForWeb.detachWebConn(nil); webConn != nil {
+			_ = webConn.Close()
+		}
+		logrus.WithField("callID", callID).Info("sip call finished")
+	}()
+}
+
+// SolveCallFailed 通话接通前被拒绝或出错，记录为未接通并结束通话
+func (backendForWeb *BackendForWeb) SolveCallFailed(reason string) {
+	record := backendForWeb.callRecordSnapshot()
+	if record == nil || record.AnsweredAt != nil || record.EndedAt != nil {
+		return
+	}
+	backendForWeb.finishCallRecord(reason, "callee")
+	backendForWeb.endCall()
+}
+
+// hasWebConn 是否已有订阅事件的连接
+func (backendForWeb *BackendForWeb) hasWebConn() bool {
+	backendForWeb.webWriteMutex.Lock()
+	defer backendForWeb.webWriteMutex.Unlock()
+	return backendForWeb.WebToGoConn != nil
+}
+
+// attachWebConn 订阅通话事件，已有订阅时返回false
+func (backendForWeb *BackendForWeb) attachWebConn(conn *websocket.Conn) bool {
+	backendForWeb.webWriteMutex.Lock()
+	defer backendForWeb.webWriteMutex.Unlock()
+	if backendForWeb.WebToGoConn != nil {
+		return false
+	}
+	backendForWeb.WebToGoConn = conn
+	return true
+}
+
+// detachWebConn 取消订阅并返回原连接，conn不为空时只取消该连接的订阅
+func (backendForWeb *BackendForWeb) detachWebConn(conn *websocket.Conn) *websocket.Conn {
+	backendForWeb.webWriteMutex.Lock()
+	defer backendForWeb.webWriteMutex.Unlock()
+	current := backendForWeb.WebToGoConn
+	if conn != nil && current != conn {
+		return nil
+	}
+	backendForWeb.WebToGoConn = nil
+	return current
+}
+
+// endCall 通话结束，SIP通话断开与rust的连接
+func (backendForWeb *BackendForWeb) endCall() {
+	if backendForWeb.onCallEnded != nil {
+		backendForWeb.onCallEnded()
+	}
+}
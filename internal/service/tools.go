@@ -0,0 +1,103 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"miniRustpbxgo/internal/handler"
+	"miniRustpbxgo/internal/model"
+	"net/url"
+	"regexp"
+	"time"
+)
+
+// toolNameRegex 大模型要求的工具名称格式
+var toolNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// builtinTools 可供机器人启用的内置工具
+var builtinTools = map[string]func(backendForWeb *BackendForWeb) handler.Tool{
+	handler.HangupToolName: func(backendForWeb *BackendForWeb) handler.Tool {
+		return handler.NewHangupTool(backendForWeb.requestHangup)
+	},
+	handler.TransferToolName: func(backendForWeb *BackendForWeb) handler.Tool {
+		return handler.NewTransferTool(func(reason string) (string, error) {
+			return backendForWeb.StartTransfer("", reason)
+		})
+	},
+	handler.CollectDigitsToolName: func(backendForWeb *BackendForWeb) handler.Tool {
+		return handler.NewCollectDigitsTool(backendForWeb.collectDigits)
+	},
+}
+
+// validateTools 校验机器人的工具配置
+func validateTools(tools []model.RobotTool) error {
+	names := make(map[string]bool, len(tools))
+	for _, tool := range tools {
+		if !toolNameRegex.MatchString(tool.Name) {
+			return fmt.Errorf("invalid tool name: %s", tool.Name)
+		}
+		if names[tool.Name] {
+			return fmt.Errorf("duplicate tool name: %s", tool.Name)
+		}
+		names[tool.Name] = true
+		switch tool.Type {
+		case model.ToolTypeBuiltin:
+			if _, ok := builtinTools[tool.Name]; !ok {
+				return fmt.Errorf("unknown builtin tool: %s", tool.Name)
+			}
+		case model.ToolTypeWebhook:
+			// 内置工具的名称保留，避免webhook工具冒充挂断、转接等内置动作
+			if _, ok := builtinTools[tool.Name]; ok {
+				return fmt.Errorf("tool %s: name is reserved for the builtin tool", tool.Name)
+			}
+			if tool.Description == "" {
+				return fmt.Errorf("tool %s: description is required", tool.Name)
+			}
+			u, err := url.Parse(tool.URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("tool %s: invalid url %s", tool.Name, tool.URL)
+			}
+			if tool.Parameters != nil && tool.Parameters["type"] != "object" {
+				return fmt.Errorf("tool %s: parameters must be a JSON schema of type object", tool.Name)
+			}
+			if tool.TimeoutMs < 0 {
+				return fmt.Errorf("tool %s: timeout_ms must not be negative", tool.Name)
+			}
+		default:
+			return fmt.Errorf("tool %s: unsupported type %s", tool.Name, tool.Type)
+		}
+	}
+	return nil
+}
+
+// buildToolRegistry 根据机器人配置构建本通电话可用的工具，未配置时仅启用hangup
+func (backendForWeb *BackendForWeb) buildToolRegistry(robot *model.Robot) (*handler.ToolRegistry, error) {
+	registry := handler.NewToolRegistry()
+	if len(robot.Tools) == 0 {
+		registry.Register(builtinTools[handler.HangupToolName](backendForWeb))
+		return registry, nil
+	}
+	for _, tool := range robot.Tools {
+		switch tool.Type {
+		case model.ToolTypeBuiltin:
+			factory, ok := builtinTools[tool.Name]
+			if !ok {
+				return nil, fmt.Errorf("unknown builtin tool: %s", tool.Name)
+			}
+			registry.Register(factory(backendForWeb))
+		case model.ToolTypeWebhook:
+			var parameters json.RawMessage
+			if tool.Parameters != nil {
+				raw, err := json.Marshal(tool.Parameters)
+				if err != nil {
+					return nil, fmt.Errorf("tool %s: invalid parameters: %w", tool.Name, err)
+				}
+				parameters = raw
+			}
+			registry.Register(handler.NewWebhookTool(tool.Name, tool.Description, parameters, tool.URL, tool.Method, tool.Headers,
+				time.Duration(tool.TimeoutMs)*time.Millisecond))
+		default:
+			return nil, fmt.Errorf("tool %s: unsupported type %s", tool.Name, tool.Type)
+		}
+	}
+	return registry, nil
+}
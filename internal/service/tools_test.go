@@ -0,0 +1,39 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"miniRustpbxgo/internal/model"
+)
+
+func TestValidateTools(t *testing.T) {
+	webhook := func(name string) model.RobotTool {
+		return model.RobotTool{Name: name, Type: model.ToolTypeWebhook, Description: "look up an order", URL: "https://example.com/orders"}
+	}
+	tests := []struct {
+		name    string
+		tools   []model.RobotTool
+		wantErr string
+	}{
+		{name: "builtin and webhook", tools: []model.RobotTool{{Name: "hangup", Type: model.ToolTypeBuiltin}, webhook("lookup_order")}},
+		{name: "unknown builtin", tools: []model.RobotTool{{Name: "refund", Type: model.ToolTypeBuiltin}}, wantErr: "unknown builtin tool: refund"},
+		{name: "webhook named hangup", tools: []model.RobotTool{webhook("hangup")}, wantErr: "tool hangup: name is reserved"},
+		{name: "webhook named transfer", tools: []model.RobotTool{webhook("transfer")}, wantErr: "name is reserved"},
+		{name: "duplicate names", tools: []model.RobotTool{webhook("lookup_order"), webhook("lookup_order")}, wantErr: "duplicate tool name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateTools(tt.tools)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
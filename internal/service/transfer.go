@@ -0,0 +1,255 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"miniRustpbxgo/internal/handler"
+	"miniRustpbxgo/internal/model"
+	"time"
+)
+
+const (
+	// defaultTransferTimeout 机器人未配置超时时间时等待坐席接听的秒数
+	defaultTransferTimeout = 30
+	// transferGracePeriod rust端超时后回传事件的等待余量
+	transferGracePeriod = 5 * time.Second
+	// transferHandoffTimeout 等待转接话术播放结束的最长时间
+	transferHandoffTimeout = 15 * time.Second
+	// defaultHandoffText 未配置转接话术时播报
+	defaultHandoffText = "好的，正在为您转接人工客服，请稍候。"
+	// defaultFallbackText 未配置转接失败话术时播报
+	defaultFallbackText = "抱歉，人工客服暂时无法接通，我继续为您服务。"
+)
+
+var (
+	errTransferNotConfigured = errors.New("transfer target is not configured for this robot")
+	errTransferInProgress    = errors.New("call is already being transferred")
+)
+
+// transferState 单次转人工的状态
+type transferState struct {
+	target string
+	reason string
+	status string
+	timer  *time.Timer
+}
+
+// validateTransfer 校验转人工配置，启用transfer工具时必须配置转接目标
+func validateTransfer(robot *model.Robot) error {
+	transfer := robot.Transfer
+	if transfer != nil {
+		if transfer.TimeoutSecs < 0 {
+			return errors.New("transfer.timeout_secs must not be negative")
+		}
+		if transfer.HandoffText != "" || transfer.FallbackText != "" || transfer.MusicOnHold != "" || transfer.TimeoutSecs > 0 {
+			if transfer.Target == "" {
+				return errors.New("transfer.target is required")
+			}
+		}
+	}
+	for _, tool := range robot.Tools {
+		if tool.Type == model.ToolTypeBuiltin && tool.Name == handler.TransferToolName && (transfer == nil || transfer.Target == "") {
+			return errors.New("transfer.target is required when the transfer tool is enabled")
+		}
+	}
+	return nil
+}
+
+// StartTransfer 转人工：先播报转接话术，播放结束后向rust发送refer命令，返回播报的转接话术。
+// target为空时使用机器人配置的转接目标，由大模型工具或坐席管理接口触发。
+// 转接话术不写入大模型历史：工具触发时作为工具结果返回给大模型，其他入口由调用方写入
+func (backendForWeb *BackendForWeb) StartTransfer(target, reason string) (string, error) {
+	var config model.RobotTransfer
+	if backendForWeb.Robot != nil && backendForWeb.Robot.Transfer != nil {
+		config = *backendForWeb.Robot.Transfer
+	}
+	if target == "" {
+		target = config.Target
+	}
+	if target == "" {
+		return "", errTransferNotConfigured
+	}
+	if backendForWeb.GoToRustConn == nil {
+		return "", errors.New("call is not connected")
+	}
+
+	state := &transferState{target: target, reason: reason, status: model.TransferStatusTransferring}
+	backendForWeb.transferMutex.Lock()
+	if current := backendForWeb.transfer; current != nil && current.status != model.TransferStatusFailed {
+		backendForWeb.transferMutex.Unlock()
+		return "", errTransferInProgress
+	}
+	backendForWeb.transfer = state
+	backendForWeb.transferMutex.Unlock()
+
+	logrus.WithFields(logrus.Fields{
+		"target": target,
+		"reason": reason,
+	}).Info("start transfer")
+	backendForWeb.updateCallRecord(func(record *model.CallRecord) map[string]interface{} {
+		record.TransferTarget = target
+		record.TransferStatus = state.status
+		return map[string]interface{}{
+			"transfer_target": record.TransferTarget,
+			"transfer_status": record.TransferStatus,
+		}
+	})
+	backendForWeb.ForwardToWebConn(&Event{
+		Event:  "transferring",
+		Text:   target,
+		Reason: reason,
+	})
+
+	handoff := config.HandoffText
+	if handoff == "" {
+		handoff = defaultHandoffText
+	}
+	playID := fmt.Sprintf("transfer-%s", uuid.New().String())
+	if err := backendForWeb.SendTTSCommandForRustBackend(handoff, playID, false, nil); err != nil {
+		logrus.Errorf("send transfer handoff error:%v", err)
+		// 工具触发时调用方持有大模型的锁，refer失败写入回退话术需等本轮回答结束
+		go backendForWeb.sendRefer(state, &config)
+		return handoff, nil
+	}
+	backendForWeb.runAfterPlayback(playID, transferHandoffTimeout, func() {
+		backendForWeb.sendRefer(state, &config)
+	})
+	return handoff, nil
+}
+
+// sendRefer 发送refer命令，并在超时后回退到机器人
+func (backendForWeb *BackendForWeb) sendRefer(state *transferState, config *model.RobotTransfer) {
+	if !backendForWeb.isCurrentTransfer(state, model.TransferStatusTransferring) {
+		return
+	}
+	timeoutSecs := config.TimeoutSecs
+	if timeoutSecs <= 0 {
+		timeoutSecs = defaultTransferTimeout
+	}
+	referCommand := model.ReferCommand{
+		Command: "refer",
+		Target:  state.target,
+		Options: &model.ReferOption{
+			Timeout:     uint32(timeoutSecs),
+			MusicOnHold: config.MusicOnHold,
+			// 转接失败时保留通话，由机器人继续服务
+			AutoHangup: false,
+		},
+	}
+	if err := backendForWeb.sendCommandToRust(referCommand); err != nil {
+		logrus.Errorf("send refer command error:%v", err)
+		backendForWeb.failTransfer(state, "refer_error", config.FallbackText)
+		return
+	}
+	timer := time.AfterFunc(time.Duration(timeoutSecs)*time.Second+transferGracePeriod, func() {
+		backendForWeb.failTransfer(state, "timeout", config.FallbackText)
+	})
+	backendForWeb.transferMutex.Lock()
+	state.timer = timer
+	backendForWeb.transferMutex.Unlock()
+}
+
+// SolveTransferAnswered 坐席接听后标记转接成功，机器人不再应答
+func (backendForWeb *BackendForWeb) SolveTransferAnswered() {
+	backendForWeb.transferMutex.Lock()
+	state := backendForWeb.transfer
+	if state == nil || state.status != model.TransferStatusTransferring || state.timer == nil {
+		backendForWeb.transferMutex.Unlock()
+		return
+	}
+	state.timer.Stop()
+	state.status = model.TransferStatusTransferred
+	backendForWeb.transferMutex.Unlock()
+
+	logrus.WithField("target", state.target).Info("transfer answered")
+	backendForWeb.updateTransferStatus(state.status)
+	backendForWeb.ForwardToWebConn(&Event{
+		Event: "transferred",
+		Text:  state.target,
+	})
+}
+
+// SolveTransferRejected 坐席拒接或转接出错时回退到机器人
+func (backendForWeb *BackendForWeb) SolveTransferRejected(reason string) {
+	backendForWeb.transferMutex.Lock()
+	state := backendForWeb.transfer
+	// 未发出refer命令前的错误事件与转接无关
+	referSent := state != nil && state.timer != nil
+	backendForWeb.transferMutex.Unlock()
+	if !referSent {
+		return
+	}
+	var fallbackText string
+	if backendForWeb.Robot != nil && backendForWeb.Robot.Transfer != nil {
+		fallbackText = backendForWeb.Robot.Transfer.FallbackText
+	}
+	backendForWeb.failTransfer(state, reason, fallbackText)
+}
+
+// failTransfer 转接失败，播报回退话术后由机器人继续服务
+func (backendForWeb *BackendForWeb) failTransfer(state *transferState, reason, fallbackText string) {
+	backendForWeb.transferMutex.Lock()
+	if backendForWeb.transfer != state || state.status != model.TransferStatusTransferring {
+		backendForWeb.transferMutex.Unlock()
+		return
+	}
+	if state.timer != nil {
+		state.timer.Stop()
+	}
+	state.status = model.TransferStatusFailed
+	backendForWeb.transferMutex.Unlock()
+
+	logrus.WithFields(logrus.Fields{
+		"target": state.target,
+		"reason": reason,
+	}).Warn("transfer failed, fall back to robot")
+	backendForWeb.updateTransferStatus(state.status)
+	backendForWeb.ForwardToWebConn(&Event{
+		Event:  "transferFailed",
+		Text:   state.target,
+		Reason: reason,
+	})
+
+	if fallbackText == "" {
+		fallbackText = defaultFallbackText
+	}
+	playID := fmt.Sprintf("transfer-fallback-%s", uuid.New().String())
+	if err := backendForWeb.SendTTSCommandForRustBackend(fallbackText, playID, false, nil); err != nil {
+		logrus.Errorf("send transfer fallback error:%v", err)
+		return
+	}
+	// 同步写入大模型历史，回退话术排在随后的用户发言之前
+	backendForWeb.LLMHandler.AddAssistantMessage(fallbackText)
+}
+
+// isTransferActive 转接中或已转接时机器人不再处理识别结果
+func (backendForWeb *BackendForWeb) isTransferActive() bool {
+	backendForWeb.transferMutex.Lock()
+	defer backendForWeb.transferMutex.Unlock()
+	return backendForWeb.transfer != nil && backendForWeb.transfer.status != model.TransferStatusFailed
+}
+
+func (backendForWeb *BackendForWeb) isCurrentTransfer(state *transferState, status string) bool {
+	backendForWeb.transferMutex.Lock()
+	defer backendForWeb.transferMutex.Unlock()
+	return backendForWeb.transfer == state && state.status == status
+}
+
+func (backendForWeb *BackendForWeb) updateTransferStatus(status string) {
+	backendForWeb.updateCallRecord(func(record *model.CallRecord) map[string]interface{} {
+		record.TransferStatus = status
+		return map[string]interface{}{"transfer_status": status}
+	})
+}
+
+// resetTransfer 通话开始或结束时清理转人工状态
+func (backendForWeb *BackendForWeb) resetTransfer() {
+	backendForWeb.transferMutex.Lock()
+	defer backendForWeb.transferMutex.Unlock()
+	if backendForWeb.transfer != nil && backendForWeb.transfer.timer != nil {
+		backendForWeb.transfer.timer.Stop()
+	}
+	backendForWeb.transfer = nil
+}
@@ -0,0 +1,417 @@
+package service
+
+import (
+	"errors"
+	"github.com/sirupsen/logrus"
+	"miniRustpbxgo/internal/handler"
+	"miniRustpbxgo/internal/model"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultEouWindow 机器人未配置时，最后一段识别结果之后等待用户继续说话的时间
+	defaultEouWindow = 500 * time.Millisecond
+	// defaultEouWaitWindow 启用rust端断句检测时等待eou事件的最长时间
+	defaultEouWaitWindow = 2000 * time.Millisecond
+)
+
+// userTurn 一轮用户发言及其回复的状态
+type userTurn struct {
+	id   int
+	text string
+	// state 轮次状态，见model.TurnState*
+	state string
+	// spoken 回复已开始送去合成，此后不再合并重来
+	spoken bool
+	// done 本轮退出后关闭
+	done chan struct{}
+}
+
+// discarded 本轮回复已作废，剩余输出不再播报
+func (turn *userTurn) discarded() bool {
+	return turn.state == model.TurnStateMerged || turn.state == model.TurnStateSuperseded
+}
+
+// turnTimer 结束窗口的计时器
+type turnTimer interface {
+	Stop() bool
+}
+
+// turnClock 创建结束窗口计时器的时钟，为空时使用time.AfterFunc，测试中替换为手动推进的时钟
+type turnClock interface {
+	AfterFunc(d time.Duration, f func()) turnTimer
+}
+
+// turnAssembler 将连续的识别结果合并为一轮用户发言，并跟踪当前正在回答的轮次
+type turnAssembler struct {
+	mutex  sync.Mutex
+	clock  turnClock
+	texts  []string  // 等待合并的识别结果
+	timer  turnTimer // 结束窗口计时，到期后开始回复
+	active *userTurn // 正在生成回复的轮次
+	seq    int       // 本通电话的轮次序号
+	// ready 窗口已结束但上一轮还未退出，上一轮退出后立即开始
+	ready bool
+}
+
+// turnReport 轮次状态变化，释放turns.mutex后记录日志并写入通话记录
+type turnReport struct {
+	turnID int
+	state  string
+	text   string
+}
+
+// turnStateIgnored 按ignore策略丢弃的发言，只计入通话记录的ignored_turns
+const turnStateIgnored = "ignored"
+
+// eouWindow 最后一段识别结果之后等待的时间
+func (backendForWeb *BackendForWeb) eouWindow() time.Duration {
+	robot := backendForWeb.Robot
+	switch {
+	case robot == nil:
+		return defaultEouWindow
+	case robot.EouWindowMs > 0:
+		return time.Duration(robot.EouWindowMs) * time.Millisecond
+	case robot.EouType != "":
+		return defaultEouWaitWindow
+	default:
+		return defaultEouWindow
+	}
+}
+
+// turnPolicy 机器人回答期间用户又说话时的处理方式
+func (backendForWeb *BackendForWeb) turnPolicy() string {
+	if backendForWeb.Robot == nil || backendForWeb.Robot.TurnPolicy == "" {
+		return model.TurnPolicyQueue
+	}
+	return backendForWeb.Robot.TurnPolicy
+}
+
+// buildEouOption 机器人启用断句检测时，在invite中让rust发送eou事件
+func buildEouOption(key *model.RobotKey, robot *model.Robot) *model.EouOption {
+	if robot == nil || robot.EouType == "" {
+		return nil
+	}
+	option := &model.EouOption{Type: robot.EouType}
+	if robot.EouWindowMs > 0 {
+		option.Timeout = uint32(robot.EouWindowMs)
+	}
+	if key != nil {
+		option.SecretID = key.ASRSecretID
+		option.SecretKey = key.ASRSecretKey
+	}
+	return option
+}
+
+// SolveAsrFinalEvent 收到一段识别结果，等待结束窗口内的后续结果一起回复。
+// 上一轮回复还未开始播报时取消它，与本段合并后重新回复；
+// 机器人正在回答时按机器人的turn_policy排队、取代当前回答或忽略本段
+func (backendForWeb *BackendForWeb) SolveAsrFinalEvent(event *Event) {
+	text := strings.TrimSpace(event.Text)
+	if text == "" {
+		return
+	}
+	// 已转接人工时不再由机器人应答
+	if backendForWeb.isTransferActive() {
+		logrus.Info("call is transferred, skip asrFinal")
+		return
+	}
+	// 达到最长通话时长后只等待结束语播完挂断
+	if backendForWeb.isCallClosing() {
+		logrus.Info("call is closing, skip asrFinal")
+		return
+	}
+	backendForWeb.SolveCallerActivity()
+	policy := backendForWeb.turnPolicy()
+	playing := backendForWeb.isPlaying()
+
+	assembler := &backendForWeb.turns
+	assembler.mutex.Lock()
+	turn := assembler.active
+	var report *turnReport
+	merge, replace := false, false
+	switch {
+	case turn != nil && !turn.spoken && !turn.discarded():
+		report = backendForWeb.markTurnLocked(turn, model.TurnStateMerged)
+		merge = true
+	case playing || (turn != nil && turn.spoken && !turn.discarded()):
+		switch policy {
+		case model.TurnPolicyIgnore:
+			report = backendForWeb.ignoreTurnLocked(text)
+			assembler.mutex.Unlock()
+			backendForWeb.reportTurn(report)
+			return
+		case model.TurnPolicyReplace:
+			if turn != nil && !turn.discarded() {
+				report = backendForWeb.markTurnLocked(turn, model.TurnStateSuperseded)
+			}
+			replace = true
+		}
+	}
+	assembler.texts = append(assembler.texts, text)
+	backendForWeb.armTurnTimerLocked()
+	assembler.mutex.Unlock()
+
+	backendForWeb.reportTurn(report)
+	switch {
+	case merge:
+		logrus.WithField("text", text).Info("caller continued before the reply was spoken, restart the turn")
+		backendForWeb.LLMHandler.Interrupt()
+	case replace && playing:
+		// 停止播放并按播放位置截断历史中未播报的内容
+		backendForWeb.bargeIn()
+	case replace:
+		backendForWeb.LLMHandler.Interrupt()
+	}
+}
+
+// SolveEou rust端断句检测的结果，complete表示用户已说完，否则继续等待
+func (backendForWeb *BackendForWeb) SolveEou(complete bool) {
+	assembler := &backendForWeb.turns
+	assembler.mutex.Lock()
+	if len(assembler.texts) == 0 {
+		assembler.mutex.Unlock()
+		return
+	}
+	if !complete {
+		backendForWeb.armTurnTimerLocked()
+		assembler.mutex.Unlock()
+		return
+	}
+	if assembler.timer != nil {
+		assembler.timer.Stop()
+		assembler.timer = nil
+	}
+	assembler.mutex.Unlock()
+	backendForWeb.flushTurn()
+}
+
+// armTurnTimerLocked 重新开始结束窗口计时，调用方需持有turns.mutex
+func (backendForWeb *BackendForWeb) armTurnTimerLocked() {
+	assembler := &backendForWeb.turns
+	if assembler.timer != nil {
+		assembler.timer.Stop()
+	}
+	flush := func() {
+		assembler.mutex.Lock()
+		assembler.timer = nil
+		assembler.mutex.Unlock()
+		backendForWeb.flushTurn()
+	}
+	if assembler.clock != nil {
+		assembler.timer = assembler.clock.AfterFunc(backendForWeb.eouWindow(), flush)
+		return
+	}
+	assembler.timer = time.AfterFunc(backendForWeb.eouWindow(), flush)
+}
+
+// flushTurn 结束窗口到期，合并等待中的识别结果开始回复，上一轮未退出时等它退出后再开始
+func (backendForWeb *BackendForWeb) flushTurn() {
+	assembler := &backendForWeb.turns
+	if backendForWeb.isCallClosing() {
+		return
+	}
+	assembler.mutex.Lock()
+	if len(assembler.texts) == 0 {
+		assembler.mutex.Unlock()
+		return
+	}
+	if assembler.active != nil {
+		assembler.ready = true
+		assembler.mutex.Unlock()
+		return
+	}
+	assembler.seq++
+	turn := &userTurn{id: assembler.seq, text: strings.Join(assembler.texts, " "), done: make(chan struct{})}
+	assembler.texts = nil
+	assembler.active = turn
+	report := backendForWeb.markTurnLocked(turn, model.TurnStateThinking)
+	assembler.mutex.Unlock()
+
+	backendForWeb.reportTurn(report)
+	backendForWeb.stopSilenceTimer()
+	go backendForWeb.runTurn(turn)
+}
+
+// runTurn 生成一轮回复，本轮作废后丢弃剩余输出
+func (backendForWeb *BackendForWeb) runTurn(turn *userTurn) {
+	backendForWeb.armFiller()
+	response, err := backendForWeb.LLMHandler.QueryStream(backendForWeb.Model, turn.text, func(segment string, playID string, autoHangup, endOfStream bool) error {
+		assembler := &backendForWeb.turns
+		assembler.mutex.Lock()
+		if turn.discarded() {
+			assembler.mutex.Unlock()
+			// 作废时生成可能还未开始，此时再打断一次
+			backendForWeb.LLMHandler.Interrupt()
+			return handler.ErrInterrupted
+		}
+		if segment != "" {
+			backendForWeb.stopFiller()
+		}
+		var report *turnReport
+		if segment != "" && !turn.spoken {
+			turn.spoken = true
+			report = backendForWeb.markTurnLocked(turn, model.TurnStateSpeaking)
+		}
+		assembler.mutex.Unlock()
+		backendForWeb.reportTurn(report)
+		return backendForWeb.sendTTSSegment(segment, playID, autoHangup, endOfStream)
+	})
+	state := backendForWeb.finishTurn(turn, err)
+
+	switch {
+	case errors.Is(err, handler.ErrInterrupted):
+		logrus.Info("SolveAsrFinalEvent response interrupted by caller")
+	case err != nil:
+		logrus.Error("SolveAsrFinalEvent response error:", err)
+		backendForWeb.speakLLMFallback()
+	case state == model.TurnStateDone:
+		backendForWeb.ForwardToWebConn(&Event{
+			Event: "LLMResult",
+			Text:  response,
+		})
+	}
+}
+
+// finishTurn 一轮回复结束，返回本轮的最终状态。
+// 回复被打断且一句都没播报时，撤回本轮发言并合并到下一轮
+func (backendForWeb *BackendForWeb) finishTurn(turn *userTurn, err error) string {
+	backendForWeb.stopFiller()
+	assembler := &backendForWeb.turns
+	assembler.mutex.Lock()
+	retract := errors.Is(err, handler.ErrInterrupted) && !turn.spoken
+	assembler.mutex.Unlock()
+
+	if retract {
+		if text, ok := backendForWeb.LLMHandler.RetractLastUserTurn(); ok {
+			assembler.mutex.Lock()
+			assembler.texts = append([]string{text}, assembler.texts...)
+			// 打断后用户没有继续说话（如咳嗽），窗口结束后仍回答原来的问题
+			if assembler.timer == nil && !assembler.ready {
+				backendForWeb.armTurnTimerLocked()
+			}
+			assembler.mutex.Unlock()
+		}
+	}
+
+	assembler.mutex.Lock()
+	var report *turnReport
+	if !turn.discarded() {
+		state := model.TurnStateDone
+		switch {
+		case retract:
+			state = model.TurnStateMerged
+		case errors.Is(err, handler.ErrInterrupted):
+			state = model.TurnStateInterrupted
+		case err != nil:
+			state = model.TurnStateFailed
+		}
+		report = backendForWeb.markTurnLocked(turn, state)
+	}
+	state := turn.state
+	assembler.active = nil
+	close(turn.done)
+	ready := assembler.ready
+	assembler.ready = false
+	assembler.mutex.Unlock()
+
+	backendForWeb.reportTurn(report)
+	if ready {
+		backendForWeb.flushTurn()
+	} else {
+		// 回复没有播放任何语音时（如被打断），从此刻起计算沉默
+		backendForWeb.armSilenceTimer()
+	}
+	return state
+}
+
+// markTurnLocked 更新轮次状态，调用方需持有turns.mutex
+func (backendForWeb *BackendForWeb) markTurnLocked(turn *userTurn, state string) *turnReport {
+	turn.state = state
+	return &turnReport{turnID: turn.id, state: state, text: turn.text}
+}
+
+// ignoreTurnLocked 按ignore策略丢弃机器人回答期间的发言，调用方需持有turns.mutex
+func (backendForWeb *BackendForWeb) ignoreTurnLocked(text string) *turnReport {
+	return &turnReport{state: turnStateIgnored, text: text}
+}
+
+// reportTurn 记录轮次状态变化并更新通话记录中的统计
+func (backendForWeb *BackendForWeb) reportTurn(report *turnReport) {
+	if report == nil {
+		return
+	}
+	fields := logrus.Fields{
+		"turn":   report.turnID,
+		"state":  report.state,
+		"policy": backendForWeb.turnPolicy(),
+		"text":   report.text,
+	}
+	backendForWeb.updateCallRecord(func(record *model.CallRecord) map[string]interface{} {
+		fields["callId"] = record.CallID
+		if report.state == turnStateIgnored {
+			record.IgnoredTurns++
+			return map[string]interface{}{"ignored_turns": record.IgnoredTurns}
+		}
+		record.TurnState = report.state
+		updates := map[string]interface{}{"turn_state": report.state}
+		switch report.state {
+		case model.TurnStateThinking:
+			record.TurnCount++
+			updates["turn_count"] = record.TurnCount
+		case model.TurnStateSuperseded:
+			record.SupersededTurns++
+			updates["superseded_turns"] = record.SupersededTurns
+		}
+		return updates
+	})
+	logrus.WithFields(fields).Info("user turn state changed")
+}
+
+// turnPending 有等待合并的发言或正在生成回复
+func (backendForWeb *BackendForWeb) turnPending() bool {
+	assembler := &backendForWeb.turns
+	assembler.mutex.Lock()
+	defer assembler.mutex.Unlock()
+	return assembler.active != nil || len(assembler.texts) > 0
+}
+
+// stopActiveTurn 丢弃等待合并的发言并打断正在生成的回复，等待该轮退出，超时返回false
+func (backendForWeb *BackendForWeb) stopActiveTurn(timeout time.Duration) bool {
+	assembler := &backendForWeb.turns
+	assembler.mutex.Lock()
+	if assembler.timer != nil {
+		assembler.timer.Stop()
+		assembler.timer = nil
+	}
+	assembler.texts = nil
+	assembler.ready = false
+	turn := assembler.active
+	assembler.mutex.Unlock()
+	if turn == nil {
+		return true
+	}
+	backendForWeb.LLMHandler.Interrupt()
+	select {
+	case <-turn.done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// resetTurns 新通话开始时清理未完成的发言
+func (backendForWeb *BackendForWeb) resetTurns() {
+	assembler := &backendForWeb.turns
+	assembler.mutex.Lock()
+	defer assembler.mutex.Unlock()
+	if assembler.timer != nil {
+		assembler.timer.Stop()
+		assembler.timer = nil
+	}
+	assembler.texts = nil
+	assembler.seq = 0
+	assembler.ready = false
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"miniRustpbxgo/internal/model"
+)
+
+func TestTurnPolicy(t *testing.T) {
+	// the first answer is spoken and then stays open until released or cancelled
+	replies := []fakeReply{{content: "您好，请问有什么可以帮您？", hold: true}, {content: "好的。"}}
+	speaking := []turnStep{
+		{final: "你好"},
+		{advance: 500 * time.Millisecond},
+		{queries: 1},
+		{spoken: true},
+		{final: "我想查询订单"},
+		{advance: 500 * time.Millisecond},
+	}
+	tests := []struct {
+		name   string
+		policy string
+		steps  []turnStep
+		want   []string
+	}{
+		{
+			name:   "queue waits for the current answer",
+			policy: model.TurnPolicyQueue,
+			steps:  speaking,
+			want:   []string{"你好"},
+		},
+		{
+			name:   "queue answers after the current answer",
+			policy: model.TurnPolicyQueue,
+			steps:  append(append([]turnStep(nil), speaking...), turnStep{release: true}),
+			want:   []string{"你好", "我想查询订单"},
+		},
+		{
+			name:   "empty policy queues",
+			policy: "",
+			steps:  append(append([]turnStep(nil), speaking...), turnStep{release: true}),
+			want:   []string{"你好", "我想查询订单"},
+		},
+		{
+			name:   "replace stops the current answer",
+			policy: model.TurnPolicyReplace,
+			steps:  speaking,
+			want:   []string{"你好", "我想查询订单"},
+		},
+		{
+			name:   "ignore drops the caller's turn",
+			policy: model.TurnPolicyIgnore,
+			steps:  append(append([]turnStep(nil), speaking...), turnStep{release: true}),
+			want:   []string{"你好"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llm := newFakeLLM(replies...)
+			backendForWeb, clock := newTurnTestBackend(t, &model.Robot{TurnPolicy: tt.policy}, llm)
+			runTurnSteps(t, backendForWeb, clock, llm, tt.steps)
+			checkQueries(t, llm, tt.want)
+		})
+	}
+}
@@ -0,0 +1,326 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/sirupsen/logrus"
+	"miniRustpbxgo/internal/handler"
+	"miniRustpbxgo/internal/model"
+)
+
+// fakeClock fires timers only when the test advances it
+type fakeClock struct {
+	mutex  sync.Mutex
+	now    time.Duration
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock   *fakeClock
+	at      time.Duration
+	f       func()
+	stopped bool
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) turnTimer {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	timer := &fakeTimer{clock: c, at: c.now + d, f: f}
+	c.timers = append(c.timers, timer)
+	return timer
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+	active := !t.stopped
+	t.stopped = true
+	return active
+}
+
+// Advance moves the clock forward and runs the timers that became due
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	c.now += d
+	var due []*fakeTimer
+	for _, timer := range c.timers {
+		if !timer.stopped && timer.at <= c.now {
+			timer.stopped = true
+			due = append(due, timer)
+		}
+	}
+	c.mutex.Unlock()
+	for _, timer := range due {
+		timer.f()
+	}
+}
+
+// fakeReply is what the fake LLM streams for one request
+type fakeReply struct {
+	content string
+	// hold keeps the stream open after content until the turn is cancelled or the LLM is released
+	hold bool
+}
+
+// fakeLLM records the user text of every request and answers with scripted replies,
+// the last reply repeats
+type fakeLLM struct {
+	mutex   sync.Mutex
+	replies []fakeReply
+	queries []string
+	release chan struct{}
+	once    sync.Once
+}
+
+func newFakeLLM(replies ...fakeReply) *fakeLLM {
+	return &fakeLLM{replies: replies, release: make(chan struct{})}
+}
+
+func (f *fakeLLM) Name() string { return "fake" }
+
+func (f *fakeLLM) DefaultModel() string { return "fake" }
+
+func (f *fakeLLM) ChatStream(ctx context.Context, request handler.ChatRequest) (handler.ChatStream, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	query := ""
+	for _, message := range request.Messages {
+		if message.Role == openai.ChatMessageRoleUser {
+			query = message.Content
+		}
+	}
+	reply := fakeReply{content: "好的。"}
+	if len(f.replies) > 0 {
+		reply = f.replies[min(len(f.queries), len(f.replies)-1)]
+	}
+	f.queries = append(f.queries, query)
+	stream := &fakeStream{ctx: ctx, hold: reply.hold, release: f.release}
+	if reply.content != "" {
+		stream.deltas = []string{reply.content}
+	}
+	return stream, nil
+}
+
+func (f *fakeLLM) Chat(ctx context.Context, request handler.ChatRequest) (handler.ChatResponse, error) {
+	return handler.ChatResponse{}, errors.New("fake LLM only streams")
+}
+
+// Release lets held streams finish
+func (f *fakeLLM) Release() {
+	f.once.Do(func() { close(f.release) })
+}
+
+func (f *fakeLLM) Queries() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]string(nil), f.queries...)
+}
+
+type fakeStream struct {
+	ctx     context.Context
+	deltas  []string
+	hold    bool
+	release chan struct{}
+}
+
+func (s *fakeStream) Recv() (handler.ChatDelta, error) {
+	if len(s.deltas) > 0 {
+		delta := s.deltas[0]
+		s.deltas = s.deltas[1:]
+		return handler.ChatDelta{Content: delta}, nil
+	}
+	if s.hold {
+		select {
+		case <-s.ctx.Done():
+			return handler.ChatDelta{}, s.ctx.Err()
+		case <-s.release:
+		}
+	}
+	return handler.ChatDelta{}, io.EOF
+}
+
+func (s *fakeStream) Close() error { return nil }
+
+// turnStep is one thing that happens during a call, in order
+type turnStep struct {
+	final   string        // an asrFinal with this text
+	eou     string        // "complete" or "incomplete" from the rust end-of-utterance detection
+	advance time.Duration // moves the fake clock
+	queries int           // waits until the LLM received this many requests
+	spoken  bool          // waits until the active turn started speaking
+	release bool          // lets held LLM streams finish
+}
+
+func newTurnTestBackend(t *testing.T, robot *model.Robot, llm *fakeLLM) (*BackendForWeb, *fakeClock) {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetLevel(logrus.WarnLevel)
+	backendForWeb := NewBackendForWebByNoParam(nil)
+	backendForWeb.Robot = robot
+	backendForWeb.LLMHandler = handler.NewLLMHandlerWithProvider(context.Background(), llm, "system", logger)
+	clock := &fakeClock{}
+	backendForWeb.turns.clock = clock
+	t.Cleanup(llm.Release)
+	return backendForWeb, clock
+}
+
+func runTurnSteps(t *testing.T, backendForWeb *BackendForWeb, clock *fakeClock, llm *fakeLLM, steps []turnStep) {
+	t.Helper()
+	for i, step := range steps {
+		switch {
+		case step.final != "":
+			backendForWeb.SolveAsrFinalEvent(&Event{Event: "asrFinal", Text: step.final})
+		case step.eou != "":
+			backendForWeb.SolveEou(step.eou == "complete")
+		case step.advance > 0:
+			clock.Advance(step.advance)
+		case step.queries > 0:
+			waitFor(t, func() bool { return len(llm.Queries()) >= step.queries }, "step %d: %d LLM requests, got %q", i, step.queries, llm.Queries())
+		case step.spoken:
+			waitFor(t, func() bool {
+				backendForWeb.turns.mutex.Lock()
+				defer backendForWeb.turns.mutex.Unlock()
+				return backendForWeb.turns.active != nil && backendForWeb.turns.active.spoken
+			}, "step %d: active turn speaking", i)
+		case step.release:
+			llm.Release()
+		}
+	}
+}
+
+// waitFor polls cond because turns are answered on their own goroutine
+func waitFor(t *testing.T, cond func() bool, format string, args ...any) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for "+format, args...)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// checkQueries waits for the wanted requests and makes sure no extra one follows
+func checkQueries(t *testing.T, llm *fakeLLM, want []string) {
+	t.Helper()
+	waitFor(t, func() bool { return len(llm.Queries()) >= len(want) }, "%d LLM requests, got %q", len(want), llm.Queries())
+	time.Sleep(20 * time.Millisecond)
+	if got := llm.Queries(); !reflect.DeepEqual(got, want) {
+		t.Errorf("LLM requests = %q, want %q", got, want)
+	}
+}
+
+func TestTurnAssembler(t *testing.T) {
+	tests := []struct {
+		name    string
+		robot   *model.Robot
+		replies []fakeReply
+		steps   []turnStep
+		// want are the user turns sent to the LLM
+		want []string
+	}{
+		{
+			name:  "finals within the window are merged",
+			robot: &model.Robot{},
+			steps: []turnStep{
+				{final: "我想查询"},
+				{advance: 200 * time.Millisecond},
+				{final: "订单状态"},
+				{advance: 500 * time.Millisecond},
+			},
+			want: []string{"我想查询 订单状态"},
+		},
+		{
+			name:  "each final restarts the window",
+			robot: &model.Robot{EouWindowMs: 800},
+			steps: []turnStep{
+				{final: "我想查询"},
+				{advance: 600 * time.Millisecond},
+				{final: "订单状态"},
+				{advance: 600 * time.Millisecond},
+				{final: "谢谢"},
+				{advance: 800 * time.Millisecond},
+			},
+			want: []string{"我想查询 订单状态 谢谢"},
+		},
+		{
+			name:  "no answer before the window ends",
+			robot: &model.Robot{},
+			steps: []turnStep{
+				{final: "我想查询"},
+				{advance: 499 * time.Millisecond},
+			},
+			want: nil,
+		},
+		{
+			name:  "finals after the answer start a new turn",
+			robot: &model.Robot{},
+			steps: []turnStep{
+				{final: "你好"},
+				{advance: 500 * time.Millisecond},
+				{queries: 1},
+				{final: "我想查询订单"},
+				{advance: 500 * time.Millisecond},
+			},
+			want: []string{"你好", "我想查询订单"},
+		},
+		{
+			name:  "complete eou answers without waiting",
+			robot: &model.Robot{EouType: "tencent"},
+			steps: []turnStep{
+				{final: "我想查询"},
+				{final: "订单状态"},
+				{eou: "complete"},
+			},
+			want: []string{"我想查询 订单状态"},
+		},
+		{
+			name:  "incomplete eou keeps waiting for the window",
+			robot: &model.Robot{EouType: "tencent"},
+			steps: []turnStep{
+				{final: "我想查询"},
+				{eou: "incomplete"},
+				{advance: 1500 * time.Millisecond},
+				{final: "订单状态"},
+				{advance: 2000 * time.Millisecond},
+			},
+			want: []string{"我想查询 订单状态"},
+		},
+		{
+			name:  "eou without pending finals is ignored",
+			robot: &model.Robot{EouType: "tencent"},
+			steps: []turnStep{
+				{eou: "complete"},
+			},
+			want: nil,
+		},
+		{
+			name:    "final before the answer is spoken restarts the turn",
+			robot:   &model.Robot{},
+			replies: []fakeReply{{hold: true}, {content: "好的。"}},
+			steps: []turnStep{
+				{final: "我想查询"},
+				{advance: 500 * time.Millisecond},
+				{queries: 1},
+				{final: "订单状态"},
+				{advance: 500 * time.Millisecond},
+			},
+			want: []string{"我想查询", "我想查询 订单状态"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llm := newFakeLLM(tt.replies...)
+			backendForWeb, clock := newTurnTestBackend(t, tt.robot, llm)
+			runTurnSteps(t, backendForWeb, clock, llm, tt.steps)
+			checkQueries(t, llm, tt.want)
+		})
+	}
+}
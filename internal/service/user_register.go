@@ -74,7 +74,7 @@ func (app *App) Register(ctx *gin.Context) {
 func encryptPassword(password string) (string, error) {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
-		logrus.Error("bcrypt generate from password error = %v", err)
+		logrus.Errorf("bcrypt generate from password error = %v", err)
 		return "", err
 	}
 	return string(hashedPassword), nil
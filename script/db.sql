@@ -35,6 +35,9 @@ CREATE TABLE IF NOT EXISTS robotKeys (
                                     tts_secret_id VARCHAR(255) COMMENT '语音合成Secret ID',
                                     tts_secret_key VARCHAR(255) COMMENT '语音合成Secret Key',
 
+    -- 通话限制
+                                    max_call_duration_sec INT NOT NULL DEFAULT 0 COMMENT '使用该密钥的通话最长秒数，0表示不限制',
+
     -- 新增的API密钥字段
                                     api_key VARCHAR(255) COMMENT 'API密钥',
                                     api_secret VARCHAR(255) COMMENT 'API密钥的Secret',
@@ -53,10 +56,93 @@ CREATE TABLE IF NOT EXISTS robots (
                                       speed FLOAT COMMENT '语音语速，浮点型（支持0.5-2.0）',
                                       volume INT COMMENT '语音音量，整数型（数值越大音量越高，通常范围0-10）',
                                       speaker VARCHAR(50) COMMENT '根据枚举类查找腾讯服务商具体对应信息',
+                                      tts_provider VARCHAR(100) COMMENT '发音人所属语音合成服务商，默认tencent',
+                                      sample_rate INT COMMENT '语音合成采样率，需服务商支持',
                                       emotion VARCHAR(50) COMMENT '语音情感（如"happy"、"sad"、"neutral"等情感类型）',
                                       system_prompt TEXT COMMENT '系统提示词，用于定义机器人的行为模式或角色设定',
+                                      greeting_type VARCHAR(20) COMMENT '开场白类型：text-固定文本，audio-播放音频，llm-大模型生成',
+                                      greeting TEXT COMMENT '开场白文本，audio类型时为音频对应文字，llm类型时为生成指令',
+                                      greeting_url VARCHAR(255) COMMENT '开场白音频地址',
+                                      timezone VARCHAR(64) COMMENT '时区，用于提示词模板中的日期时间，默认Asia/Shanghai',
+                                      template_strict TINYINT(1) NOT NULL DEFAULT 0 COMMENT '模板缺失变量时是否报错：1-报错，0-替换为空',
+                                      tools TEXT COMMENT '启用的大模型工具配置（JSON），为空时仅启用hangup',
+                                      transfer TEXT COMMENT '转人工配置（JSON）：转接目标、转接话术、等待音乐、超时时间',
+                                      barge_in TINYINT(1) NOT NULL DEFAULT 0 COMMENT '是否允许用户打断机器人说话：1-允许，0-不允许',
+                                      barge_in_min_ms INT NOT NULL DEFAULT 0 COMMENT '触发打断的最短说话时长（毫秒）',
+                                      eou_window_ms INT NOT NULL DEFAULT 0 COMMENT '最后一段识别结果后等待用户继续说话的毫秒数，0表示默认500，启用断句检测时为最长等待时间',
+                                      eou_type VARCHAR(50) COMMENT 'rust端断句检测的服务类型，为空时只按等待时间合并识别结果',
+                                      turn_policy VARCHAR(20) COMMENT '机器人回答期间用户又说话时的处理方式：queue-说完再回答|replace-停止当前回答改答新发言|ignore-忽略，为空表示queue',
+                                      fillers TEXT COMMENT '等待大模型回复时播报的填充语（JSON数组）：文本或音频地址，随机选取一条',
+                                      filler_delay_ms INT NOT NULL DEFAULT 0 COMMENT '等待多少毫秒仍没有回复时播报填充语，0表示默认1000',
+                                      silence_timeout_sec INT NOT NULL DEFAULT 0 COMMENT '机器人说完后用户沉默多少秒进行追问，0表示不追问',
+                                      silence_reprompt TEXT COMMENT '用户沉默时的追问话术，为空使用默认话术',
+                                      silence_max_reprompts INT NOT NULL DEFAULT 0 COMMENT '最多追问次数，之后说告别语并以idle原因挂断，0表示默认2',
+                                      idle_goodbye TEXT COMMENT '用户一直沉默挂断前的告别语，为空使用默认话术',
+                                      max_call_duration_sec INT NOT NULL DEFAULT 0 COMMENT '通话最长秒数，与密钥上的限制取较小值，0表示不限制',
+                                      duration_warning_sec INT NOT NULL DEFAULT 0 COMMENT '达到最长时长前多少秒播报提醒，0表示不提醒',
+                                      duration_warning TEXT COMMENT '通话即将结束的提醒话术，为空使用默认话术',
+                                      duration_goodbye TEXT COMMENT '达到最长时长挂断前的结束语，为空使用默认话术',
+                                      dtmf TEXT COMMENT '按键配置（JSON）：按键菜单（播报、转人工、切换机器人）和号码收集的结束键、超时、位数',
+                                      amd_policy VARCHAR(20) COMMENT '外呼检测到答录机时的处理方式：hangup|voicemail|continue',
+                                      voicemail_text TEXT COMMENT '答录机提示音后合成的留言',
+                                      voicemail_url VARCHAR(255) COMMENT '答录机提示音后播放的留言音频地址',
+                                      streaming_tts TINYINT(1) NOT NULL DEFAULT 0 COMMENT '是否使用流式语音合成：1-整轮回复合成为一段连续语音，0-按标点分段合成',
+                                      segment_min_runes INT NOT NULL DEFAULT 0 COMMENT '回复分段合成时每段的最少字数，0表示默认6，首段不受限制',
+                                      segment_max_runes INT NOT NULL DEFAULT 0 COMMENT '回复分段合成时每段的最多字数，0表示默认80',
+                                      lexicon TEXT COMMENT '发音词典（JSON）：键为原词，值为朗读时替换成的文本',
+                                      context_max_tokens INT NOT NULL DEFAULT 0 COMMENT '对话历史的token预算，0表示默认8000',
+                                      context_strategy VARCHAR(20) COMMENT '超出预算时的处理方式：truncate|summarize',
+                                      llm_endpoints TEXT COMMENT '备用大模型节点（JSON数组）：提供商、API密钥、API地址、模型，主节点失败后按顺序切换',
+                                      llm_fallback_text TEXT COMMENT '所有大模型节点都失败时播报的话术',
                                       created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
                                       updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
     -- 外键约束，关联users表的id字段，级联删除
                                       FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT '机器人语音及行为配置表';
+
+CREATE TABLE IF NOT EXISTS call_records (
+                                      id INT AUTO_INCREMENT PRIMARY KEY COMMENT '通话记录ID，自增主键',
+                                      call_id VARCHAR(64) NOT NULL UNIQUE COMMENT '通话唯一标识',
+                                      user_id INT NOT NULL COMMENT '关联的用户ID',
+                                      robot_id INT COMMENT '接听的机器人ID',
+                                      robot_key_id INT COMMENT '使用的机器人密钥ID',
+                                      direction VARCHAR(20) COMMENT '呼叫方向：webrtc|inbound|outbound',
+                                      caller VARCHAR(255) COMMENT '主叫',
+                                      callee VARCHAR(255) COMMENT '被叫',
+                                      status VARCHAR(20) COMMENT '通话状态：calling|answered|ended|failed',
+                                      hangup_reason VARCHAR(255) COMMENT '挂断原因',
+                                      hangup_initiator VARCHAR(50) COMMENT '挂断发起方',
+                                      transfer_target VARCHAR(255) COMMENT '转人工目标',
+                                      transfer_status VARCHAR(20) COMMENT '转人工状态：transferring|transferred|failed',
+                                      answered_by VARCHAR(20) COMMENT '外呼接听方：human|machine',
+                                      turn_count INT NOT NULL DEFAULT 0 COMMENT '机器人回答的用户发言轮数',
+                                      turn_state VARCHAR(20) COMMENT '最近一轮发言的状态：thinking|speaking|done|interrupted|merged|superseded|failed',
+                                      superseded_turns INT NOT NULL DEFAULT 0 COMMENT '被新发言取代而未答完的轮数',
+                                      ignored_turns INT NOT NULL DEFAULT 0 COMMENT '机器人回答期间被忽略的发言数',
+                                      started_at DATETIME COMMENT '发起时间',
+                                      answered_at DATETIME COMMENT '接通时间',
+                                      ended_at DATETIME COMMENT '结束时间',
+                                      duration BIGINT NOT NULL DEFAULT 0 COMMENT '接通时长（秒）',
+                                      created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+                                      updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+                                      INDEX idx_call_records_user_id (user_id),
+                                      INDEX idx_call_records_robot_id (robot_id),
+    -- 外键约束，关联users表的id字段，级联删除
+                                      FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT '通话记录表';
+
+CREATE TABLE IF NOT EXISTS phone_numbers (
+                                      id INT AUTO_INCREMENT PRIMARY KEY COMMENT '呼入号码ID，自增主键',
+                                      user_id INT NOT NULL COMMENT '关联的用户ID',
+                                      number VARCHAR(255) NOT NULL UNIQUE COMMENT '被叫号码或SIP URI',
+                                      robot_id INT NOT NULL COMMENT '接听的机器人ID',
+                                      robot_key_id INT NOT NULL COMMENT '使用的机器人密钥ID',
+                                      enabled TINYINT(1) NOT NULL DEFAULT 1 COMMENT '是否接听呼入',
+                                      allow_list TEXT COMMENT '允许呼入的主叫号码（JSON数组，为空不限制）',
+                                      block_list TEXT COMMENT '拒绝呼入的主叫号码（JSON数组）',
+                                      created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+                                      updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+                                      INDEX idx_phone_numbers_user_id (user_id),
+    -- 外键约束，关联users表的id字段，级联删除
+                                      FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT '呼入号码路由表';